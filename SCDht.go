@@ -1,21 +1,67 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/astaxie/beego"
 	"github.com/beego/i18n"
+	"github.com/ylqjgm/SCDht/cmd"
 	"github.com/ylqjgm/SCDht/common"
 	"github.com/ylqjgm/SCDht/controllers"
 	"github.com/ylqjgm/SCDht/models"
 )
 
 func main() {
-	// 初始化
-	models.Init()
+	// 执行命令行子命令
+	if len(os.Args) > 1 {
+		os.Exit(cmd.Run(os.Args[1:]))
+	}
+
+	// 载入语言文件
+	common.SetLang()
+
+	// 初始化, 数据库无法连接时退出
+	if err := models.Init(); err != nil {
+		beego.Critical("init store: " + err.Error())
+		os.Exit(1)
+	}
+	defer func() {
+		if err := models.Close(); err != nil {
+			beego.Error("close store: " + err.Error())
+		}
+	}()
+
+	// 收到退出信号时停止Web服务, beego.Run返回后由defer写入缓冲中的统计
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := beego.BeeApp.Server.Shutdown(ctx); err != nil {
+			beego.Error("shutdown: " + err.Error())
+		}
+	}()
+
+	// 执行未完成的数据迁移
+	if automigrate, err := beego.AppConfig.Bool("automigrate"); err != nil || automigrate {
+		go migrate()
+	}
 
 	// 启动dht
 	go common.Dht()
 	// 启动入库
 	go common.Put()
+	// 启动hash清理
+	go common.Janitor()
+	// 启动重复内容分组更新
+	go common.GroupJanitor()
 
 	// 主页路由
 	beego.Router("/", &controllers.IndexController{}, "get:Index")
@@ -29,6 +75,16 @@ func main() {
 	beego.Router("/new", &controllers.IndexController{}, "get:Newly")
 	// 磁力链转种子
 	beego.Router("/torrent", &controllers.IndexController{}, "*:Torrent")
+	// 种子编辑
+	beego.Router("/edit", &controllers.IndexController{}, "*:Edit")
+	// 种子编辑接口
+	beego.Router("/api/edit", &controllers.IndexController{}, "post:EditApi")
+	// 运行状态
+	beego.Router("/admin/status", &controllers.AdminController{}, "get:Status")
+	// 统计数据
+	beego.Router("/admin/stats", &controllers.AdminController{}, "get:Stats")
+	// hash清理报告
+	beego.Router("/admin/prune", &controllers.AdminController{}, "get:Prune")
 	// 显示页路由
 	beego.Router("/:infohash", &controllers.IndexController{}, "get:View")
 	// 设置静态目录
@@ -40,7 +96,9 @@ func main() {
 	beego.AddFuncMap("DateFormat", common.DateFormat)
 	beego.AddFuncMap("FileFormat", common.FileType)
 	beego.AddFuncMap("Thunder", common.Thunder)
+	beego.AddFuncMap("Magnet", common.Magnet)
 	beego.AddFuncMap("FileList", common.TreeShow)
+	beego.AddFuncMap("Sparkline", common.Sparkline)
 	beego.AddFuncMap("i18n", i18n.Tr)
 
 	// 自定义错误页
@@ -49,3 +107,25 @@ func main() {
 	// 启动Web
 	beego.Run()
 }
+
+// 执行数据迁移, 每分钟最多输出一次进度
+func migrate() {
+	m := common.NewMigrator(100)
+	last := time.Now()
+	m.Report = func(progress common.MigrateProgress) {
+		if progress.Done {
+			if progress.Count > 0 {
+				beego.Info(fmt.Sprintf("migrate %s: %d torrents converted", progress.Name, progress.Count))
+			}
+			return
+		}
+		if time.Since(last) >= time.Minute {
+			last = time.Now()
+			beego.Info(fmt.Sprintf("migrate %s: %d/%d", progress.Name, progress.Count, progress.Total))
+		}
+	}
+
+	if err := m.Run(); err != nil {
+		beego.Error("migrate: " + err.Error())
+	}
+}
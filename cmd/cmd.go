@@ -0,0 +1,68 @@
+// 命令行子命令
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// 子命令结构
+type command struct {
+	Usage string                  // 用法说明
+	Run   func(args []string) int // 执行函数, 返回退出码
+}
+
+// 所有子命令
+var commands = map[string]command{}
+
+// 注册子命令
+func register(name, usage string, run func(args []string) int) {
+	commands[name] = command{Usage: usage, Run: run}
+}
+
+// 是否为子命令
+func Has(name string) bool {
+	_, ok := commands[name]
+	return ok
+}
+
+// 执行子命令
+func Run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return 0
+	}
+
+	c, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		usage()
+		return 2
+	}
+
+	return c.Run(args[1:])
+}
+
+// 输出用法说明
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: SCDht [command] [arguments]")
+	fmt.Fprintln(os.Stderr, "\nRun without a command to start the crawler and web server.\n\nCommands:")
+
+	// 按名称排序输出
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].Usage)
+	}
+}
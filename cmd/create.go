@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ylqjgm/SCDht/common"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func init() {
+	register("create", "create a .torrent file from a file or directory", runCreate)
+}
+
+// 可重复的字符串参数
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// 制作结果
+type createResult struct {
+	Path       string `json:"path"`                 // 种子文件路径
+	InfoHash   string `json:"infohash"`             // InfoHash
+	InfoHashV2 string `json:"infohashv2,omitempty"` // v2 InfoHash
+	Indexed    bool   `json:"indexed,omitempty"`    // 是否已入库
+}
+
+// create子命令
+func runCreate(args []string) int {
+	var trackers, webseeds listFlag
+
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	out := flags.String("o", "", "output .torrent path (default: <name>.torrent)")
+	name := flags.String("name", "", "torrent name (default: file or directory name)")
+	pieceLength := flags.Int64("piece-length", 0, "piece length in bytes, a power of two >= 16384 (default: automatic)")
+	format := flags.String("format", common.FormatV1, "torrent format: v1, v2 or hybrid")
+	flags.Var(&trackers, "t", "tracker tier, comma separated for several trackers in one tier (repeatable)")
+	flags.Var(&webseeds, "w", "web seed URL (repeatable)")
+	private := flags.Bool("private", false, "set the private flag")
+	source := flags.String("source", "", "source tag")
+	comment := flags.String("comment", "", "comment")
+	createdBy := flags.String("created-by", "SCDht", "created by")
+	noDate := flags.Bool("no-date", false, "omit the creation date")
+	index := flags.Bool("index", false, "also add the created torrent to the index")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: SCDht create [-o file] [-format v1|v2|hybrid] [-piece-length n] [-t tracker] [-w url] [-private] path")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	opts := common.CreateOptions{
+		Name:        *name,
+		PieceLength: *pieceLength,
+		Format:      *format,
+		WebSeeds:    webseeds,
+		Private:     *private,
+		Source:      *source,
+		Comment:     *comment,
+		CreatedBy:   *createdBy,
+		NoDate:      *noDate,
+	}
+	for _, tier := range trackers {
+		opts.Trackers = append(opts.Trackers, strings.Split(tier, ","))
+	}
+
+	data, err := common.CreateTorrent(flags.Arg(0), opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// 读取生成的种子, 确认可以正确解析
+	meta, err := common.ReadTorrent(bytes.NewReader(data))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	res := createResult{
+		Path:       *out,
+		InfoHash:   meta.InfoHash,
+		InfoHashV2: meta.InfoHashV2,
+	}
+	if res.Path == "" {
+		res.Path = filepath.Base(meta.Info.Name) + ".torrent"
+	}
+
+	if err = ioutil.WriteFile(res.Path, data, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *index {
+		// 初始化数据库
+		if err = models.Init(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer models.Close()
+
+		if err = common.PutTorrent(meta); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		common.SaveHash(meta.InfoHash, true)
+		res.Indexed = true
+	}
+
+	json.NewEncoder(os.Stdout).Encode(res)
+	return 0
+}
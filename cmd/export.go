@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ylqjgm/SCDht/common"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func init() {
+	register("export", "export torrents, hashes, searches and daily stats as compressed JSON lines", runExport)
+}
+
+// export子命令
+func runExport(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := flags.String("o", "-", "output file, - for stdout")
+	compress := flags.String("compress", "", "gzip, zstd or none, chosen by the output file extension (.gz, .zst) when empty")
+	types := flags.String("types", strings.Join(common.ArchiveTypes, ","), "comma separated data types to export")
+	from := flags.String("from", "", "first day to export, yyyymmdd")
+	to := flags.String("to", "", "last day to export, yyyymmdd")
+	since := flags.String("since", "", "only export data changed since this RFC3339 time, e.g. the exported time of the previous archive")
+	category := flags.String("category", "", "only export torrents of this category")
+	minHot := flags.Int64("minhot", 0, "only export torrents and hashes at least this hot")
+	batch := flags.Int("batch", 500, "number of records read per batch")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: SCDht export [-o file] [-compress gzip|zstd|none] [-types info,hash,search,log] [-from yyyymmdd] [-to yyyymmdd] [-since time] [-category name] [-minhot 0]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	filter, err := exportFilter(*types, *from, *to, *since)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	filter.Category = *category
+	filter.MinHot = *minHot
+
+	// 未指定压缩方式时按扩展名选择
+	if *compress == "" {
+		switch {
+		case strings.HasSuffix(*output, ".gz"):
+			*compress = common.CompressGzip
+		case strings.HasSuffix(*output, ".zst"):
+			*compress = common.CompressZstd
+		default:
+			*compress = common.CompressNone
+		}
+	}
+
+	// 初始化数据库
+	if err := models.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer models.Close()
+
+	f := os.Stdout
+	if *output != "-" {
+		if f, err = os.Create(*output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+	}
+
+	w, err := common.NewArchiveWriter(f, *compress)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	stats, err := common.Export(w, filter, *batch)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+
+	// 输出各类型的数量
+	json.NewEncoder(os.Stderr).Encode(stats)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// 解析导出条件, 日期包含起止日期, since晚于起始日期时以since为准
+func exportFilter(types, from, to, since string) (common.ExportFilter, error) {
+	var filter common.ExportFilter
+	for _, typ := range strings.Split(types, ",") {
+		typ = strings.TrimSpace(typ)
+		if typ == "" {
+			continue
+		}
+		if !validArchiveType(typ) {
+			return filter, fmt.Errorf("unknown type %q", typ)
+		}
+		filter.Types = append(filter.Types, typ)
+	}
+
+	if from != "" {
+		t, err := time.ParseInLocation("20060102", from, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("invalid -from %q", from)
+		}
+		filter.Since = t
+	}
+	if to != "" {
+		t, err := time.ParseInLocation("20060102", to, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("invalid -to %q", to)
+		}
+		filter.Until = t.AddDate(0, 0, 1)
+	}
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return filter, fmt.Errorf("invalid -since %q", since)
+		}
+		if t.After(filter.Since) {
+			filter.Since = t
+		}
+	}
+
+	return filter, nil
+}
+
+// 是否为支持的数据类型
+func validArchiveType(typ string) bool {
+	for _, t := range common.ArchiveTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
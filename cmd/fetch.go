@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ylqjgm/SCDht/common"
+	"github.com/ylqjgm/SCDht/magnet"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func init() {
+	register("fetch", "index infohashes, magnet links or .torrent files read from files or stdin", runFetch)
+}
+
+// 单条处理结果
+type fetchResult struct {
+	Input    string `json:"input"`              // 输入内容
+	InfoHash string `json:"infohash,omitempty"` // InfoHash
+	Status   string `json:"status"`             // 处理状态
+	Error    string `json:"error,omitempty"`    // 错误信息
+	Elapsed  int64  `json:"elapsed_ms"`         // 处理耗时, 单位毫秒
+}
+
+// 处理状态
+const (
+	fetchOK      = "ok"      // 入库成功
+	fetchSkipped = "skipped" // 已入库, 跳过
+	fetchQueued  = "queued"  // 已加入待入库队列
+	fetchFailed  = "failed"  // 入库失败
+	fetchInvalid = "invalid" // 无法识别的输入
+)
+
+// fetch子命令
+func runFetch(args []string) int {
+	flags := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	concurrency := flags.Int("c", 10, "number of concurrent fetches")
+	queue := flags.Bool("queue", false, "only queue infohashes for the crawler instead of fetching them now")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout for each torrent download")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: SCDht fetch [-c 10] [-queue] [-timeout 30s] [file ...]")
+		fmt.Fprintln(os.Stderr, "\nEach line is an infohash, a magnet link or a .torrent path. Reads stdin when no file is given.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+
+	// 初始化数据库
+	if err := models.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer models.Close()
+
+	// 输入通道
+	inputs := make(chan string)
+	// 输出结果
+	out := json.NewEncoder(os.Stdout)
+	var mu sync.Mutex
+	failed := false
+
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for input := range inputs {
+				res := fetchOne(input, *queue, *timeout)
+
+				mu.Lock()
+				out.Encode(res)
+				if res.Status == fetchFailed || res.Status == fetchInvalid {
+					failed = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// 读取输入
+	err := readInputs(flags.Args(), inputs)
+	close(inputs)
+	wg.Wait()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if failed {
+		return 1
+	}
+
+	return 0
+}
+
+// 从文件或标准输入逐行读取
+func readInputs(files []string, inputs chan<- string) error {
+	if len(files) == 0 {
+		return scanInputs(os.Stdin, inputs)
+	}
+
+	for _, name := range files {
+		if name == "-" {
+			if err := scanInputs(os.Stdin, inputs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = scanInputs(f, inputs)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 逐行扫描, 忽略空行与#开头的注释
+func scanInputs(r io.Reader, inputs chan<- string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		inputs <- line
+	}
+
+	return scanner.Err()
+}
+
+// 处理单条输入
+func fetchOne(input string, queue bool, timeout time.Duration) (res fetchResult) {
+	start := time.Now()
+	res.Input = input
+	defer func() {
+		res.Elapsed = int64(time.Since(start) / time.Millisecond)
+	}()
+
+	// 种子文件直接读取入库
+	if hash := parseHash(input); hash != "" {
+		res.InfoHash = hash
+	} else if strings.HasSuffix(strings.ToLower(input), ".torrent") {
+		return fetchFile(res, input)
+	} else {
+		res.Status = fetchInvalid
+		res.Error = "not an infohash, magnet link or .torrent file"
+		return
+	}
+
+	// 已入库则跳过
+	if models.Db.HasInfo(res.InfoHash) {
+		models.SetPut(res.InfoHash)
+		res.Status = fetchSkipped
+		return
+	}
+
+	// 保存hash数据, 由爬虫入库或记录下载失败次数
+	common.SaveHash(res.InfoHash, false)
+
+	if queue {
+		res.Status = fetchQueued
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ret, err := common.PullTorrentContext(ctx, res.InfoHash)
+	if err != nil || ret != 0 {
+		res.Status = fetchFailed
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Error = "torrent not found on any source"
+		}
+		return
+	}
+
+	res.Status = fetchOK
+	return
+}
+
+// 读取种子文件并入库
+func fetchFile(res fetchResult, path string) fetchResult {
+	meta, err := common.ReadTorrentFile(path)
+	if err != nil {
+		res.Status = fetchInvalid
+		res.Error = err.Error()
+		return res
+	}
+	res.InfoHash = strings.ToUpper(meta.InfoHash)
+
+	// 已入库则跳过
+	if models.Db.HasInfo(res.InfoHash) {
+		res.Status = fetchSkipped
+		return res
+	}
+
+	if err = common.PutTorrent(meta); err != nil {
+		res.Status = fetchFailed
+		res.Error = err.Error()
+		return res
+	}
+	common.SaveHash(res.InfoHash, true)
+
+	res.Status = fetchOK
+	return res
+}
+
+// 从infohash或磁力链接中获取大写的十六进制infohash
+func parseHash(input string) string {
+	if m, err := magnet.Parse(input); err == nil {
+		if m.InfoHash != "" {
+			return m.InfoHash
+		}
+		// 纯v2磁力链接使用截断的v2 infohash
+		return m.InfoHashV2[:40]
+	}
+
+	if hash, err := magnet.ParseHash(input); err == nil {
+		return hash
+	}
+
+	return ""
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+const (
+	testHash   = "C2B8034ADB94D5CFFD8F5406DB981CCA4DAB5AE1"
+	testHashV2 = "0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF"
+)
+
+// 使用内存存储, 并在临时目录中运行以免生成的二维码写入源码目录
+func useMemoryStore(t *testing.T) *models.MemoryStore {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	store := models.NewMemoryStore()
+	old, oldRead := models.Db, models.ReadDb
+	models.Db, models.ReadDb = store, store
+	t.Cleanup(func() {
+		models.Db, models.ReadDb = old, oldRead
+		os.Chdir(wd)
+	})
+	return store
+}
+
+func TestParseHash(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{testHash, testHash},
+		{"  c2b8034adb94d5cffd8f5406db981cca4dab5ae1  ", testHash},
+		{"YK4AGSW3STK477MPKQDNXGA4ZJG2WWXB", testHash},
+		{"magnet:?xt=urn:btih:" + testHash + "&dn=test", testHash},
+		// 混合磁力链接优先使用v1 infohash
+		{"magnet:?xt=urn:btih:" + testHash + "&xt=urn:btmh:1220" + testHashV2, testHash},
+		// 纯v2磁力链接使用截断的v2 infohash
+		{"magnet:?xt=urn:btmh:1220" + testHashV2, testHashV2[:40]},
+		{"magnet:?xt=urn:ed2k:31D6CFE0D16AE931B73C59D7E0C089C0", ""},
+		{"magnet:?xt=urn:btih:XYZ", ""},
+		{testHash[:39], ""},
+		{"movie.torrent", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := parseHash(tt.input); got != tt.want {
+			t.Errorf("parseHash(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFetchOne(t *testing.T) {
+	torrent, err := filepath.Abs("../common/testdata/v1multi.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := useMemoryStore(t)
+
+	tests := []struct {
+		input  string
+		status string
+		hash   string
+	}{
+		{"not a hash", fetchInvalid, ""},
+		{"missing.torrent", fetchInvalid, ""},
+		// 只加入待入库队列
+		{"magnet:?xt=urn:btmh:1220" + testHashV2, fetchQueued, testHashV2[:40]},
+		{torrent, fetchOK, testHash},
+		// 已入库的种子跳过
+		{torrent, fetchSkipped, testHash},
+		{testHash, fetchSkipped, testHash},
+	}
+
+	for _, tt := range tests {
+		res := fetchOne(tt.input, true, time.Second)
+		if res.Status != tt.status || res.InfoHash != tt.hash {
+			t.Errorf("fetchOne(%q) = %s %q (%s), want %s %q", tt.input, res.Status, res.InfoHash, res.Error, tt.status, tt.hash)
+		}
+	}
+
+	if _, ok := store.GetHash(testHashV2[:40]); !ok {
+		t.Error("queued hash not saved")
+	}
+	if h, ok := store.GetHash(testHash); !ok || !h.IsPut {
+		t.Errorf("imported hash = %+v, %v", h, ok)
+	}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ylqjgm/SCDht/common"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func init() {
+	register("import", "import archives written by export, skipping data that already exists", runImport)
+}
+
+// import子命令
+func runImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: SCDht import [file...]")
+		fmt.Fprintln(os.Stderr, "Reads stdin when no file is given, gzip and zstd archives are detected automatically.")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// 初始化数据库
+	if err := models.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer models.Close()
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	// 每个文件输出一行统计
+	out := json.NewEncoder(os.Stdout)
+	code := 0
+	for _, name := range files {
+		stats, err := importArchive(name)
+		out.Encode(stats)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// 导入单个文件
+func importArchive(name string) (common.ArchiveImportStats, error) {
+	var f io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return common.ArchiveImportStats{}, err
+		}
+		defer file.Close()
+		f = file
+	}
+
+	r, err := common.OpenArchive(f)
+	if err != nil {
+		return common.ArchiveImportStats{}, err
+	}
+	defer r.Close()
+
+	return common.Import(r)
+}
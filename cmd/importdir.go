@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ylqjgm/SCDht/common"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func init() {
+	register("importdir", "import all .torrent files under a directory, optionally watching for new ones", runImportDir)
+}
+
+// importdir子命令
+func runImportDir(args []string) int {
+	flags := flag.NewFlagSet("importdir", flag.ContinueOnError)
+	batch := flags.Int("batch", 100, "number of files ingested per batch")
+	watch := flags.Bool("watch", false, "keep watching the directory for newly added files")
+	interval := flags.Duration("interval", 10*time.Second, "polling interval in watch mode")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: SCDht importdir [-batch 100] [-watch] [-interval 10s] dir")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	// 初始化数据库
+	if err := models.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer models.Close()
+
+	// 每个文件输出一行结果
+	out := json.NewEncoder(os.Stdout)
+	im := common.NewImporter(flags.Arg(0), *batch)
+	im.Interval = *interval
+	im.Report = func(res common.ImportResult) {
+		out.Encode(res)
+	}
+
+	var err error
+	if *watch {
+		// 收到退出信号时停止监视
+		stop := make(chan struct{})
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sig
+			close(stop)
+		}()
+
+		err = im.Watch(stop)
+	} else {
+		err = im.Scan()
+	}
+
+	// 输出统计信息
+	stats := im.Stats()
+	fmt.Fprintf(os.Stderr, "ok: %d, skipped: %d, invalid: %d, failed: %d\n", stats.OK, stats.Skipped, stats.Invalid, stats.Failed)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if stats.Invalid > 0 || stats.Failed > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ylqjgm/SCDht/common"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func init() {
+	register("migrate", "run pending data migrations, resuming interrupted ones", runMigrate)
+}
+
+// migrate子命令
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	batch := flags.Int("batch", 500, "number of torrents converted per batch, progress is saved after each batch")
+	dryRun := flags.Bool("dry-run", false, "only count the torrents that would be converted, nothing is written")
+	list := flags.Bool("list", false, "list all migrations and their progress")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: SCDht migrate [-batch 500] [-dry-run] [-list]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	// 初始化数据库
+	if err := models.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer models.Close()
+
+	out := json.NewEncoder(os.Stdout)
+	m := common.NewMigrator(*batch)
+
+	// 每个迁移输出一行进度
+	if *list {
+		for _, meta := range m.List() {
+			out.Encode(meta)
+		}
+		return 0
+	}
+
+	// 每批处理完成后输出一行进度
+	m.DryRun = *dryRun
+	m.Report = func(progress common.MigrateProgress) {
+		out.Encode(progress)
+	}
+
+	if err := m.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ylqjgm/SCDht/common"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func init() {
+	register("prune", "delete unfetchable, cold or excess hashes according to the retention rules", runPrune)
+}
+
+// prune子命令
+func runPrune(args []string) int {
+	// 默认使用配置中的规则
+	rules := common.PruneRulesFromConfig()
+
+	flags := flag.NewFlagSet("prune", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report how many hashes would be deleted")
+	flags.IntVar(&rules.InvalidDays, "invalid-days", rules.InvalidDays, "delete hashes that failed more than 3 times and were last seen this many days ago, 0 to disable")
+	flags.IntVar(&rules.ColdDays, "cold-days", rules.ColdDays, "delete hashes seen only once and last seen this many days ago, 0 to disable")
+	flags.IntVar(&rules.MaxHashes, "max", rules.MaxHashes, "keep at most this many hashes, deleting the least recently seen unindexed ones, 0 to disable")
+	batch := flags.Int("batch", 1000, "number of hashes deleted per batch")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: SCDht prune [-dry-run] [-invalid-days n] [-cold-days n] [-max n] [-batch 1000]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	// 初始化数据库
+	if err := models.Init(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer models.Close()
+
+	result, err := common.Prune(rules, *dryRun, *batch)
+	json.NewEncoder(os.Stdout).Encode(result)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,324 @@
+// 索引数据导出与导入
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 导出文件格式名称与版本, 格式不兼容时增加版本
+const (
+	ArchiveFormat  = "scdht"
+	ArchiveVersion = 1
+)
+
+// 导出的数据类型
+const (
+	ArchiveInfo   = "info"   // 种子, SC_Info
+	ArchiveHash   = "hash"   // hash, SC_Hash
+	ArchiveSearch = "search" // 搜索, SC_Search
+	ArchiveLog    = "log"    // 每日统计, SC_Log
+)
+
+// 所有数据类型, 按导出顺序排列
+var ArchiveTypes = []string{ArchiveInfo, ArchiveHash, ArchiveSearch, ArchiveLog}
+
+// 压缩方式
+const (
+	CompressNone = "none"
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// 不是导出文件或版本过高
+var ErrArchive = errors.New("archive: unsupported file")
+
+// 导出条件, 零值字段不参与过滤
+type ExportFilter struct {
+	Types    []string  `json:"types"`              // 导出的数据类型, 为空时导出全部
+	Since    time.Time `json:"since"`              // 种子入库, hash最后获取, 搜索时间及统计日期不早于此时间
+	Until    time.Time `json:"until"`              // 同上, 早于此时间
+	Category string    `json:"category,omitempty"` // 只导出此分类的种子
+	MinHot   int64     `json:"minhot,omitempty"`   // 只导出热度不低于此值的种子与hash
+}
+
+// 导出文件的第一行
+type ArchiveHeader struct {
+	Format   string       `json:"format"`   // 固定为scdht
+	Version  int          `json:"version"`  // 格式版本
+	Exported time.Time    `json:"exported"` // 开始导出的时间, 可作为下次增量导出的起始时间
+	Filter   ExportFilter `json:"filter"`   // 导出条件
+}
+
+// 导出文件中的一条数据
+type ArchiveRecord struct {
+	Type string          `json:"type"` // 数据类型
+	Data json.RawMessage `json:"data"` // 数据内容
+}
+
+// 各类型的数量
+type ArchiveStats map[string]int
+
+// 导入统计
+type ArchiveImportStats struct {
+	Header   ArchiveHeader `json:"header"`   // 导出文件信息
+	Imported ArchiveStats  `json:"imported"` // 新增的数量
+	Skipped  ArchiveStats  `json:"skipped"`  // 已存在而跳过的数量
+}
+
+// 按压缩方式包装输出
+func NewArchiveWriter(w io.Writer, compress string) (io.WriteCloser, error) {
+	switch compress {
+	case CompressGzip:
+		return gzip.NewWriter(w), nil
+	case CompressZstd:
+		return zstd.NewWriter(w)
+	case "", CompressNone:
+		return nopWriteCloser{w}, nil
+	}
+
+	return nil, fmt.Errorf("archive: unknown compression %q", compress)
+}
+
+// 不需要关闭的输出
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// 根据文件头自动识别压缩方式并解压
+func OpenArchive(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(4)
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		d, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+
+	return io.NopCloser(br), nil
+}
+
+// 按条件导出数据, 每行一条JSON, 第一行为文件信息
+func Export(w io.Writer, filter ExportFilter, batch int) (ArchiveStats, error) {
+	if batch < 1 {
+		batch = 500
+	}
+	if len(filter.Types) == 0 {
+		filter.Types = ArchiveTypes
+	}
+
+	out := json.NewEncoder(w)
+	stats := ArchiveStats{}
+	if err := out.Encode(ArchiveHeader{Format: ArchiveFormat, Version: ArchiveVersion, Exported: time.Now(), Filter: filter}); err != nil {
+		return stats, err
+	}
+
+	// 写入一条数据
+	write := func(typ string, data interface{}) error {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		stats[typ]++
+		return out.Encode(ArchiveRecord{Type: typ, Data: raw})
+	}
+
+	for _, typ := range filter.Types {
+		var err error
+		switch typ {
+		case ArchiveInfo:
+			err = exportInfos(filter, batch, write)
+		case ArchiveHash:
+			err = exportHashes(filter, batch, write)
+		case ArchiveSearch:
+			err = exportSearches(filter, batch, write)
+		case ArchiveLog:
+			err = exportLogs(filter, write)
+		default:
+			err = fmt.Errorf("archive: unknown type %q", typ)
+		}
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// 按编号顺序分批导出种子
+func exportInfos(filter ExportFilter, batch int, write func(string, interface{}) error) error {
+	query := models.InfoQuery{Category: filter.Category, Since: filter.Since, Until: filter.Until, MinHot: filter.MinHot}
+	for {
+		infos := models.Db.FindInfos(query, 0, batch, "_id")
+		if len(infos) == 0 {
+			return nil
+		}
+		for i := range infos {
+			if err := write(ArchiveInfo, &infos[i]); err != nil {
+				return err
+			}
+		}
+		query.After = infos[len(infos)-1].Id
+	}
+}
+
+// 按编号顺序分批导出hash
+func exportHashes(filter ExportFilter, batch int, write func(string, interface{}) error) error {
+	query := models.HashQuery{Since: filter.Since, Until: filter.Until, MinHot: filter.MinHot}
+	for {
+		hashes := models.Db.ScanHashes(query, batch)
+		if len(hashes) == 0 {
+			return nil
+		}
+		for i := range hashes {
+			if err := write(ArchiveHash, &hashes[i]); err != nil {
+				return err
+			}
+		}
+		query.After = hashes[len(hashes)-1].Id
+	}
+}
+
+// 按编号顺序分批导出搜索
+func exportSearches(filter ExportFilter, batch int, write func(string, interface{}) error) error {
+	query := models.SearchQuery{Since: filter.Since, Until: filter.Until}
+	for {
+		searches := models.Db.ScanSearches(query, batch)
+		if len(searches) == 0 {
+			return nil
+		}
+		for i := range searches {
+			if err := write(ArchiveSearch, &searches[i]); err != nil {
+				return err
+			}
+		}
+		query.After = searches[len(searches)-1].Id
+	}
+}
+
+// 导出日期范围内的每日统计, 包含起止时间所在的日期
+func exportLogs(filter ExportFilter, write func(string, interface{}) error) error {
+	from, to := "00000000", "99999999"
+	if !filter.Since.IsZero() {
+		from = models.LogDay(filter.Since.Local())
+	}
+	if !filter.Until.IsZero() {
+		to = models.LogDay(filter.Until.Local().Add(-time.Nanosecond))
+	}
+
+	for _, sclog := range models.Db.FindLogs(from, to) {
+		if err := write(ArchiveLog, &sclog); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 导入导出文件, 已存在的数据跳过, 重复导入同一文件不会改变数据
+func Import(r io.Reader) (ArchiveImportStats, error) {
+	stats := ArchiveImportStats{Imported: ArchiveStats{}, Skipped: ArchiveStats{}}
+	in := json.NewDecoder(r)
+
+	// 检查文件信息
+	if err := in.Decode(&stats.Header); err != nil {
+		return stats, ErrArchive
+	}
+	if stats.Header.Format != ArchiveFormat || stats.Header.Version < 1 || stats.Header.Version > ArchiveVersion {
+		return stats, fmt.Errorf("%v: format %q version %d", ErrArchive, stats.Header.Format, stats.Header.Version)
+	}
+
+	for {
+		var record ArchiveRecord
+		if err := in.Decode(&record); err == io.EOF {
+			return stats, nil
+		} else if err != nil {
+			return stats, err
+		}
+
+		isNew, err := importRecord(record)
+		if err != nil {
+			return stats, err
+		}
+		if isNew {
+			stats.Imported[record.Type]++
+		} else {
+			stats.Skipped[record.Type]++
+		}
+	}
+}
+
+// 导入一条数据, 返回是否新增, 不认识的类型跳过
+func importRecord(record ArchiveRecord) (bool, error) {
+	switch record.Type {
+	case ArchiveInfo:
+		var scinfo models.SC_Info
+		if err := json.Unmarshal(record.Data, &scinfo); err != nil {
+			return false, err
+		}
+		if scinfo.InfoHash == "" {
+			return false, nil
+		}
+		isNew, err := models.Db.ImportInfo(&scinfo)
+		if err == nil && isNew {
+			// 本地等待入库的hash不再下载
+			models.Db.SetPut(scinfo.InfoHash)
+			models.SeedSwarm(scinfo.InfoHash)
+			models.RefreshGroup(scinfo.Fingerprint)
+		}
+		return isNew, err
+	case ArchiveHash:
+		var schash models.SC_Hash
+		if err := json.Unmarshal(record.Data, &schash); err != nil {
+			return false, err
+		}
+		if schash.InfoHash == "" {
+			return false, nil
+		}
+		isNew, err := models.Db.ImportHash(&schash)
+		// 种子先于hash导入时补充获取历史的首次获取时间
+		if err == nil && isNew && models.Db.HasInfo(schash.InfoHash) {
+			models.SeedSwarm(schash.InfoHash)
+		}
+		return isNew, err
+	case ArchiveSearch:
+		var scsearch models.SC_Search
+		if err := json.Unmarshal(record.Data, &scsearch); err != nil {
+			return false, err
+		}
+		if scsearch.Caption == "" {
+			return false, nil
+		}
+		return models.Db.ImportSearch(&scsearch)
+	case ArchiveLog:
+		var sclog models.SC_Log
+		if err := json.Unmarshal(record.Data, &sclog); err != nil {
+			return false, err
+		}
+		if sclog.Day == "" {
+			return false, nil
+		}
+		return models.Db.ImportLog(&sclog)
+	}
+
+	return false, nil
+}
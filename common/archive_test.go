@@ -0,0 +1,129 @@
+package common
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	store := useMemoryStore(t)
+	// GBK编码的原始名称与路径
+	raw := [][]byte{[]byte("\xd6\xd0\xce\xc4"), []byte("\xce\xc4\xbc\xfe.txt")}
+	store.SaveInfo(&models.SC_Info{
+		InfoHash:   "C2B8034ADB94D5CFFD8F5406DB981CCA4DAB5AE1",
+		Caption:    "中文",
+		RawCaption: []byte("\xd6\xd0\xce\xc4"),
+		Files: []models.File{
+			{Path: "a.txt", Parts: []string{"a.txt"}, Length: 1},
+			{Path: "中文/文件.txt", Parts: []string{"中文", "文件.txt"}, Length: 2, Raw: raw},
+		},
+	})
+
+	var buf bytes.Buffer
+	stats, err := Export(&buf, ExportFilter{Types: []string{ArchiveInfo}}, 0)
+	if err != nil || stats[ArchiveInfo] != 1 {
+		t.Fatalf("export: %v, %v", stats, err)
+	}
+
+	imported := useMemoryStore(t)
+	if _, err := Import(&buf); err != nil {
+		t.Fatal(err)
+	}
+	scinfo, ok := imported.GetInfo("C2B8034ADB94D5CFFD8F5406DB981CCA4DAB5AE1")
+	if !ok {
+		t.Fatal("info not imported")
+	}
+	if !bytes.Equal(scinfo.RawCaption, []byte("\xd6\xd0\xce\xc4")) {
+		t.Errorf("RawCaption = %q", scinfo.RawCaption)
+	}
+	if len(scinfo.Files) != 2 || scinfo.Files[0].Raw != nil || !reflect.DeepEqual(scinfo.Files[1].Raw, raw) {
+		t.Errorf("Files = %+v", scinfo.Files)
+	}
+}
+
+// 写入各类型的测试数据
+func saveArchiveData(store *models.MemoryStore) {
+	now := time.Now()
+	store.SaveInfo(&models.SC_Info{InfoHash: "A1", Caption: "first", Category: MediaVideo, PutTime: now})
+	store.SaveInfo(&models.SC_Info{InfoHash: "B2", Caption: "second", Category: MediaAudio, PutTime: now})
+	for _, hash := range []string{"A1", "C3", "D4"} {
+		store.ImportHash(&models.SC_Hash{InfoHash: hash, Hot: 2, IsPut: hash == "A1", CreateTime: now, LastSeen: now})
+	}
+	store.SaveSearch(&models.SC_Search{Caption: "first", SearchTime: now, Count: 1})
+	// 导入种子时会写入当天的统计, 测试数据使用过去的日期
+	store.ImportLog(&models.SC_Log{Day: "20200101", LogCounts: models.LogCounts{PutNums: 2, SearchNums: 1}})
+}
+
+func TestArchiveCompressedReimport(t *testing.T) {
+	want := ArchiveStats{ArchiveInfo: 2, ArchiveHash: 3, ArchiveSearch: 1, ArchiveLog: 1}
+	magic := map[string][]byte{
+		CompressNone: []byte("{"),
+		CompressGzip: {0x1f, 0x8b},
+		CompressZstd: {0x28, 0xb5, 0x2f, 0xfd},
+	}
+
+	for _, compress := range []string{CompressNone, CompressGzip, CompressZstd} {
+		saveArchiveData(useMemoryStore(t))
+
+		var buf bytes.Buffer
+		w, err := NewArchiveWriter(&buf, compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := Export(w, ExportFilter{}, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(stats, want) {
+			t.Errorf("%s: export stats %v, want %v", compress, stats, want)
+		}
+		if !bytes.HasPrefix(buf.Bytes(), magic[compress]) {
+			t.Errorf("%s: archive starts with % x", compress, buf.Bytes()[:4])
+		}
+
+		// 导入到空的存储, 再次导入时全部跳过
+		store := useMemoryStore(t)
+		inTempDir(t)
+		data := buf.Bytes()
+		for i, wantImported := range []ArchiveStats{want, {}} {
+			r, err := OpenArchive(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := Import(r)
+			r.Close()
+			if err != nil {
+				t.Fatalf("%s: import %d: %v", compress, i, err)
+			}
+
+			wantSkipped := ArchiveStats{}
+			if i > 0 {
+				wantSkipped = want
+			}
+			if !reflect.DeepEqual(res.Imported, wantImported) || !reflect.DeepEqual(res.Skipped, wantSkipped) {
+				t.Errorf("%s: import %d: imported %v, skipped %v", compress, i, res.Imported, res.Skipped)
+			}
+		}
+
+		// 重复导入不改变数据
+		if n := store.CountInfos(models.InfoQuery{}); n != 2 {
+			t.Errorf("%s: %d infos", compress, n)
+		}
+		if n := store.CountHashes(models.HashQuery{Pending: true}); n != 2 {
+			t.Errorf("%s: %d pending hashes", compress, n)
+		}
+		if h, _ := store.GetHash("A1"); h.Hot != 2 || !h.IsPut {
+			t.Errorf("%s: hash A1 = %+v", compress, h)
+		}
+		if sclog := store.GetLog("20200101"); sclog.PutNums != 2 || sclog.SearchNums != 1 {
+			t.Errorf("%s: log = %+v", compress, sclog)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// 熔断器
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	BreakerClosed   = iota // 关闭, 正常请求
+	BreakerOpen            // 打开, 跳过请求
+	BreakerHalfOpen        // 半开, 允许单个探测请求
+)
+
+// 熔断器结构
+type Breaker struct {
+	mu        sync.Mutex
+	window    int           // 统计最近请求的数量
+	rate      float64       // 打开熔断的错误率
+	min       int           // 计算错误率所需的最少请求数
+	cooldown  time.Duration // 打开后多久进入半开状态
+	state     int           // 当前状态
+	results   []bool        // 最近请求结果, true为失败
+	next      int           // 下一个写入位置
+	probing   bool          // 是否有探测请求正在进行
+	openedAt  time.Time     // 打开时间
+	changedAt time.Time     // 状态变化时间
+	requests  int64         // 请求总数
+	failures  int64         // 失败总数
+	skipped   int64         // 跳过总数
+	lastError string        // 最后一次错误信息
+}
+
+// 熔断器状态信息
+type BreakerStatus struct {
+	State     string    `json:"state"`      // 当前状态
+	ErrorRate float64   `json:"error_rate"` // 最近错误率
+	Requests  int64     `json:"requests"`   // 请求总数
+	Failures  int64     `json:"failures"`   // 失败总数
+	Skipped   int64     `json:"skipped"`    // 跳过总数
+	LastError string    `json:"last_error"` // 最后一次错误信息
+	ChangedAt time.Time `json:"changed_at"` // 状态变化时间
+}
+
+// 创建熔断器
+func NewBreaker(window int, rate float64, min int, cooldown time.Duration) *Breaker {
+	if window < 1 {
+		window = 1
+	}
+	if min > window {
+		min = window
+	}
+
+	return &Breaker{
+		window:    window,
+		rate:      rate,
+		min:       min,
+		cooldown:  cooldown,
+		changedAt: time.Now(),
+	}
+}
+
+// 是否允许请求
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		// 冷却时间已过则进入半开状态, 放行一个探测请求
+		if time.Since(b.openedAt) >= b.cooldown {
+			b.setState(BreakerHalfOpen)
+			b.probing = true
+			return true
+		}
+	case BreakerHalfOpen:
+		// 同一时间只允许一个探测请求
+		if !b.probing {
+			b.probing = true
+			return true
+		}
+	default:
+		return true
+	}
+
+	b.skipped++
+	return false
+}
+
+// 记录请求结果, err为nil表示成功
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.requests++
+	failed := err != nil
+	if failed {
+		b.failures++
+		b.lastError = err.Error()
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probing = false
+		if failed {
+			// 探测失败重新打开
+			b.open()
+		} else {
+			// 探测成功则恢复, 清空之前的统计
+			b.reset()
+			b.setState(BreakerClosed)
+		}
+	case BreakerClosed:
+		b.push(failed)
+		if b.count() >= b.min && b.errorRate() >= b.rate {
+			b.open()
+		}
+	}
+}
+
+// 放弃本次请求(如任务被取消), 不计入统计
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+}
+
+// 获取状态信息
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return BreakerStatus{
+		State:     BreakerStateName(b.state),
+		ErrorRate: b.errorRate(),
+		Requests:  b.requests,
+		Failures:  b.failures,
+		Skipped:   b.skipped,
+		LastError: b.lastError,
+		ChangedAt: b.changedAt,
+	}
+}
+
+// 状态名称
+func BreakerStateName(state int) string {
+	switch state {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+
+	return "closed"
+}
+
+// 打开熔断
+func (b *Breaker) open() {
+	b.openedAt = time.Now()
+	b.setState(BreakerOpen)
+}
+
+// 设置状态
+func (b *Breaker) setState(state int) {
+	if b.state != state {
+		b.state = state
+		b.changedAt = time.Now()
+	}
+}
+
+// 记录一次结果
+func (b *Breaker) push(failed bool) {
+	if len(b.results) < b.window {
+		b.results = append(b.results, failed)
+		return
+	}
+
+	b.results[b.next] = failed
+	b.next = (b.next + 1) % b.window
+}
+
+// 清空最近结果
+func (b *Breaker) reset() {
+	b.results = b.results[:0]
+	b.next = 0
+}
+
+// 最近结果数量
+func (b *Breaker) count() int {
+	return len(b.results)
+}
+
+// 最近错误率
+func (b *Breaker) errorRate() float64 {
+	if len(b.results) == 0 {
+		return 0
+	}
+
+	failed := 0
+	for _, f := range b.results {
+		if f {
+			failed++
+		}
+	}
+
+	return float64(failed) / float64(len(b.results))
+}
@@ -0,0 +1,110 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	fail := errors.New("fail")
+
+	tests := []struct {
+		name    string
+		results []error // 依次记录的请求结果
+		cooled  bool    // 记录后冷却时间是否已过
+		probe   error   // 半开状态下探测请求的结果
+		want    []int   // 每一步之后的状态
+	}{
+		{
+			name:    "below threshold stays closed",
+			results: []error{fail, nil, nil, fail},
+			want:    []int{BreakerClosed, BreakerClosed, BreakerClosed, BreakerClosed},
+		},
+		{
+			name:    "opens at failure threshold",
+			results: []error{nil, fail, fail, fail},
+			want:    []int{BreakerClosed, BreakerClosed, BreakerClosed, BreakerOpen, BreakerOpen},
+		},
+		{
+			name:    "stays open during cooldown",
+			results: []error{fail, fail, fail},
+			want:    []int{BreakerClosed, BreakerClosed, BreakerOpen, BreakerOpen},
+		},
+		{
+			name:    "probe success closes",
+			results: []error{fail, fail, fail},
+			cooled:  true,
+			probe:   nil,
+			want:    []int{BreakerClosed, BreakerClosed, BreakerOpen, BreakerHalfOpen, BreakerClosed},
+		},
+		{
+			name:    "probe failure reopens",
+			results: []error{fail, fail, fail},
+			cooled:  true,
+			probe:   fail,
+			want:    []int{BreakerClosed, BreakerClosed, BreakerOpen, BreakerHalfOpen, BreakerOpen},
+		},
+	}
+
+	for _, tt := range tests {
+		// 最近4次请求中至少3次后计算错误率, 错误率达到0.75时打开
+		b := NewBreaker(4, 0.75, 3, time.Hour)
+		var got []int
+		for _, err := range tt.results {
+			if !b.Allow() {
+				t.Fatalf("%s: request rejected while closed", tt.name)
+			}
+			b.Record(err)
+			got = append(got, b.state)
+		}
+
+		if b.state == BreakerOpen {
+			if tt.cooled {
+				b.openedAt = time.Now().Add(-2 * time.Hour)
+			}
+			// 冷却期间跳过请求, 冷却后放行一个探测请求
+			allowed := b.Allow()
+			if allowed != tt.cooled {
+				t.Errorf("%s: Allow() = %v after open", tt.name, allowed)
+			}
+			got = append(got, b.state)
+			if allowed {
+				if b.Allow() {
+					t.Errorf("%s: second probe allowed while half-open", tt.name)
+				}
+				b.Record(tt.probe)
+				got = append(got, b.state)
+			}
+		}
+
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: states %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: states %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestBreakerRelease(t *testing.T) {
+	b := NewBreaker(2, 0.5, 1, 0)
+	b.Record(errors.New("fail"))
+	if !b.Allow() || b.state != BreakerHalfOpen {
+		t.Fatalf("state %s after cooldown", BreakerStateName(b.state))
+	}
+
+	// 取消的探测请求不计入统计, 允许新的探测
+	b.Release()
+	if !b.Allow() {
+		t.Error("probe not allowed after release")
+	}
+	b.Record(nil)
+	if s := b.Status(); s.State != "closed" || s.Requests != 2 || s.Failures != 1 || s.ErrorRate != 0 {
+		t.Errorf("status = %+v", s)
+	}
+}
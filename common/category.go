@@ -0,0 +1,97 @@
+// 种子内容分类
+package common
+
+import (
+	"regexp"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 游戏分类, 其余分类与媒体类型相同
+const CategoryGame = "game"
+
+// 所有分类, 按显示顺序排列
+var Categories = []string{MediaVideo, MediaAudio, MediaSoftware, MediaEbook, MediaImage, MediaArchive, CategoryGame, MediaOther}
+
+// 标题特征, 命中时为对应分类加分
+var captionRules = []struct {
+	category string         // 分类
+	re       *regexp.Regexp // 标题特征
+	weight   float64        // 加分, 文件大小占比为1
+}{
+	// 分辨率, 视频编码, 剧集编号及片源
+	{MediaVideo, regexp.MustCompile(`(?i)\b(480p|576p|720p|1080[pi]|2160p|4k|uhd|x26[45]|h\.?26[45]|hevc|avc|xvid|divx|s\d{1,2}e\d{1,3}|s\d{1,2}|season|bluray|blu-ray|bdrip|brrip|web-?dl|webrip|hdtv|dvdrip|hdrip|remux)\b`), 0.6},
+	{MediaVideo, regexp.MustCompile(`第\s*[0-9一二三四五六七八九十百]+\s*[集话話季]`), 0.6},
+	// 音频格式及专辑
+	{MediaAudio, regexp.MustCompile(`(?i)\b(flac|mp3|ape|aac|alac|320\s?kbps|\d{2,3}kbps|24bit|16bit|lossless|discography|album|ost|soundtrack|single|vinyl)\b`), 0.5},
+	{MediaAudio, regexp.MustCompile(`专辑|專輯|原声|原聲|无损|無損`), 0.5},
+	// 游戏发布组及平台
+	{CategoryGame, regexp.MustCompile(`(?i)\b(repack|fitgirl|dodi|codex|skidrow|reloaded|plaza|cpy|empress|razor1911|flt|tenoke|gog|ps[2345]|psp|nsw|switch|xbox|wii|nds|3ds|steam ?rip)\b`), 0.8},
+	{CategoryGame, regexp.MustCompile(`游戏|遊戲|ゲーム`), 0.8},
+	// 软件
+	{MediaSoftware, regexp.MustCompile(`(?i)\b(x64|x86|win(dows)?|macos|linux|portable|keygen|crack|patch|setup|installer|v\d+\.\d+(\.\d+)*|multilingual|pre-?activated)\b`), 0.4},
+	// 电子书
+	{MediaEbook, regexp.MustCompile(`(?i)\b(epub|mobi|azw3?|pdf|ebook|e-book|comics?|manga|magazine)\b`), 0.6},
+	{MediaEbook, regexp.MustCompile(`电子书|電子書|漫画|漫畫|杂志|雜誌|小说|小說`), 0.6},
+}
+
+// 根据标题与文件列表对种子分类
+func Classify(caption string, files []models.File) string {
+	scores := make(map[string]float64)
+
+	// 按文件大小计算各类型占比
+	var total int64
+	for _, f := range files {
+		if !f.IsPadding() {
+			total += f.Length
+		}
+	}
+	for _, f := range files {
+		if f.IsPadding() || total == 0 {
+			continue
+		}
+		media := f.Media
+		if media == "" {
+			media = FileMedia(FileExt(f.Path))
+		}
+		scores[media] += float64(f.Length) / float64(total)
+	}
+
+	// 标题特征加分, 同一分类只计算一次
+	hit := make(map[string]bool)
+	for _, rule := range captionRules {
+		if !hit[rule.category] && rule.re.MatchString(caption) {
+			hit[rule.category] = true
+			scores[rule.category] += rule.weight
+		}
+	}
+
+	// 安装包或压缩包且标题像游戏时归为游戏
+	if hit[CategoryGame] {
+		scores[CategoryGame] += scores[MediaSoftware] + scores[MediaArchive]
+		scores[MediaSoftware], scores[MediaArchive] = 0, 0
+	}
+
+	// 其它类型的文件只作为兜底
+	scores[MediaOther] *= 0.5
+
+	best, bestScore := MediaOther, 0.0
+	for _, category := range Categories {
+		if scores[category] > bestScore {
+			best, bestScore = category, scores[category]
+		}
+	}
+
+	return best
+}
+
+// 是否为有效的分类
+func IsCategory(category string) bool {
+	for _, c := range Categories {
+		if c == category {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,134 @@
+package common
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 生成文件列表, 参数为交替的路径与长度
+func classifyFiles(args ...interface{}) []models.File {
+	var files []models.File
+	for i := 0; i+1 < len(args); i += 2 {
+		path := args[i].(string)
+		attr := ""
+		if strings.Contains(path, "_____padding_file_") || strings.Contains(path, ".pad/") {
+			attr = "p"
+		}
+		files = append(files, NewFile(strings.Split(path, "/"), int64(args[i+1].(int)), attr))
+	}
+	return files
+}
+
+const mb = 1 << 20
+
+func TestClassify(t *testing.T) {
+	// 扩展名表在init中生成, 测试数据不能作为包级变量初始化
+	tests := []struct {
+		caption string
+		files   []models.File
+		want    string
+	}{
+		// 电影, 附带样片与说明
+		{"Blade.Runner.2049.2017.1080p.BluRay.x264-SPARKS", classifyFiles(
+			"Blade.Runner.2049.2017.1080p.BluRay.x264-SPARKS/blade.runner.2049.mkv", 10900*mb,
+			"Blade.Runner.2049.2017.1080p.BluRay.x264-SPARKS/Sample/sample.mkv", 60*mb,
+			"Blade.Runner.2049.2017.1080p.BluRay.x264-SPARKS/sparks.nfo", 4096,
+		), MediaVideo},
+		// 中文剧集
+		{"庆余年 第01-46集 全集 1080p", classifyFiles(
+			"庆余年/第01集.mp4", 800*mb,
+			"庆余年/第02集.mp4", 790*mb,
+			"庆余年/说明.txt", 1024,
+		), MediaVideo},
+		// 填充文件不参与计算
+		{"某电影", classifyFiles(
+			"某电影/movie.mp4", 700*mb,
+			"某电影/_____padding_file_0_如果您看到此文件，请升级到BitComet(比特彗星)0.85或以上版本____", 900*mb,
+		), MediaVideo},
+		// 无损专辑, 附带封面与cue
+		{"Pink Floyd - The Dark Side of the Moon (1973) [FLAC 24bit]", classifyFiles(
+			"Pink Floyd - The Dark Side of the Moon/01 - Speak to Me.flac", 30*mb,
+			"Pink Floyd - The Dark Side of the Moon/02 - Breathe.flac", 60*mb,
+			"Pink Floyd - The Dark Side of the Moon/cover.jpg", 40*mb,
+			"Pink Floyd - The Dark Side of the Moon/album.cue", 2048,
+		), MediaAudio},
+		{"周杰伦 - 范特西 专辑 320K", classifyFiles(
+			"周杰伦 - 范特西/01 爱在西元前.mp3", 10*mb,
+			"周杰伦 - 范特西/02 爸我回来了.mp3", 9*mb,
+		), MediaAudio},
+		// 游戏重打包, 文件为安装程序与数据包
+		{"Cyberpunk 2077 [FitGirl Repack]", classifyFiles(
+			"Cyberpunk 2077 [FitGirl Repack]/setup.exe", 5*mb,
+			"Cyberpunk 2077 [FitGirl Repack]/fg-01.bin", 4000*mb,
+			"Cyberpunk 2077 [FitGirl Repack]/fg-02.bin", 4000*mb,
+		), CategoryGame},
+		{"The Legend of Zelda Tears of the Kingdom [NSW]", classifyFiles(
+			"Zelda.nsp", 16000*mb,
+		), CategoryGame},
+		// 软件
+		{"Adobe Photoshop 2024 v25.0 x64 Multilingual", classifyFiles(
+			"Adobe Photoshop 2024/Set-up.exe", 3*mb,
+			"Adobe Photoshop 2024/packages/core.zip", 2*mb,
+			"Adobe Photoshop 2024/readme.txt", 1024,
+		), MediaSoftware},
+		// 光盘镜像
+		{"ubuntu-22.04.3-desktop-amd64.iso", classifyFiles(
+			"ubuntu-22.04.3-desktop-amd64.iso", 4800*mb,
+		), MediaArchive},
+		// 电子书合集
+		{"Programming Books Collection (EPUB, PDF)", classifyFiles(
+			"Books/The Go Programming Language.epub", 5*mb,
+			"Books/SICP.pdf", 8*mb,
+			"Books/cover.jpg", 1*mb,
+		), MediaEbook},
+		// 图片集
+		{"Wallpapers 4K Collection", classifyFiles(
+			"Wallpapers/001.jpg", 8*mb,
+			"Wallpapers/002.png", 12*mb,
+			"Wallpapers/003.jpg", 9*mb,
+		), MediaImage},
+		// 无法识别
+		{"data", classifyFiles("data/file.dat", 100*mb), MediaOther},
+		{"", nil, MediaOther},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(tt.caption, tt.files); got != tt.want {
+			t.Errorf("Classify(%q) = %q, want %q", tt.caption, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyTorrentFixtures(t *testing.T) {
+	store := useMemoryStore(t)
+	tests := map[string]string{
+		"v1multi.torrent":  MediaAudio,
+		"v1single.torrent": MediaVideo,
+		"v2.torrent":       MediaArchive,
+		"hybrid.torrent":   MediaArchive,
+		"gbk.torrent":      MediaVideo,
+	}
+	metas := make(map[string]MetaInfo)
+	for name := range tests {
+		meta, err := ReadTorrent(bytes.NewReader(readFixture(t, name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		metas[name] = meta
+	}
+	inTempDir(t)
+
+	// 入库时根据种子名称与文件列表分类
+	for name, want := range tests {
+		if err := PutTorrent(metas[name]); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := store.GetInfo(strings.ToUpper(metas[name].InfoHash))
+		if info.Category != want {
+			t.Errorf("%s: category %q of %q %+v, want %q", name, info.Category, info.Caption, info.Files, want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// 字符集检测与转换
+package common
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// 字符集候选
+type charsetCandidate struct {
+	name string            // 字符集名称
+	enc  encoding.Encoding // 编码对象
+}
+
+// 统计检测时依次尝试的字符集
+var charsetCandidates = []charsetCandidate{
+	{"GBK", simplifiedchinese.GBK},
+	{"Big5", traditionalchinese.Big5},
+	{"Shift_JIS", japanese.ShiftJIS},
+	{"EUC-KR", korean.EUCKR},
+	{"EUC-JP", japanese.EUCJP},
+}
+
+// 高频汉字(简体与繁体), 错误解码的结果中很少出现
+const commonHan = "的一是了我不人在他有这个上们来到时大地为子中你说生国年着就那和要她出也得里后自以会家可下而过天去能对小多然于心学么之都好看起发当没成只如事把还用第样道想作种开美总从无情己面最女但现前些所同日手又行意动方期它头经长儿回位分爱老因很给名法间斯知世什两次使身者被高已亲其进此话常与活正感见明问力理尔点文几定本公特做外孩相西果走将月十实向声车全信重三机工物气每并别真打太新比才便夫再书部水像眼等体却加电主界门利海受听表德少克代员许先口由死安写性马光白或住难望教命花结乐色更拉东神记处让母父应直字场平报友关放至张认接告入笑内英军候民岁往何度山觉路带万男边风解叫任金快原吃妈变通师立象数四失满战远格士音轻目条呢這個們來時為說國過發後會經對麼學當沒從無現與種開總門問點親聽還樣讓進話見長電書車東條關頭實視體愛處應戰邊帶變師軍氣萬兒將記樂員認陽聲買賣難雙顯歡機華劇場隊陸劃區廣產業動畫集語錄流浪球红楼梦龙虎卧传狼津湖药城市角号练习曲蓝阳照返校霸姬赛巴莱谷姫鬼灭刃侍罗剧季篇版语幕简繁双粤台港韩俄泰印片影视频唱专辑清整删减导剪收藏纪录漫游戏软件系统破汉绿龍傳紅樓夢臥藥馬鳥魚鬥戀讀寫線網頁導演戲綜藝節紀遊軟統漢綠藍練習賽號灣臺韓簡粵"
+
+// 高频韩文音节, 错误解码得到的韩文多为很少使用的音节
+const commonHangul = "이의다는에하고을를가지기사한리대로서도자어인정수아시일나상전부그해국적으보주게제들있소장우만여라면구원성오년문동방과위세관신화경내연마결무데비회실요러공개발생중모물용통학유까계미간선된거할합니않작때말없것더안같두번새집날밤길손눈꽃별바람음랑노래친영드임악편권판본완막글산행곡명량극직업택운함께괴추억살불착씨충징올활금토월목알파베타감독배출봉특삭포즌피차송예능뉴스큐멘터애메션웹툰설반앨범싱뮤디콘트브버빙속후최종즈둘셋넷섯곱덟홉열백천남북울강청군읍호역"
+
+// 获取字符集对应的编码, 不支持时返回nil
+func lookupCharset(name string) encoding.Encoding {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil
+	}
+
+	return enc
+}
+
+// 是否为utf-8字符集名称
+func isUTF8Name(name string) bool {
+	name = strings.ToUpper(strings.Replace(strings.TrimSpace(name), "-", "", -1))
+	return name == "UTF8"
+}
+
+// 使用指定编码转换为utf-8, 出现无法转换的字符时返回false
+func decodeWith(enc encoding.Encoding, b []byte) (string, bool) {
+	out, err := enc.NewDecoder().Bytes(b)
+	if err != nil {
+		return "", false
+	}
+
+	s := string(out)
+	if strings.ContainsRune(s, utf8.RuneError) {
+		return "", false
+	}
+
+	return s, true
+}
+
+// 检测字符集, hint为种子中encoding字段的值
+func DetectCharset(b []byte, hint string) string {
+	// 合法的utf-8(包括纯ASCII)直接使用
+	if utf8.Valid(b) {
+		return "UTF-8"
+	}
+
+	// 优先使用种子声明的字符集
+	if !isUTF8Name(hint) {
+		if enc := lookupCharset(hint); enc != nil {
+			if _, ok := decodeWith(enc, b); ok {
+				return hint
+			}
+		}
+	}
+
+	// 否则进行统计检测
+	best, bestScore := "", 0.0
+	for _, c := range charsetCandidates {
+		s, ok := decodeWith(c.enc, b)
+		if !ok {
+			continue
+		}
+		if score := charsetScore(s); best == "" || score > bestScore {
+			best, bestScore = c.name, score
+		}
+	}
+
+	return best
+}
+
+// 将字节转换为utf-8字符串, 无法识别时丢弃非法字符
+func DecodeCharset(b []byte, charset string) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+
+	if enc := lookupCharset(charset); enc != nil {
+		if s, ok := decodeWith(enc, b); ok {
+			return s
+		}
+	}
+
+	return strings.ToValidUTF8(string(b), "")
+}
+
+// 检测种子中非utf-8名称与路径使用的字符集
+func TorrentCharset(meta *MetaInfo) string {
+	// 将所有需要转换的字段合并检测, 样本越多越准确
+	var sample []byte
+
+	if meta.Info.Name8 == "" {
+		sample = append(sample, meta.Info.Name...)
+	}
+	for _, f := range meta.Info.Files {
+		if f.Path8 != nil {
+			continue
+		}
+		for _, p := range f.Path {
+			sample = append(sample, ' ')
+			sample = append(sample, p...)
+		}
+	}
+
+	return DetectCharset(sample, meta.Encoding)
+}
+
+// 计算解码结果的可信度, 分数越高越可能是正确的字符集
+// 汉字与假名可以同时出现, 韩文与汉字或假名混合时多为错误解码, 按混合的字符数扣分
+func charsetScore(s string) float64 {
+	var score float64
+	var count, han, kana, hangul int
+
+	for _, r := range s {
+		count++
+
+		switch {
+		case r < utf8.RuneSelf:
+			// ASCII字符不区分字符集
+			score += 0.2
+		case strings.ContainsRune(commonHan, r):
+			// 高频汉字
+			score += 3
+			han++
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r) && r < 0xFF00:
+			// 平假名与全角片假名
+			score += 2
+			kana++
+		case strings.ContainsRune(commonHangul, r):
+			// 高频韩文
+			score += 3
+			hangul++
+		case unicode.Is(unicode.Hangul, r):
+			// 其它韩文
+			score += 0.5
+			hangul++
+		case r >= 0x4E00 && r <= 0x9FFF:
+			// 其它常用汉字区
+			score += 1
+			han++
+		case r >= 0xFF61 && r <= 0xFF9F:
+			// 半角片假名, 错误解码时常见
+			score -= 0.5
+		case r >= 0xE000 && r <= 0xF8FF, r >= 0x3400 && r <= 0x4DBF:
+			// 私用区与扩展汉字, 正常文本很少出现
+			score -= 2
+		case unicode.IsPunct(r) || unicode.IsSpace(r) || unicode.IsNumber(r):
+			score += 0.2
+		default:
+			score -= 0.5
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	// 韩文与汉字或假名混合
+	if mixed := han + kana; hangul > 0 && mixed > 0 {
+		if mixed > hangul {
+			mixed = hangul
+		}
+		score -= 4 * float64(mixed)
+	}
+
+	return score / float64(count)
+}
@@ -0,0 +1,140 @@
+package common
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// 切换到临时目录, 入库时生成的二维码写入临时目录
+func inTempDir(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestReadTorrentUTF8Fields(t *testing.T) {
+	meta, err := ReadTorrent(bytes.NewReader(readFixture(t, "gbk.torrent")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.Info.Name8 != "中文電影" {
+		t.Errorf("Name8 = %q", meta.Info.Name8)
+	}
+	if len(meta.Info.Files) != 2 {
+		t.Fatalf("files = %+v", meta.Info.Files)
+	}
+	if !reflect.DeepEqual(meta.Info.Files[0].Path8, []string{"第一集.mkv"}) {
+		t.Errorf("Path8 = %q", meta.Info.Files[0].Path8)
+	}
+	if meta.Info.Files[1].Path8 != nil {
+		t.Errorf("second file should have no path.utf-8: %q", meta.Info.Files[1].Path8)
+	}
+	if got := TorrentCharset(&meta); got != "GBK" {
+		t.Errorf("TorrentCharset() = %q, want GBK", got)
+	}
+}
+
+func TestPutTorrentPrefersUTF8Name(t *testing.T) {
+	data := readFixture(t, "gbk.torrent")
+	store := useMemoryStore(t)
+	inTempDir(t)
+
+	meta, err := ReadTorrent(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := PutTorrent(meta); err != nil {
+		t.Fatal(err)
+	}
+
+	scinfo, ok := store.GetInfo(meta.InfoHash)
+	if !ok {
+		t.Fatal("torrent not stored")
+	}
+
+	// utf-8名称优先于按字符集转换的GBK名称
+	if scinfo.Caption != "中文電影" {
+		t.Errorf("Caption = %q, want utf-8 name", scinfo.Caption)
+	}
+	if scinfo.Charset != "GBK" || len(scinfo.RawCaption) != 0 {
+		t.Errorf("Charset = %q, RawCaption = %x", scinfo.Charset, scinfo.RawCaption)
+	}
+	if len(scinfo.Files) != 2 {
+		t.Fatalf("files = %+v", scinfo.Files)
+	}
+
+	// 有utf-8路径的文件不保留原始路径, 其余按GBK转换并保留原始字节
+	if scinfo.Files[0].Path != "第一集.mkv" || scinfo.Files[0].Raw != nil {
+		t.Errorf("first file = %q raw %x", scinfo.Files[0].Path, scinfo.Files[0].Raw)
+	}
+	if scinfo.Files[1].Path != "说明.txt" || len(scinfo.Files[1].Raw) != 1 || string(scinfo.Files[1].Raw[0]) == "说明.txt" {
+		t.Errorf("second file = %q raw %x", scinfo.Files[1].Path, scinfo.Files[1].Raw)
+	}
+}
+
+// 没有encoding字段时按统计检测的名称
+var detectTests = []struct {
+	charset string
+	enc     encoding.Encoding
+	names   []string
+}{
+	{"Big5", traditionalchinese.Big5, []string{"臥虎藏龍", "臥虎藏龍.2000.1080p", "無間道", "英雄本色 國語中字", "天龍八部 第01集", "海角七號", "那些年，我們一起追的女孩", "賽德克·巴萊", "霸王別姬", "牯嶺街少年殺人事件", "陳情令 第10集"}},
+	{"Shift_JIS", japanese.ShiftJIS, []string{"千と千尋の神隠し", "となりのトトロ", "進撃の巨人 第1話", "ワンピース", "君の名は。", "新世紀エヴァンゲリオン", "東京物語", "七人の侍", "鬼滅の刃", "涼宮ハルヒの憂鬱", "攻殻機動隊"}},
+	{"EUC-KR", korean.EUCKR, []string{"기생충", "오징어 게임 1화", "올드보이", "부산행", "살인의 추억", "사랑의 불시착 E01", "극한직업", "명량", "도깨비", "이태원 클라쓰", "킹덤 시즌2"}},
+	{"GBK", simplifiedchinese.GBK, []string{"流浪地球", "让子弹飞", "霸王别姬 国语中字", "三体 第一集", "卧虎藏龙", "琅琊榜", "甄嬛传", "哪吒之魔童降世", "你好，李焕英", "红楼梦", "狂飙 第1集"}},
+}
+
+func TestDetectCharsetWithoutHint(t *testing.T) {
+	for _, tt := range detectTests {
+		for _, name := range tt.names {
+			b, err := tt.enc.NewEncoder().Bytes([]byte(name))
+			if err != nil {
+				t.Fatalf("encode %q as %s: %v", name, tt.charset, err)
+			}
+			if got := DetectCharset(b, ""); got != tt.charset {
+				t.Errorf("DetectCharset(%s %q) = %s, decoded as %q", tt.charset, name, got, DecodeCharset(b, got))
+			}
+		}
+	}
+}
+
+func TestCharsetScoreMixedScripts(t *testing.T) {
+	// Big5编码的"臥虎藏龍"按EUC-KR解码得到假名与韩文的混合
+	if mixed, han := charsetScore("ぷり쩠픰"), charsetScore("臥虎藏龍"); mixed >= han {
+		t.Errorf("mixed score %.2f >= han score %.2f", mixed, han)
+	}
+	// 汉字与假名混合是正常的日文
+	if score := charsetScore("千と千尋の神隠し"); score <= 1 {
+		t.Errorf("japanese score %.2f", score)
+	}
+}
+
+func TestMetaInfoGetName(t *testing.T) {
+	name, _ := traditionalchinese.Big5.NewEncoder().String("臥虎藏龍")
+
+	var meta MetaInfo
+	meta.Info.Name = name
+	if got := meta.GetName(); got != "臥虎藏龍" {
+		t.Errorf("GetName() = %q", got)
+	}
+
+	// utf-8名称优先
+	meta.Info.Name8 = " Crouching Tiger "
+	if got := meta.GetName(); got != "Crouching Tiger" {
+		t.Errorf("GetName() with name.utf-8 = %q", got)
+	}
+}
@@ -0,0 +1,468 @@
+// 种子文件制作
+package common
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zeebo/bencode"
+)
+
+// 种子格式
+const (
+	FormatV1     = "v1"     // 只包含v1元数据
+	FormatV2     = "v2"     // 只包含v2元数据(BEP 52)
+	FormatHybrid = "hybrid" // 同时包含v1与v2元数据
+)
+
+// v2分块的最小单位
+const blockSize = 16 * 1024
+
+// 自动选择分块大小的范围
+const (
+	minPieceLength = 16 * 1024
+	maxPieceLength = 16 * 1024 * 1024
+)
+
+var (
+	// 没有可制作种子的文件
+	ErrNoFiles = errors.New("no files to create torrent from")
+	// 分块大小错误
+	ErrPieceLength = errors.New("piece length must be a power of two and at least 16 KiB")
+	// 种子格式错误
+	ErrFormat = errors.New("format must be v1, v2 or hybrid")
+)
+
+// 制作种子的选项
+type CreateOptions struct {
+	Name        string     // 种子名称, 默认为文件或目录名
+	PieceLength int64      // 分块大小, 为0时自动选择
+	Format      string     // 种子格式, 默认为v1
+	Trackers    [][]string // 分层tracker列表
+	WebSeeds    []string   // web种子
+	Private     bool       // 是否为私有种子
+	Source      string     // 发布来源
+	Comment     string     // 注释
+	CreatedBy   string     // 制作程序
+	NoDate      bool       // 不写入制作时间
+}
+
+// 待制作种子的文件
+type createFile struct {
+	path   string   // 本地路径
+	parts  []string // 种子中的路径
+	length int64    // 文件长度
+}
+
+// 从文件或目录制作种子, 返回bencode编码后的种子内容
+func CreateTorrent(path string, opts CreateOptions) ([]byte, error) {
+	format := strings.ToLower(strings.TrimSpace(opts.Format))
+	if format == "" {
+		format = FormatV1
+	}
+	if format != FormatV1 && format != FormatV2 && format != FormatHybrid {
+		return nil, ErrFormat
+	}
+	v1 := format != FormatV2
+	v2 := format != FormatV1
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 列出所有文件
+	files, err := createFiles(path, stat)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, ErrNoFiles
+	}
+
+	var total int64
+	// 最后一个非空文件, 其后不需要填充
+	last := 0
+	for i, f := range files {
+		total += f.length
+		if f.length > 0 {
+			last = i
+		}
+	}
+
+	pieceLength := opts.PieceLength
+	if pieceLength == 0 {
+		pieceLength = autoPieceLength(total)
+	}
+	if pieceLength < minPieceLength || pieceLength&(pieceLength-1) != 0 {
+		return nil, ErrPieceLength
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = stat.Name()
+	}
+
+	info := map[string]interface{}{
+		"name":         name,
+		"piece length": pieceLength,
+	}
+	if opts.Private {
+		info["private"] = int64(1)
+	}
+	if opts.Source != "" {
+		info["source"] = opts.Source
+	}
+
+	// 计算分块hash
+	var pieces *pieceHasher
+	if v1 {
+		pieces = newPieceHasher(pieceLength)
+	}
+	var v1Files []interface{}
+	tree := make(map[string]interface{})
+	layers := make(map[string]interface{})
+	buf := make([]byte, 256*1024)
+
+	for i, f := range files {
+		var merkle *merkleHasher
+		if v2 {
+			merkle = new(merkleHasher)
+		}
+
+		if err = hashFile(f, pieces, merkle, buf); err != nil {
+			return nil, err
+		}
+
+		if v1 {
+			v1Files = append(v1Files, map[string]interface{}{
+				"length": f.length,
+				"path":   toList(f.parts),
+			})
+
+			// 混合种子中每个文件需要从分块边界开始, 不足部分使用填充文件补齐(BEP 47)
+			if format == FormatHybrid && i < last {
+				if pad := pieces.padding(); pad > 0 {
+					pieces.Write(make([]byte, pad))
+					v1Files = append(v1Files, map[string]interface{}{
+						"attr":   "p",
+						"length": pad,
+						"path":   toList([]string{".pad", fmt.Sprint(pad)}),
+					})
+				}
+			}
+		}
+
+		if v2 {
+			node := map[string]interface{}{"length": f.length}
+			if f.length > 0 {
+				root, layer := merkle.root(pieceLength)
+				node["pieces root"] = string(root)
+				// 大于一个分块的文件需要保存分块层
+				if f.length > pieceLength {
+					layers[string(root)] = string(layer)
+				}
+			}
+			addFileTree(tree, f.parts, node)
+		}
+	}
+
+	if v1 {
+		info["pieces"] = string(pieces.sum())
+		if stat.IsDir() {
+			info["files"] = v1Files
+		} else {
+			info["length"] = files[0].length
+		}
+	}
+	if v2 {
+		info["meta version"] = int64(2)
+		if stat.IsDir() {
+			info["file tree"] = tree
+		} else {
+			// 单文件种子的文件树以种子名称为文件名
+			info["file tree"] = map[string]interface{}{name: tree[files[0].parts[0]]}
+		}
+	}
+
+	torrent := map[string]interface{}{
+		"info": info,
+	}
+	if v2 && len(layers) > 0 {
+		torrent["piece layers"] = layers
+	}
+
+	// tracker列表
+	var tiers []interface{}
+	for _, tier := range opts.Trackers {
+		var t []string
+		for _, tr := range tier {
+			if tr = strings.TrimSpace(tr); tr != "" {
+				t = append(t, tr)
+			}
+		}
+		if len(t) > 0 {
+			if len(tiers) == 0 {
+				torrent["announce"] = t[0]
+			}
+			tiers = append(tiers, toList(t))
+		}
+	}
+	if len(tiers) > 1 || (len(tiers) == 1 && len(tiers[0].([]interface{})) > 1) {
+		torrent["announce-list"] = tiers
+	}
+
+	if len(opts.WebSeeds) > 0 {
+		torrent["url-list"] = toList(opts.WebSeeds)
+	}
+	if opts.Comment != "" {
+		torrent["comment"] = opts.Comment
+	}
+	if opts.CreatedBy != "" {
+		torrent["created by"] = opts.CreatedBy
+	}
+	if !opts.NoDate {
+		torrent["creation date"] = time.Now().Unix()
+	}
+
+	return bencode.EncodeBytes(torrent)
+}
+
+// 列出文件或目录下的所有文件, 按种子中的顺序排列
+func createFiles(path string, stat os.FileInfo) ([]createFile, error) {
+	if !stat.IsDir() {
+		return []createFile{{path: path, parts: []string{stat.Name()}, length: stat.Size()}}, nil
+	}
+
+	var files []createFile
+	// Walk按文件名顺序遍历, 与v2文件树的键名顺序一致
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, createFile{
+			path:   p,
+			parts:  strings.Split(filepath.ToSlash(rel), "/"),
+			length: info.Size(),
+		})
+		return nil
+	})
+
+	return files, err
+}
+
+// 读取文件并计算分块hash
+func hashFile(f createFile, pieces *pieceHasher, merkle *merkleHasher, buf []byte) error {
+	fh, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var w io.Writer
+	switch {
+	case pieces != nil && merkle != nil:
+		w = io.MultiWriter(pieces, merkle)
+	case pieces != nil:
+		w = pieces
+	default:
+		w = merkle
+	}
+
+	n, err := io.CopyBuffer(w, fh, buf)
+	if err != nil {
+		return err
+	}
+	if n != f.length {
+		return fmt.Errorf("%s: file changed while hashing", f.path)
+	}
+
+	return nil
+}
+
+// 根据总大小选择分块大小, 使分块数量在1000到2000之间
+func autoPieceLength(total int64) int64 {
+	length := int64(minPieceLength)
+	for length < maxPieceLength && total/length > 2000 {
+		length *= 2
+	}
+
+	return length
+}
+
+// 将路径加入v2文件树
+func addFileTree(tree map[string]interface{}, parts []string, node map[string]interface{}) {
+	for _, part := range parts {
+		sub, ok := tree[part].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			tree[part] = sub
+		}
+		tree = sub
+	}
+
+	// 空键表示文件本身
+	tree[""] = node
+}
+
+// 转换为bencode列表
+func toList(list []string) []interface{} {
+	result := make([]interface{}, len(list))
+	for i, s := range list {
+		result[i] = s
+	}
+
+	return result
+}
+
+// v1分块hash计算
+type pieceHasher struct {
+	length int64     // 分块大小
+	filled int64     // 当前分块已写入的长度
+	hash   hash.Hash // 当前分块的hash
+	pieces []byte    // 已完成的分块hash
+}
+
+// 创建v1分块hash计算
+func newPieceHasher(length int64) *pieceHasher {
+	return &pieceHasher{length: length, hash: sha1.New()}
+}
+
+func (p *pieceHasher) Write(b []byte) (int, error) {
+	n := len(b)
+
+	for len(b) > 0 {
+		m := p.length - p.filled
+		if int64(len(b)) < m {
+			m = int64(len(b))
+		}
+
+		p.hash.Write(b[:m])
+		p.filled += m
+		b = b[m:]
+
+		if p.filled == p.length {
+			p.pieces = p.hash.Sum(p.pieces)
+			p.hash.Reset()
+			p.filled = 0
+		}
+	}
+
+	return n, nil
+}
+
+// 补齐到分块边界所需的长度
+func (p *pieceHasher) padding() int64 {
+	if p.filled == 0 {
+		return 0
+	}
+
+	return p.length - p.filled
+}
+
+// 获取所有分块hash, 最后一个分块可以不足分块大小
+func (p *pieceHasher) sum() []byte {
+	if p.filled > 0 {
+		p.pieces = p.hash.Sum(p.pieces)
+		p.hash.Reset()
+		p.filled = 0
+	}
+
+	return p.pieces
+}
+
+// v2文件merkle树计算
+type merkleHasher struct {
+	block  bytes.Buffer // 当前未满的块
+	leaves [][]byte     // 每个16KiB块的hash
+}
+
+func (m *merkleHasher) Write(b []byte) (int, error) {
+	n := len(b)
+
+	for len(b) > 0 {
+		k := blockSize - m.block.Len()
+		if len(b) < k {
+			k = len(b)
+		}
+
+		m.block.Write(b[:k])
+		b = b[k:]
+
+		if m.block.Len() == blockSize {
+			m.flush()
+		}
+	}
+
+	return n, nil
+}
+
+// 计算当前块的hash
+func (m *merkleHasher) flush() {
+	sum := sha256.Sum256(m.block.Bytes())
+	m.leaves = append(m.leaves, sum[:])
+	m.block.Reset()
+}
+
+// 计算merkle树根与分块层
+func (m *merkleHasher) root(pieceLength int64) (root []byte, layer []byte) {
+	if m.block.Len() > 0 {
+		m.flush()
+	}
+
+	// 叶子数量补齐到2的幂, 超出文件末尾的叶子为全零
+	blocks := len(m.leaves)
+	n := 1
+	for n < blocks {
+		n *= 2
+	}
+	level := make([][]byte, n)
+	copy(level, m.leaves)
+	for i := blocks; i < n; i++ {
+		level[i] = make([]byte, sha256.Size)
+	}
+
+	// 每个分块包含的块数量
+	pieceBlocks := int(pieceLength / blockSize)
+	pieces := (blocks + pieceBlocks - 1) / pieceBlocks
+
+	for width := 1; len(level) > 1; width *= 2 {
+		if width == pieceBlocks {
+			for _, h := range level[:pieces] {
+				layer = append(layer, h...)
+			}
+		}
+
+		next := make([][]byte, len(level)/2)
+		for i := range next {
+			h := sha256.New()
+			h.Write(level[2*i])
+			h.Write(level[2*i+1])
+			next[i] = h.Sum(nil)
+		}
+		level = next
+	}
+
+	return level[0], layer
+}
@@ -0,0 +1,324 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zeebo/bencode"
+)
+
+// 测试使用的分块大小, 每个分块只有一个16KiB块
+const testPieceLength = 16 * 1024
+
+// 测试目录中的文件, 按种子中的顺序排列
+var testCreateFiles = []struct {
+	path   string
+	length int
+}{
+	{"a.bin", 50000},     // 多个分块, 需要分块层
+	{"sub/b.txt", 3000},  // 不足一个分块
+	{"sub/c.dat", 16384}, // 正好一个分块
+}
+
+// 生成测试文件内容
+func testFileData(i, length int) []byte {
+	data := make([]byte, length)
+	for j := range data {
+		data[j] = byte((j*31 + i*7 + j/251) % 256)
+	}
+	return data
+}
+
+// 创建测试目录
+func createTestDir(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "album")
+	for i, f := range testCreateFiles {
+		path := filepath.Join(dir, filepath.FromSlash(f.path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, testFileData(i, f.length), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// 计算merkle子树的hash, 超出叶子数量的部分使用zero
+func testSubtree(leaves [][]byte, start, n int, zero []byte) []byte {
+	if n == 1 {
+		if start < len(leaves) {
+			return leaves[start]
+		}
+		return zero
+	}
+
+	sum := sha256.Sum256(append(append([]byte{}, testSubtree(leaves, start, n/2, zero)...), testSubtree(leaves, start+n/2, n/2, zero)...))
+	return sum[:]
+}
+
+// 按BEP 52计算文件的pieces root与分块层
+func testMerkle(data []byte, pieceLength int) (root []byte, layer []byte) {
+	var blocks [][]byte
+	for i := 0; i < len(data); i += blockSize {
+		end := i + blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		sum := sha256.Sum256(data[i:end])
+		blocks = append(blocks, sum[:])
+	}
+
+	zero := make([]byte, sha256.Size)
+	n := 1
+	for n < len(blocks) {
+		n *= 2
+	}
+	root = testSubtree(blocks, 0, n, zero)
+
+	pieceBlocks := pieceLength / blockSize
+	if len(data) > pieceLength {
+		for i := 0; i*pieceBlocks < len(blocks); i++ {
+			layer = append(layer, testSubtree(blocks, i*pieceBlocks, pieceBlocks, zero)...)
+		}
+	}
+
+	return
+}
+
+// 由分块层计算pieces root, 用于校验分块层
+func testLayerRoot(layer []byte, pieceLength int) []byte {
+	var hashes [][]byte
+	for i := 0; i < len(layer); i += sha256.Size {
+		hashes = append(hashes, layer[i:i+sha256.Size])
+	}
+
+	// 超出文件末尾的分块为全零块组成的子树
+	zero := testSubtree(nil, 0, pieceLength/blockSize, make([]byte, sha256.Size))
+	n := 1
+	for n < len(hashes) {
+		n *= 2
+	}
+	return testSubtree(hashes, 0, n, zero)
+}
+
+// 读取种子中的原始info
+func rawInfo(t *testing.T, data []byte) []byte {
+	var raw struct {
+		Info bencode.RawMessage `bencode:"info"`
+	}
+	if err := bencode.DecodeBytes(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	return raw.Info
+}
+
+func TestCreateTorrentRoundTrip(t *testing.T) {
+	dir := createTestDir(t)
+
+	for _, format := range []string{FormatV1, FormatV2, FormatHybrid} {
+		data, err := CreateTorrent(dir, CreateOptions{
+			Format:      format,
+			PieceLength: testPieceLength,
+			Trackers:    [][]string{{"http://a.example/announce", "http://b.example/announce"}, {"udp://c.example:80"}},
+			WebSeeds:    []string{"http://seed.example/"},
+			Comment:     "test",
+			NoDate:      true,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		meta, err := ReadTorrent(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: ReadTorrent: %v", format, err)
+		}
+		v1 := format != FormatV2
+		v2 := format != FormatV1
+		if meta.Info.IsV1() != v1 || meta.Info.IsV2() != v2 {
+			t.Fatalf("%s: IsV1 %v IsV2 %v", format, meta.Info.IsV1(), meta.Info.IsV2())
+		}
+		if meta.Info.Name != "album" || meta.Info.PieceLength != testPieceLength || meta.Comment != "test" || meta.CreationDate != 0 {
+			t.Errorf("%s: name %q, piece length %d, comment %q, date %d", format, meta.Info.Name, meta.Info.PieceLength, meta.Comment, meta.CreationDate)
+		}
+		if got := meta.Trackers(); len(got) != 2 || meta.Announce != "http://a.example/announce" {
+			t.Errorf("%s: announce %q, trackers %v", format, meta.Announce, got)
+		}
+		if got := meta.WebSeeds(); !reflect.DeepEqual(got, []string{"http://seed.example/"}) {
+			t.Errorf("%s: web seeds %v", format, got)
+		}
+
+		// infohash由原始info计算
+		info := rawInfo(t, data)
+		sha1Hash, sha256Hash := sha1.Sum(info), sha256.Sum256(info)
+		wantV1 := fmt.Sprintf("%02X", sha1Hash[:])
+		wantV2 := fmt.Sprintf("%02X", sha256Hash[:])
+		switch format {
+		case FormatV1:
+			if meta.InfoHash != wantV1 || meta.InfoHashV2 != "" {
+				t.Errorf("%s: infohash %s / %s", format, meta.InfoHash, meta.InfoHashV2)
+			}
+		case FormatV2:
+			if meta.InfoHashV2 != wantV2 || meta.InfoHash != wantV2[:40] {
+				t.Errorf("%s: infohash %s / %s", format, meta.InfoHash, meta.InfoHashV2)
+			}
+		case FormatHybrid:
+			if meta.InfoHash != wantV1 || meta.InfoHashV2 != wantV2 {
+				t.Errorf("%s: infohash %s / %s", format, meta.InfoHash, meta.InfoHashV2)
+			}
+		}
+
+		if v1 {
+			checkV1Pieces(t, format, meta)
+		}
+		if v2 {
+			checkV2Layers(t, format, meta)
+		}
+	}
+}
+
+// 校验v1文件列表、填充文件与分块hash
+func checkV1Pieces(t *testing.T, format string, meta MetaInfo) {
+	var content []byte
+	var want []FileDict
+	for i, f := range testCreateFiles {
+		content = append(content, testFileData(i, f.length)...)
+		want = append(want, FileDict{Length: int64(f.length), Path: strings.Split(f.path, "/")})
+
+		// 混合种子中除最后一个文件外都补齐到分块边界
+		if format == FormatHybrid && i < len(testCreateFiles)-1 {
+			if pad := (testPieceLength - len(content)%testPieceLength) % testPieceLength; pad > 0 {
+				content = append(content, make([]byte, pad)...)
+				want = append(want, FileDict{Length: int64(pad), Path: []string{".pad", fmt.Sprint(pad)}, Attr: "p"})
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(meta.Info.Files, want) {
+		t.Errorf("%s: files = %+v, want %+v", format, meta.Info.Files, want)
+	}
+
+	var pieces []byte
+	for i := 0; i < len(content); i += testPieceLength {
+		end := i + testPieceLength
+		if end > len(content) {
+			end = len(content)
+		}
+		sum := sha1.Sum(content[i:end])
+		pieces = append(pieces, sum[:]...)
+	}
+	if meta.Info.Pieces != string(pieces) {
+		t.Errorf("%s: v1 pieces do not match file content", format)
+	}
+}
+
+// 校验v2文件树与分块层
+func checkV2Layers(t *testing.T, format string, meta MetaInfo) {
+	files := meta.Info.V2Files()
+	if len(files) != len(testCreateFiles) {
+		t.Fatalf("%s: V2Files() = %+v", format, files)
+	}
+
+	layers := 0
+	for i, f := range testCreateFiles {
+		if got := strings.Join(files[i].Path, "/"); got != f.path || files[i].Length != int64(f.length) {
+			t.Errorf("%s: file %d is %s (%d)", format, i, got, files[i].Length)
+			continue
+		}
+
+		root, layer := testMerkle(testFileData(i, f.length), testPieceLength)
+		if files[i].PiecesRoot != fmt.Sprintf("%02X", root) {
+			t.Errorf("%s: %s pieces root %s, want %X", format, f.path, files[i].PiecesRoot, root)
+		}
+
+		got, ok := meta.PieceLayers[string(root)]
+		if layer == nil {
+			if ok {
+				t.Errorf("%s: %s should not have a piece layer", format, f.path)
+			}
+			continue
+		}
+		layers++
+		if !ok || got != string(layer) {
+			t.Errorf("%s: %s piece layer does not match file content", format, f.path)
+			continue
+		}
+		if !bytes.Equal(testLayerRoot([]byte(got), testPieceLength), root) {
+			t.Errorf("%s: %s piece layer does not verify against pieces root", format, f.path)
+		}
+	}
+
+	if len(meta.PieceLayers) != layers {
+		t.Errorf("%s: %d piece layers, want %d", format, len(meta.PieceLayers), layers)
+	}
+}
+
+func TestCreateTorrentSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "movie.mkv")
+	content := testFileData(0, 40000)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := CreateTorrent(path, CreateOptions{Format: FormatHybrid, PieceLength: testPieceLength, Private: true, Source: "TEST"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := ReadTorrent(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.Info.Name != "movie.mkv" || meta.Info.Length != 40000 || len(meta.Info.Files) != 0 {
+		t.Errorf("name %q, length %d, files %d", meta.Info.Name, meta.Info.Length, len(meta.Info.Files))
+	}
+	if meta.Info.Private != 1 || meta.Info.Source != "TEST" || meta.CreationDate == 0 {
+		t.Errorf("private %d, source %q, date %d", meta.Info.Private, meta.Info.Source, meta.CreationDate)
+	}
+
+	// 单文件v2文件树以种子名称为文件名
+	files := meta.Info.V2Files()
+	root, layer := testMerkle(content, testPieceLength)
+	if len(files) != 1 || !reflect.DeepEqual(files[0].Path, []string{"movie.mkv"}) || files[0].PiecesRoot != fmt.Sprintf("%02X", root) {
+		t.Fatalf("V2Files() = %+v", files)
+	}
+	if meta.PieceLayers[string(root)] != string(layer) {
+		t.Error("piece layer does not match file content")
+	}
+}
+
+func TestCreateTorrentOptions(t *testing.T) {
+	dir := createTestDir(t)
+
+	if _, err := CreateTorrent(dir, CreateOptions{Format: "v3"}); err != ErrFormat {
+		t.Errorf("format v3: %v", err)
+	}
+	for _, length := range []int64{1024, 3 * 16 * 1024} {
+		if _, err := CreateTorrent(dir, CreateOptions{PieceLength: length}); err != ErrPieceLength {
+			t.Errorf("piece length %d: %v", length, err)
+		}
+	}
+	if _, err := CreateTorrent(t.TempDir(), CreateOptions{}); err != ErrNoFiles {
+		t.Errorf("empty directory: %v", err)
+	}
+
+	// 自动选择分块大小
+	if got := autoPieceLength(0); got != minPieceLength {
+		t.Errorf("autoPieceLength(0) = %d", got)
+	}
+	if got := autoPieceLength(4 << 30); got != 4<<20 {
+		t.Errorf("autoPieceLength(4GiB) = %d", got)
+	}
+	if got := autoPieceLength(1 << 50); got != maxPieceLength {
+		t.Errorf("autoPieceLength(1PiB) = %d", got)
+	}
+}
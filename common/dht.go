@@ -14,13 +14,13 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/ylqjgm/SCDht/models"
 	"github.com/zeebo/bencode"
-	"gopkg.in/mgo.v2/bson"
 )
 
 var (
@@ -49,7 +49,13 @@ type KNode struct {
 	network *Network
 	log     *log.Logger
 	krpc    *KRPC
-	outChan chan string
+	outChan chan HashMsg
+}
+
+// 获取到的InfoHash信息
+type HashMsg struct {
+	InfoHash string // InfoHash
+	Announce bool   // 是否来自announce_peer, 否则来自get_peers
 }
 
 // 网络结构
@@ -96,35 +102,42 @@ type Response struct {
 }
 
 // 输出Hash
-func OutHash(master chan string) {
+func OutHash(master chan HashMsg) {
 	for {
 		select {
-		case infohash := <-master:
+		case msg := <-master:
 			if models.DbConfig.ShowMsg {
-				fmt.Println("Get InfoHash: ", infohash)
+				fmt.Println("Get InfoHash: ", msg.InfoHash)
 			}
 			// 定义一个SC_Hash
 			var schash models.SC_Hash
 			// 设置SC_Hash
 			schash.Hot = 1
 			schash.IsPut = false
-			schash.InfoHash = strings.ToUpper(strings.TrimSpace(infohash))
-			// 检测infohash是否存在
-			has := models.Has(models.DbHash, bson.M{"infohash": schash.InfoHash})
-			// 保存hash数据
-			err := schash.Save()
-			if err == nil && has == false {
+			schash.InfoHash = strings.ToUpper(strings.TrimSpace(msg.InfoHash))
+			// 记录来源类型
+			if msg.Announce {
+				schash.Announce = 1
+			} else {
+				schash.GetPeers = 1
+			}
+			// 保存hash数据, 只有新的hash计入新hash统计
+			isNew, err := schash.Save()
+			models.SaveLog(models.LogSeen)
+			if err == nil && isNew {
 				// 自增统计数据
-				models.SaveLog(time.Now().Format("20060102"), "dhtnums")
+				models.SaveLog(models.LogDht)
 			}
 
 			// 修改种子热度
 			models.SetHot(schash.InfoHash)
+			// 记录获取历史
+			models.SaveSwarm(schash.InfoHash)
 		}
 	}
 }
 
-func Executing(master chan string) {
+func Executing(master chan HashMsg) {
 	dhtNode := NewdhtNode(master, os.Stdout)
 	dhtNode.Run()
 }
@@ -134,12 +147,12 @@ func GenerateId() Id {
 	random := rand.New(rand.NewSource(time.Now().UnixNano()))
 	h := sha1.New()
 	io.WriteString(h, time.Now().String())
-	io.WriteString(h, string(random.Int()))
+	io.WriteString(h, strconv.Itoa(random.Int()))
 	return h.Sum(nil)
 }
 
 //DhtNode 节点信息
-func NewdhtNode(master chan string, logger io.Writer) *KNode {
+func NewdhtNode(master chan HashMsg, logger io.Writer) *KNode {
 	dhtNode := new(KNode)
 	dhtNode.log = log.New(logger, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Lshortfile)
 	dhtNode.node = NewNode()
@@ -412,11 +425,11 @@ func (krpc *KRPC) Query(msg *KRPCMSG) {
 			krpc.dhtNode.network.Send([]byte(data), msg.addr)
 		case "announce_peer":
 			if infohash, ok := query.A["info_hash"].(string); ok {
-				krpc.dhtNode.outChan <- Id(infohash).String()
+				krpc.dhtNode.outChan <- HashMsg{InfoHash: Id(infohash).String(), Announce: true}
 			}
 		case "get_peers":
 			if infohash, ok := query.A["info_hash"].(string); ok {
-				krpc.dhtNode.outChan <- Id(infohash).String()
+				krpc.dhtNode.outChan <- HashMsg{InfoHash: Id(infohash).String()}
 				token := krpc.dhtNode.GenToken(queryNode)
 				nodes := ConvertByteStream(krpc.dhtNode.routing.table[1].Nodes)
 				data, _ := krpc.EncodingNodeResult(msg.T, token, nodes)
@@ -504,7 +517,7 @@ func ParseBytesStream(data []byte) []*NodeInfo {
 // 运行
 func Dht() {
 	runtime.GOMAXPROCS(2)
-	master := make(chan string)
+	master := make(chan HashMsg)
 	for i := 0; i < 2; i++ {
 		go Executing(master)
 	}
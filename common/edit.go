@@ -0,0 +1,210 @@
+// 种子编辑
+package common
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/zeebo/bencode"
+)
+
+// 修改会改变infohash的警告
+var ErrInfoChange = errors.New("changing the private flag modifies the info dictionary and changes the infohash")
+
+// 编辑选项, 指针或切片为nil的项不修改
+type EditOptions struct {
+	Trackers        [][]string // 替换全部tracker层
+	AddTrackers     [][]string // 追加的tracker层
+	RemoveTrackers  []string   // 删除的tracker
+	WebSeeds        []string   // 替换全部web种子
+	AddWebSeeds     []string   // 追加的web种子
+	RemoveWebSeeds  []string   // 删除的web种子
+	Comment         *string    // 注释, 为空字符串时删除
+	CreatedBy       *string    // 制作程序, 为空字符串时删除
+	Private         *bool      // 是否为私有种子, 修改会改变infohash
+	AllowInfoChange bool       // 是否允许修改info字典
+}
+
+// 编辑结果
+type EditResult struct {
+	Data        []byte   // 编辑后的种子内容
+	Name        string   // 种子名称
+	InfoHash    string   // 编辑前的infohash
+	NewInfoHash string   // 编辑后的infohash
+	Warnings    []string // 警告信息
+}
+
+// 编辑种子的非info字段, 返回编辑后的种子内容
+func EditTorrent(data []byte, opts EditOptions) (res EditResult, err error) {
+	meta, err := ReadTorrent(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	res.InfoHash = meta.InfoHash
+	res.Name = meta.GetName()
+
+	// 只解码第一层, 其余字段保持原样
+	var dict map[string]bencode.RawMessage
+	if err = bencode.DecodeBytes(data, &dict); err != nil {
+		return
+	}
+
+	// tracker列表
+	if opts.Trackers != nil || len(opts.AddTrackers) > 0 || len(opts.RemoveTrackers) > 0 {
+		tiers := meta.Trackers()
+		if opts.Trackers != nil {
+			tiers = opts.Trackers
+		}
+		tiers = append(tiers, opts.AddTrackers...)
+		tiers = editTiers(tiers, opts.RemoveTrackers)
+
+		delete(dict, "announce")
+		delete(dict, "announce-list")
+		if len(tiers) > 0 {
+			if err = setRaw(dict, "announce", tiers[0][0]); err != nil {
+				return
+			}
+			if err = setRaw(dict, "announce-list", tiers); err != nil {
+				return
+			}
+		}
+	}
+
+	// web种子
+	if opts.WebSeeds != nil || len(opts.AddWebSeeds) > 0 || len(opts.RemoveWebSeeds) > 0 {
+		seeds := meta.WebSeeds()
+		if opts.WebSeeds != nil {
+			seeds = opts.WebSeeds
+		}
+		seeds = append(seeds, opts.AddWebSeeds...)
+		seeds = editList(seeds, opts.RemoveWebSeeds)
+
+		delete(dict, "url-list")
+		if len(seeds) > 0 {
+			if err = setRaw(dict, "url-list", seeds); err != nil {
+				return
+			}
+		}
+	}
+
+	// 注释, 同时删除可能过期的utf-8注释
+	if opts.Comment != nil {
+		delete(dict, "comment")
+		delete(dict, "comment.utf-8")
+		if c := strings.TrimSpace(*opts.Comment); c != "" {
+			if err = setRaw(dict, "comment", c); err != nil {
+				return
+			}
+		}
+	}
+
+	// 制作程序
+	if opts.CreatedBy != nil {
+		delete(dict, "created by")
+		if c := strings.TrimSpace(*opts.CreatedBy); c != "" {
+			if err = setRaw(dict, "created by", c); err != nil {
+				return
+			}
+		}
+	}
+
+	// private位于info中, 修改会改变infohash
+	if opts.Private != nil && *opts.Private != (meta.Info.Private == 1) {
+		res.Warnings = append(res.Warnings, ErrInfoChange.Error())
+
+		// 未允许修改info时直接返回错误, 由调用方确认后重试
+		if !opts.AllowInfoChange {
+			err = ErrInfoChange
+			return
+		}
+
+		var info map[string]bencode.RawMessage
+		if err = bencode.DecodeBytes(dict["info"], &info); err != nil {
+			return
+		}
+
+		delete(info, "private")
+		if *opts.Private {
+			info["private"] = bencode.RawMessage("i1e")
+		}
+
+		if err = setRaw(dict, "info", info); err != nil {
+			return
+		}
+	}
+
+	if res.Data, err = bencode.EncodeBytes(dict); err != nil {
+		return
+	}
+
+	// 重新读取以确认结果有效, 并获取新的infohash
+	meta, err = ReadTorrent(bytes.NewReader(res.Data))
+	if err != nil {
+		return
+	}
+	res.NewInfoHash = meta.InfoHash
+
+	// 未允许修改info时infohash必须保持不变
+	if !opts.AllowInfoChange && res.NewInfoHash != res.InfoHash {
+		err = ErrInfoChange
+	}
+
+	return
+}
+
+// 编码并设置字段
+func setRaw(dict map[string]bencode.RawMessage, key string, v interface{}) error {
+	b, err := bencode.EncodeBytes(v)
+	if err != nil {
+		return err
+	}
+
+	dict[key] = bencode.RawMessage(b)
+	return nil
+}
+
+// 去重并删除指定的tracker, 同时去掉空层
+func editTiers(tiers [][]string, remove []string) [][]string {
+	// 定义一个map用以去重
+	has := make(map[string]bool)
+	for _, tr := range remove {
+		has[strings.TrimSpace(tr)] = true
+	}
+
+	var result [][]string
+	for _, tier := range tiers {
+		var t []string
+		for _, tr := range tier {
+			tr = strings.TrimSpace(tr)
+			if tr != "" && !has[tr] {
+				has[tr] = true
+				t = append(t, tr)
+			}
+		}
+		if len(t) > 0 {
+			result = append(result, t)
+		}
+	}
+
+	return result
+}
+
+// 去重并删除指定的项
+func editList(list []string, remove []string) []string {
+	has := make(map[string]bool)
+	for _, s := range remove {
+		has[strings.TrimSpace(s)] = true
+	}
+
+	var result []string
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s != "" && !has[s] {
+			has[s] = true
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEditTorrentKeepsInfoHash(t *testing.T) {
+	data := readFixture(t, "v1multi.torrent")
+	comment := "new comment"
+	createdBy := ""
+
+	res, err := EditTorrent(data, EditOptions{
+		Trackers:       [][]string{{"http://new.example/announce", " http://new.example/announce "}},
+		AddTrackers:    [][]string{{"udp://add.example:80", "udp://removed.example:80"}},
+		RemoveTrackers: []string{"udp://removed.example:80"},
+		AddWebSeeds:    []string{"http://seed3.example/", "http://seed.example/files/"},
+		RemoveWebSeeds: []string{"http://seed2.example/"},
+		Comment:        &comment,
+		CreatedBy:      &createdBy,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 非info字段的修改不改变infohash
+	want := "C2B8034ADB94D5CFFD8F5406DB981CCA4DAB5AE1"
+	if res.InfoHash != want || res.NewInfoHash != want || len(res.Warnings) != 0 {
+		t.Errorf("infohash %s -> %s, warnings %v", res.InfoHash, res.NewInfoHash, res.Warnings)
+	}
+	if res.Name != "album" {
+		t.Errorf("Name = %q", res.Name)
+	}
+
+	meta, err := ReadTorrent(bytes.NewReader(res.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.InfoHash != want {
+		t.Errorf("edited torrent infohash %s", meta.InfoHash)
+	}
+	if got, want := meta.Trackers(), [][]string{{"http://new.example/announce"}, {"udp://add.example:80"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Trackers() = %v, want %v", got, want)
+	}
+	if meta.Announce != "http://new.example/announce" {
+		t.Errorf("Announce = %q", meta.Announce)
+	}
+	if got, want := meta.WebSeeds(), []string{"http://seed.example/files/", "http://seed3.example/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WebSeeds() = %v, want %v", got, want)
+	}
+	// 修改注释时删除旧的utf-8注释
+	if meta.Comment != comment || meta.Comment8 != "" || meta.GetComment() != comment {
+		t.Errorf("comment %q, comment.utf-8 %q", meta.Comment, meta.Comment8)
+	}
+	if meta.CreatedBy != "" {
+		t.Errorf("CreatedBy = %q", meta.CreatedBy)
+	}
+
+	// 未修改的字段保持不变
+	if meta.Info.Private != 1 || meta.Info.Source != "TEST" || len(meta.HttpSeeds) != 1 {
+		t.Errorf("private %d, source %q, httpseeds %v", meta.Info.Private, meta.Info.Source, meta.HttpSeeds)
+	}
+}
+
+func TestEditTorrentClearTrackers(t *testing.T) {
+	res, err := EditTorrent(readFixture(t, "v1multi.torrent"), EditOptions{Trackers: [][]string{}, WebSeeds: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := ReadTorrent(bytes.NewReader(res.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Announce != "" || len(meta.Trackers()) != 0 || len(meta.WebSeeds()) != 0 {
+		t.Errorf("announce %q, trackers %v, web seeds %v", meta.Announce, meta.Trackers(), meta.WebSeeds())
+	}
+	if res.NewInfoHash != res.InfoHash {
+		t.Errorf("infohash changed to %s", res.NewInfoHash)
+	}
+}
+
+func TestEditTorrentPrivate(t *testing.T) {
+	data := readFixture(t, "v1multi.torrent")
+	public, private := false, true
+
+	// 修改private需要确认
+	res, err := EditTorrent(data, EditOptions{Private: &public})
+	if err != ErrInfoChange {
+		t.Fatalf("err = %v, want ErrInfoChange", err)
+	}
+	if len(res.Warnings) != 1 || res.Data != nil {
+		t.Errorf("warnings %v, data %d bytes", res.Warnings, len(res.Data))
+	}
+
+	// 与原值相同时不修改
+	res, err = EditTorrent(data, EditOptions{Private: &private})
+	if err != nil || len(res.Warnings) != 0 || res.NewInfoHash != res.InfoHash {
+		t.Errorf("unchanged private: %v, %v, %s", err, res.Warnings, res.NewInfoHash)
+	}
+
+	// 确认后infohash改变
+	res, err = EditTorrent(data, EditOptions{Private: &public, AllowInfoChange: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Warnings) != 1 || res.NewInfoHash == res.InfoHash {
+		t.Errorf("warnings %v, infohash %s -> %s", res.Warnings, res.InfoHash, res.NewInfoHash)
+	}
+	meta, err := ReadTorrent(bytes.NewReader(res.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Info.Private != 0 || meta.InfoHash != res.NewInfoHash || meta.Info.Source != "TEST" {
+		t.Errorf("private %d, infohash %s, source %q", meta.Info.Private, meta.InfoHash, meta.Info.Source)
+	}
+
+	// 改回私有种子后infohash恢复
+	res, err = EditTorrent(res.Data, EditOptions{Private: &private, AllowInfoChange: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NewInfoHash != "C2B8034ADB94D5CFFD8F5406DB981CCA4DAB5AE1" {
+		t.Errorf("infohash %s after restoring private", res.NewInfoHash)
+	}
+}
+
+func TestEditTorrentInvalid(t *testing.T) {
+	if _, err := EditTorrent([]byte("not a torrent"), EditOptions{}); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,39 @@
+// 文件列表数据转换
+package common
+
+import (
+	"strings"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 转换单个种子的文件列表, 同时去掉填充文件并重新计算数量与大小
+func migrateInfoFiles(info *models.SC_Info) {
+	files := make([]models.File, 0, len(info.Files))
+	var length, count int64
+
+	for _, f := range info.Files {
+		parts := f.Parts
+		if len(parts) == 0 {
+			parts = strings.Split(f.Path, "/")
+		}
+
+		file := NewFile(parts, f.Length, f.Attr)
+		file.Md5sum = f.Md5sum
+		file.PiecesRoot = f.PiecesRoot
+		file.Raw = f.Raw
+		if file.IsPadding() {
+			continue
+		}
+
+		files = append(files, file)
+		length += file.Length
+		count++
+	}
+
+	info.Files = files
+	info.Length = length
+	info.FileCount = count
+	info.FileList = []*models.FileTree{BuildFileTree(files)}
+	info.Category = Classify(info.Caption, files)
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func TestMigrateInfoFiles(t *testing.T) {
+	// 旧数据只有路径与长度, 数量与大小包含填充文件
+	info := &models.SC_Info{
+		Caption:   "Movie.2015.1080p.BluRay.x264",
+		Length:    1010 + 20 + 30,
+		FileCount: 5,
+		Files: []models.File{
+			{Path: "Movie/Movie.mkv", Length: 1000},
+			{Path: "Movie/.pad/0", Length: 20, Attr: "p"},
+			{Path: "Movie/_____padding_file_0_如果您看到此文件，请升级到BitComet(比特彗星)0.85或以上版本____", Length: 30},
+			{Path: "Movie/Subs/en.srt", Length: 8, Md5sum: "abc"},
+			{Parts: []string{"Movie", "run.sh"}, Length: 2, Attr: "x"},
+		},
+	}
+
+	migrateInfoFiles(info)
+
+	want := []models.File{
+		{Path: "Movie/Movie.mkv", Parts: []string{"Movie", "Movie.mkv"}, Length: 1000, Ext: "mkv", Media: MediaVideo},
+		{Path: "Movie/Subs/en.srt", Parts: []string{"Movie", "Subs", "en.srt"}, Length: 8, Ext: "srt", Media: FileMedia("srt"), Md5sum: "abc"},
+		{Path: "Movie/run.sh", Parts: []string{"Movie", "run.sh"}, Length: 2, Ext: "sh", Media: FileMedia("sh"), Attr: "x"},
+	}
+	if !reflect.DeepEqual(info.Files, want) {
+		t.Errorf("files\n got %+v\nwant %+v", info.Files, want)
+	}
+	if info.Length != 1010 || info.FileCount != 3 {
+		t.Errorf("length %d, count %d", info.Length, info.FileCount)
+	}
+
+	// 文件树中也不包含填充文件
+	if len(info.FileList) != 1 {
+		t.Fatalf("file list %+v", info.FileList)
+	}
+	movie := info.FileList[0].Dirs[0]
+	if info.FileList[0].Size != 1010 || len(movie.Dirs) != 1 || len(movie.Files) != 2 {
+		t.Errorf("tree %+v", movie)
+	}
+	if info.Category != MediaVideo {
+		t.Errorf("category = %q", info.Category)
+	}
+
+	// 再次转换结果不变
+	files := info.Files
+	migrateInfoFiles(info)
+	if !reflect.DeepEqual(info.Files, files) || info.Length != 1010 || info.FileCount != 3 {
+		t.Errorf("second migration changed files: %+v", info.Files)
+	}
+}
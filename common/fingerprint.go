@@ -0,0 +1,81 @@
+// 内容指纹
+package common
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 由文件列表计算内容指纹, 不同tracker, 填充文件或大小写不同的种子指纹相同
+// 只有一个文件时同样比较文件名, 避免扩展名与大小相同的不同内容被合并
+func Fingerprint(files []models.File) string {
+	var entries []string
+	for _, f := range files {
+		if f.IsPadding() {
+			continue
+		}
+
+		path := f.Path
+		if len(f.Parts) > 0 {
+			path = strings.Join(f.Parts, "/")
+		}
+		path = strings.ToLower(strings.TrimSpace(path))
+
+		entries = append(entries, fmt.Sprintf("%s\x00%d", path, f.Length))
+	}
+
+	if len(entries) == 0 {
+		return ""
+	}
+
+	sort.Strings(entries)
+	sum := sha1.Sum([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// 重新选出since之后获取过的种子所在分组中显示的种子, 最多处理limit个种子, 返回处理的分组数量
+func RefreshGroups(since time.Time, limit int) (int, error) {
+	done := make(map[string]bool)
+	for _, swarm := range models.Db.TrendingSwarms(since, limit) {
+		scinfo, ok := models.Db.GetInfo(swarm.InfoHash)
+		if !ok || scinfo.Fingerprint == "" || done[scinfo.Fingerprint] {
+			continue
+		}
+		done[scinfo.Fingerprint] = true
+
+		if err := models.RefreshGroup(scinfo.Fingerprint); err != nil {
+			return len(done), err
+		}
+	}
+
+	return len(done), nil
+}
+
+// 按配置的间隔定时更新近期获取过的种子所在分组, 热度变化后由热度最高的种子显示, 间隔为0时不启动
+func GroupJanitor() {
+	interval := beego.AppConfig.DefaultInt("groupinterval", 600)
+	if interval <= 0 {
+		return
+	}
+
+	wait := time.Duration(interval) * time.Second
+	since := time.Now()
+	for {
+		time.Sleep(wait)
+
+		// 获取记录由缓冲延迟写入, 多处理一个间隔以免遗漏
+		now := time.Now()
+		if _, err := RefreshGroups(since.Add(-wait), 5000); err != nil {
+			beego.Error("refresh groups: " + err.Error())
+			continue
+		}
+		since = now
+	}
+}
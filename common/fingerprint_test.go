@@ -0,0 +1,67 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func TestFingerprint(t *testing.T) {
+	files := []models.File{
+		{Path: "Album/a.flac", Parts: []string{"Album", "a.flac"}, Length: 100},
+		{Path: "Album/b.txt", Parts: []string{"Album", "b.txt"}, Length: 10},
+	}
+	fp := Fingerprint(files)
+
+	// 顺序, 大小写与填充文件不影响指纹
+	same := []models.File{
+		{Path: "album/B.TXT", Parts: []string{"album", "B.TXT"}, Length: 10},
+		{Path: ".pad/50", Parts: []string{".pad", "50"}, Length: 50, Attr: "p"},
+		{Path: "album/a.flac ", Parts: []string{"album", "a.flac "}, Length: 100},
+	}
+	if got := Fingerprint(same); got != fp {
+		t.Errorf("Fingerprint = %s, want %s", got, fp)
+	}
+	if Fingerprint(files[:1]) == fp || Fingerprint(nil) != "" {
+		t.Error("different files have the same fingerprint")
+	}
+
+	// 单文件种子同样比较文件名
+	a := Fingerprint([]models.File{{Path: "Movie.2019.mkv", Length: 1 << 30}})
+	if b := Fingerprint([]models.File{{Path: "movie.2019.MKV", Length: 1 << 30}}); a != b {
+		t.Errorf("case changed single file fingerprint: %s != %s", a, b)
+	}
+	if b := Fingerprint([]models.File{{Path: "Other.2020.mkv", Length: 1 << 30}}); a == b {
+		t.Error("single files with different names have the same fingerprint")
+	}
+}
+
+func TestRefreshGroups(t *testing.T) {
+	store := useMemoryStore(t)
+	now := time.Now()
+	for _, info := range []models.SC_Info{
+		{InfoHash: "A", Hot: 5, Fingerprint: "fp1"},
+		{InfoHash: "B", Hot: 9, Fingerprint: "fp1", Duplicate: true},
+		{InfoHash: "C", Hot: 1, Fingerprint: "fp2"},
+		{InfoHash: "D", Hot: 8, Fingerprint: "fp2", Duplicate: true},
+	} {
+		info := info
+		store.SaveInfo(&info)
+	}
+	store.AddSwarm("A", now, now, map[string]int64{models.LogDay(now): 1})
+	store.AddSwarm("B", now, now, map[string]int64{models.LogDay(now): 1})
+	past := now.Add(-time.Hour)
+	store.AddSwarm("D", past, past, map[string]int64{models.LogDay(past): 1})
+
+	// 只处理近期获取过的种子所在分组
+	n, err := RefreshGroups(now.Add(-time.Minute), 100)
+	if err != nil || n != 1 {
+		t.Fatalf("RefreshGroups = %d, %v", n, err)
+	}
+	for hash, duplicate := range map[string]bool{"A": true, "B": false, "C": false, "D": true} {
+		if info, _ := store.GetInfo(hash); info.Duplicate != duplicate {
+			t.Errorf("%s duplicate = %v", hash, info.Duplicate)
+		}
+	}
+}
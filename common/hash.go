@@ -0,0 +1,27 @@
+package common
+
+import (
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 保存手动添加的hash, put表示种子是否已入库
+func SaveHash(hash string, put bool) {
+	// 定义一个SC_Hash
+	var schash models.SC_Hash
+	// 设置SC_Hash
+	schash.Hot = 1
+	schash.IsPut = put
+	schash.InfoHash = hash
+	// 不存在则插入, 已存在则累加热度, 并发添加同一hash时只有插入的一方计入统计
+	isNew, err := schash.Save()
+	if err != nil {
+		return
+	}
+	if isNew {
+		// 自增统计数据
+		models.SaveLog(models.LogDht)
+	} else if put {
+		// 已存在时设置为已入库
+		models.SetPut(hash)
+	}
+}
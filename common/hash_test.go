@@ -0,0 +1,38 @@
+package common
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func TestSaveHashConcurrent(t *testing.T) {
+	store := useMemoryStore(t)
+	hash := "C2B8034ADB94D5CFFD8F5406DB981CCA4DAB5AE1"
+
+	// 并发添加同一hash时只插入一次, 其余累加热度
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			SaveHash(hash, false)
+		}()
+	}
+	wg.Wait()
+
+	if n := store.GetLog(models.LogDay(time.Now())).DhtNums; n != 1 {
+		t.Errorf("DhtNums = %d, want 1", n)
+	}
+	if h, ok := store.GetHash(hash); !ok || h.Hot != 10 || h.IsPut {
+		t.Errorf("hash = %+v, %v", h, ok)
+	}
+
+	// 已存在的hash设置为已入库
+	SaveHash(hash, true)
+	if !store.IsPut(hash) {
+		t.Error("hash not marked as put")
+	}
+}
@@ -0,0 +1,257 @@
+// 种子目录导入
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 导入状态
+const (
+	ImportOK      = "ok"      // 入库成功
+	ImportSkipped = "skipped" // 已入库, 跳过
+	ImportInvalid = "invalid" // 不是有效的种子文件
+	ImportFailed  = "failed"  // 入库失败
+)
+
+// 单个文件的导入结果
+type ImportResult struct {
+	Path     string `json:"path"`               // 文件路径
+	InfoHash string `json:"infohash,omitempty"` // InfoHash
+	Status   string `json:"status"`             // 导入状态
+	Error    string `json:"error,omitempty"`    // 错误信息
+}
+
+// 导入统计
+type ImportStats struct {
+	OK      int `json:"ok"`      // 入库成功数量
+	Skipped int `json:"skipped"` // 跳过数量
+	Invalid int `json:"invalid"` // 无效文件数量
+	Failed  int `json:"failed"`  // 失败数量
+}
+
+// 文件状态, 用于判断文件是否变化
+type fileState struct {
+	size int64
+	mod  time.Time
+}
+
+// 目录导入器结构
+type Importer struct {
+	Dir      string             // 种子目录
+	Batch    int                // 每批处理的文件数量
+	Interval time.Duration      // 监视模式下的扫描间隔
+	Report   func(ImportResult) // 每个文件处理完成后的回调
+
+	done    map[string]fileState // 已处理的文件
+	pending map[string]fileState // 监视模式下等待写入完成的文件
+	stats   ImportStats          // 导入统计
+}
+
+// 待入库的种子
+type importItem struct {
+	path string
+	meta MetaInfo
+}
+
+// 创建目录导入器
+func NewImporter(dir string, batch int) *Importer {
+	if batch < 1 {
+		batch = 100
+	}
+
+	return &Importer{
+		Dir:      dir,
+		Batch:    batch,
+		Interval: 10 * time.Second,
+		done:     make(map[string]fileState),
+		pending:  make(map[string]fileState),
+	}
+}
+
+// 获取导入统计
+func (im *Importer) Stats() ImportStats {
+	return im.stats
+}
+
+// 递归扫描目录并导入所有未处理过的种子文件
+func (im *Importer) Scan() error {
+	files, err := im.list()
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for path, state := range files {
+		if old, ok := im.done[path]; ok && old == state {
+			continue
+		}
+		paths = append(paths, path)
+	}
+
+	im.run(paths, files)
+	return nil
+}
+
+// 监视目录, 持续导入新放入的种子文件, 直到stop被关闭
+func (im *Importer) Watch(stop <-chan struct{}) error {
+	// 先导入已有文件
+	if err := im.Scan(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(im.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		files, err := im.list()
+		if err != nil {
+			return err
+		}
+
+		// 两次扫描间大小与修改时间都未变化才认为文件已写入完成
+		var paths []string
+		for path, state := range files {
+			if old, ok := im.done[path]; ok && old == state {
+				continue
+			}
+			if old, ok := im.pending[path]; ok && old == state {
+				delete(im.pending, path)
+				paths = append(paths, path)
+				continue
+			}
+			im.pending[path] = state
+		}
+
+		// 清理已被删除的文件
+		for path := range im.pending {
+			if _, ok := files[path]; !ok {
+				delete(im.pending, path)
+			}
+		}
+
+		im.run(paths, files)
+	}
+}
+
+// 列出目录下所有种子文件
+func (im *Importer) list() (map[string]fileState, error) {
+	files := make(map[string]fileState)
+
+	err := filepath.Walk(im.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// 无法读取的文件单独报告, 不影响其它文件
+			im.report(ImportResult{Path: path, Status: ImportFailed, Error: err.Error()})
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".torrent") {
+			return nil
+		}
+
+		files[path] = fileState{size: info.Size(), mod: info.ModTime()}
+		return nil
+	})
+
+	return files, err
+}
+
+// 分批处理文件
+func (im *Importer) run(paths []string, files map[string]fileState) {
+	// 按路径顺序处理
+	sort.Strings(paths)
+
+	for len(paths) > 0 {
+		n := im.Batch
+		if n > len(paths) {
+			n = len(paths)
+		}
+
+		im.batch(paths[:n])
+		for _, path := range paths[:n] {
+			im.done[path] = files[path]
+		}
+		paths = paths[n:]
+	}
+}
+
+// 处理一批文件
+func (im *Importer) batch(paths []string) {
+	var items []importItem
+	var hashes []string
+
+	// 读取种子信息
+	for _, path := range paths {
+		meta, err := ReadTorrentFile(path)
+		if err != nil {
+			im.report(ImportResult{Path: path, Status: ImportInvalid, Error: err.Error()})
+			continue
+		}
+
+		meta.InfoHash = strings.ToUpper(meta.InfoHash)
+		items = append(items, importItem{path: path, meta: meta})
+		hashes = append(hashes, meta.InfoHash)
+	}
+
+	if len(items) == 0 {
+		return
+	}
+
+	// 一次查询本批中已入库的infohash
+	has := models.InfoHashes(hashes)
+
+	for _, item := range items {
+		res := ImportResult{Path: item.path, InfoHash: item.meta.InfoHash}
+
+		if has[item.meta.InfoHash] {
+			res.Status = ImportSkipped
+			im.report(res)
+			continue
+		}
+
+		if err := PutTorrent(item.meta); err != nil {
+			res.Status = ImportFailed
+			res.Error = err.Error()
+			im.report(res)
+			continue
+		}
+
+		// 同一批次中重复的种子只入库一次
+		has[item.meta.InfoHash] = true
+		SaveHash(item.meta.InfoHash, true)
+
+		res.Status = ImportOK
+		im.report(res)
+	}
+}
+
+// 记录并报告结果
+func (im *Importer) report(res ImportResult) {
+	switch res.Status {
+	case ImportOK:
+		im.stats.OK++
+	case ImportSkipped:
+		im.stats.Skipped++
+	case ImportInvalid:
+		im.stats.Invalid++
+	default:
+		im.stats.Failed++
+	}
+
+	if im.Report != nil {
+		im.Report(res)
+	}
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 写入测试文件
+func writeFile(t *testing.T, path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImporterScan(t *testing.T) {
+	v1, v2 := readFixture(t, "v1multi.torrent"), readFixture(t, "v2.torrent")
+	useMemoryStore(t)
+	inTempDir(t)
+
+	writeFile(t, "in/a.torrent", v1)
+	writeFile(t, "in/b.TORRENT", v2)
+	writeFile(t, "in/broken.torrent", []byte("not bencode"))
+	writeFile(t, "in/readme.txt", []byte("ignored"))
+	// 同一批次中重复的种子
+	writeFile(t, "in/sub/copy.torrent", v1)
+
+	results := make(map[string]ImportResult)
+	im := NewImporter("in", 10)
+	im.Report = func(res ImportResult) {
+		results[filepath.ToSlash(res.Path)] = res
+	}
+	if err := im.Scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]ImportResult{
+		"in/a.torrent": {InfoHash: "C2B8034ADB94D5CFFD8F5406DB981CCA4DAB5AE1", Status: ImportOK},
+		// 纯v2种子使用截断的v2 infohash
+		"in/b.TORRENT":        {InfoHash: "C42EB94B4AF2684552B7363BD590826204C37CB8", Status: ImportOK},
+		"in/broken.torrent":   {Status: ImportInvalid},
+		"in/sub/copy.torrent": {InfoHash: "C2B8034ADB94D5CFFD8F5406DB981CCA4DAB5AE1", Status: ImportSkipped},
+	}
+	if len(results) != len(want) {
+		t.Errorf("results %v", results)
+	}
+	for path, w := range want {
+		got := results[path]
+		if got.Status != w.Status || got.InfoHash != w.InfoHash {
+			t.Errorf("%s: %s %s (%s), want %s %s", path, got.Status, got.InfoHash, got.Error, w.Status, w.InfoHash)
+		}
+	}
+	if stats := im.Stats(); stats != (ImportStats{OK: 2, Skipped: 1, Invalid: 1}) {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// 未变化的文件不再处理
+	if err := im.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := im.Stats(); stats != (ImportStats{OK: 2, Skipped: 1, Invalid: 1}) {
+		t.Errorf("stats after rescan = %+v", stats)
+	}
+
+	// 新的导入器跳过已入库的种子
+	again := NewImporter("in", 1)
+	if err := again.Scan(); err != nil {
+		t.Fatal(err)
+	}
+	if stats := again.Stats(); stats != (ImportStats{Skipped: 3, Invalid: 1}) {
+		t.Errorf("stats of second import = %+v", stats)
+	}
+}
+
+func TestImporterWatch(t *testing.T) {
+	v1 := readFixture(t, "v1multi.torrent")
+	useMemoryStore(t)
+	inTempDir(t)
+	if err := os.Mkdir("in", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	reports := make(chan ImportResult, 10)
+	im := NewImporter("in", 10)
+	im.Interval = 10 * time.Millisecond
+	im.Report = func(res ImportResult) {
+		reports <- res
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- im.Watch(stop)
+	}()
+
+	// 监视过程中放入的文件写入完成后导入
+	writeFile(t, "in/new.torrent", v1)
+	select {
+	case res := <-reports:
+		if res.Status != ImportOK || res.InfoHash != "C2B8034ADB94D5CFFD8F5406DB981CCA4DAB5AE1" {
+			t.Errorf("result = %+v", res)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("new file not imported")
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if stats := im.Stats(); stats != (ImportStats{OK: 1}) {
+		t.Errorf("stats = %+v", stats)
+	}
+}
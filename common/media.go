@@ -0,0 +1,73 @@
+// 文件媒体类型
+package common
+
+import (
+	"path"
+	"strings"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 媒体类型
+const (
+	MediaVideo    = "video"    // 视频
+	MediaAudio    = "audio"    // 音频
+	MediaImage    = "image"    // 图片
+	MediaArchive  = "archive"  // 压缩包及光盘镜像
+	MediaEbook    = "ebook"    // 电子书及文档
+	MediaSoftware = "software" // 软件
+	MediaOther    = "other"    // 其它
+)
+
+// 扩展名对应的媒体类型
+var mediaExts = map[string]string{}
+
+func init() {
+	for media, exts := range map[string]string{
+		MediaVideo:    "mkv avi rm rmvb wmv mp4 m4v mov mpg mpeg ts m2ts vob flv webm 3gp f4v divx xvid ogm",
+		MediaAudio:    "mp3 wma flac ape wav aac m4a ogg opus alac dts ac3 mka tta wv cue mid",
+		MediaImage:    "jpg jpeg png bmp gif webp tif tiff psd raw nef cr2 heic svg",
+		MediaArchive:  "rar zip 7z gz tgz bz2 xz tar z arj ace cab iso img bin mdf mds nrg r00 001",
+		MediaEbook:    "pdf epub mobi azw azw3 djvu chm fb2 txt rtf doc docx odt cbr cbz lit",
+		MediaSoftware: "exe msi dmg pkg deb rpm apk ipa appimage jar bat sh app",
+	} {
+		for _, ext := range strings.Fields(exts) {
+			mediaExts[ext] = media
+		}
+	}
+}
+
+// 获取小写扩展名, 不含.
+func FileExt(name string) string {
+	ext := path.Ext(name)
+	if len(ext) < 2 {
+		return ""
+	}
+
+	return strings.ToLower(ext[1:])
+}
+
+// 根据扩展名获取媒体类型
+func FileMedia(ext string) string {
+	if media, ok := mediaExts[strings.ToLower(ext)]; ok {
+		return media
+	}
+
+	return MediaOther
+}
+
+// 创建文件信息
+func NewFile(parts []string, length int64, attr string) models.File {
+	file := models.File{
+		Path:   strings.Join(parts, "/"),
+		Parts:  parts,
+		Length: length,
+		Attr:   attr,
+	}
+	if len(parts) > 0 {
+		file.Ext = FileExt(parts[len(parts)-1])
+	}
+	file.Media = FileMedia(file.Ext)
+
+	return file
+}
@@ -0,0 +1,179 @@
+// 数据迁移
+package common
+
+import (
+	"time"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 数据迁移, 分批遍历需要处理的种子并保存转换结果
+type Migration struct {
+	Version int                              // 迁移版本, 按版本顺序执行, 已发布的版本不可修改
+	Name    string                           // 迁移名称, 作为SC_Meta的编号
+	Missing string                           // 需要处理的种子, 见models中Missing开头的常量, 为空时处理全部种子
+	Convert func(info *models.SC_Info)       // 转换单个种子, 需可重复执行
+	Saved   func(info *models.SC_Info) error // 种子保存后的处理, 可为空
+}
+
+// 所有数据迁移, 新的迁移追加到末尾
+var Migrations = []Migration{
+	{Version: 1, Name: "files", Missing: models.MissingParts, Convert: migrateInfoFiles},
+	{Version: 2, Name: "categories", Missing: models.MissingCategory, Convert: func(info *models.SC_Info) {
+		info.Category = Classify(info.Caption, info.Files)
+	}},
+	// 无法解析的种子保存为null, 不会再次匹配
+	{Version: 3, Name: "releases", Missing: models.MissingRelease, Convert: func(info *models.SC_Info) {
+		info.Release = ParseRelease(info.Caption)
+	}},
+	// 每个种子保存后重新选出同一指纹中热度最高的种子, 最后一个种子处理完成时分组即为完整的
+	{Version: 4, Name: "fingerprints", Missing: models.MissingFingerprint, Convert: func(info *models.SC_Info) {
+		info.Fingerprint = Fingerprint(info.Files)
+	}, Saved: func(info *models.SC_Info) error {
+		return models.RefreshGroup(info.Fingerprint)
+	}},
+}
+
+// 迁移进度
+type MigrateProgress struct {
+	Version int    `json:"version"`          // 迁移版本
+	Name    string `json:"name"`             // 迁移名称
+	Count   int    `json:"count"`            // 已处理数量, 包含中断前处理的部分
+	Total   int    `json:"total"`            // 需要处理的总数量
+	Done    bool   `json:"done"`             // 是否已完成
+	DryRun  bool   `json:"dryrun,omitempty"` // 是否为试运行
+}
+
+// 数据迁移执行器
+type Migrator struct {
+	Batch  int                   // 每批处理的种子数量, 每批完成后保存进度
+	DryRun bool                  // 试运行, 只遍历统计数量, 不保存种子与进度
+	Report func(MigrateProgress) // 每批处理完成后的回调
+
+	migrations []Migration
+}
+
+// 创建数据迁移执行器
+func NewMigrator(batch int) *Migrator {
+	if batch < 1 {
+		batch = 100
+	}
+
+	return &Migrator{Batch: batch, migrations: Migrations}
+}
+
+// 获取所有迁移的执行进度, 未执行过的只有名称与版本
+func (m *Migrator) List() []models.SC_Meta {
+	var metas []models.SC_Meta
+	for _, mg := range m.migrations {
+		metas = append(metas, m.meta(mg))
+	}
+	return metas
+}
+
+// 未完成的迁移数量
+func (m *Migrator) Pending() int {
+	n := 0
+	for _, mg := range m.migrations {
+		if !m.meta(mg).Done {
+			n++
+		}
+	}
+	return n
+}
+
+// 获取迁移进度, 不存在时返回初始进度
+func (m *Migrator) meta(mg Migration) models.SC_Meta {
+	meta, ok := models.Db.GetMeta(mg.Name)
+	if !ok {
+		meta = models.SC_Meta{Id: mg.Name}
+	}
+	meta.Version = mg.Version
+	return meta
+}
+
+// 按版本顺序执行未完成的迁移, 出错时停止, 已处理的进度保留到下次继续
+func (m *Migrator) Run() error {
+	for _, mg := range m.migrations {
+		if err := m.run(mg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 执行单个迁移
+func (m *Migrator) run(mg Migration) error {
+	meta := m.meta(mg)
+	if meta.Done {
+		return nil
+	}
+	if meta.StartTime.IsZero() {
+		meta.StartTime = time.Now()
+	}
+
+	// 从上次中断的位置继续, 按编号顺序遍历, 转换后仍满足条件的数据也不会重复处理
+	query := models.InfoQuery{Missing: mg.Missing, After: meta.Cursor}
+	progress := MigrateProgress{
+		Version: mg.Version,
+		Name:    mg.Name,
+		Count:   meta.Count,
+		Total:   meta.Count + models.Db.CountInfos(query),
+		DryRun:  m.DryRun,
+	}
+
+	for {
+		infos := models.Db.FindInfos(query, 0, m.Batch, "_id")
+		if len(infos) == 0 {
+			break
+		}
+
+		for i := range infos {
+			if !m.DryRun {
+				mg.Convert(&infos[i])
+				if _, err := models.Db.SaveInfo(&infos[i]); err != nil {
+					return err
+				}
+				if mg.Saved != nil {
+					if err := mg.Saved(&infos[i]); err != nil {
+						return err
+					}
+				}
+			}
+			progress.Count++
+		}
+		query.After = infos[len(infos)-1].Id
+
+		// 每批完成后保存进度
+		if !m.DryRun {
+			meta.Cursor = query.After
+			meta.Count = progress.Count
+			if err := models.Db.SaveMeta(&meta); err != nil {
+				return err
+			}
+		}
+		m.report(progress)
+	}
+
+	// 处理期间新增的数据也已遍历, 总数量以实际处理的为准
+	progress.Total = progress.Count
+	progress.Done = true
+	if !m.DryRun {
+		meta.Count = progress.Count
+		meta.Done = true
+		meta.DoneTime = time.Now()
+		if err := models.Db.SaveMeta(&meta); err != nil {
+			return err
+		}
+	}
+	m.report(progress)
+
+	return nil
+}
+
+// 回调迁移进度
+func (m *Migrator) report(progress MigrateProgress) {
+	if m.Report != nil {
+		m.Report(progress)
+	}
+}
@@ -0,0 +1,160 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 写入没有分类的种子
+func saveMigrateInfos(t *testing.T, n int) *models.MemoryStore {
+	store := useMemoryStore(t)
+	for i := 0; i < n; i++ {
+		store.SaveInfo(&models.SC_Info{InfoHash: fmt.Sprintf("H%d", i), Caption: fmt.Sprintf("info %d", i)})
+	}
+	return store
+}
+
+// 给种子设置分类的测试迁移, 记录处理过的种子
+func testMigration(converted *[]string, saved func(info *models.SC_Info) error) Migration {
+	return Migration{
+		Version: 1,
+		Name:    "test",
+		Missing: models.MissingCategory,
+		Convert: func(info *models.SC_Info) {
+			*converted = append(*converted, info.InfoHash)
+			info.Category = MediaOther
+		},
+		Saved: saved,
+	}
+}
+
+func TestMigratorResume(t *testing.T) {
+	store := saveMigrateInfos(t, 5)
+
+	// 第三个种子保存后出错, 模拟中断
+	var converted []string
+	fail := errors.New("interrupted")
+	m := NewMigrator(2)
+	m.migrations = []Migration{testMigration(&converted, func(info *models.SC_Info) error {
+		if info.InfoHash == "H2" {
+			return fail
+		}
+		return nil
+	})}
+	if err := m.Run(); err != fail {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	// 只保存了第一批的进度
+	meta, ok := store.GetMeta("test")
+	if !ok || meta.Done || meta.Count != 2 || meta.Cursor == "" {
+		t.Fatalf("meta after interrupt = %+v", meta)
+	}
+	if m.Pending() != 1 {
+		t.Errorf("pending = %d", m.Pending())
+	}
+
+	// 从中断位置继续, 已处理的种子不再转换
+	converted = nil
+	var reports []MigrateProgress
+	m = NewMigrator(2)
+	m.migrations = []Migration{testMigration(&converted, nil)}
+	m.Report = func(p MigrateProgress) {
+		reports = append(reports, p)
+	}
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(converted, []string{"H3", "H4"}) {
+		t.Errorf("converted %v, want [H3 H4]", converted)
+	}
+	// 总数量包含中断前处理的部分
+	want := []MigrateProgress{
+		{Version: 1, Name: "test", Count: 4, Total: 4},
+		{Version: 1, Name: "test", Count: 4, Total: 4, Done: true},
+	}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("reports %+v, want %+v", reports, want)
+	}
+
+	meta, _ = store.GetMeta("test")
+	if !meta.Done || meta.Count != 4 || meta.DoneTime.IsZero() {
+		t.Errorf("meta after resume = %+v", meta)
+	}
+	if n := store.CountInfos(models.InfoQuery{Missing: models.MissingCategory}); n != 0 {
+		t.Errorf("%d infos left without category", n)
+	}
+
+	// 已完成的迁移不再执行
+	converted = nil
+	if err := m.Run(); err != nil || len(converted) != 0 || m.Pending() != 0 {
+		t.Errorf("rerun converted %v, err %v", converted, err)
+	}
+}
+
+func TestMigratorDryRun(t *testing.T) {
+	store := saveMigrateInfos(t, 5)
+
+	var converted []string
+	var last MigrateProgress
+	m := NewMigrator(2)
+	m.DryRun = true
+	m.migrations = []Migration{testMigration(&converted, nil)}
+	m.Report = func(p MigrateProgress) { last = p }
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 试运行只统计数量, 不转换种子也不保存进度
+	if want := (MigrateProgress{Version: 1, Name: "test", Count: 5, Total: 5, Done: true, DryRun: true}); last != want {
+		t.Errorf("progress %+v, want %+v", last, want)
+	}
+	if len(converted) != 0 {
+		t.Errorf("dry run converted %v", converted)
+	}
+	if _, ok := store.GetMeta("test"); ok {
+		t.Error("dry run saved progress")
+	}
+	if n := store.CountInfos(models.InfoQuery{Missing: models.MissingCategory}); n != 5 {
+		t.Errorf("%d infos left without category, want 5", n)
+	}
+
+	// 实际执行处理全部种子
+	m.DryRun = false
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(converted) != 5 || last.Count != 5 || last.DryRun {
+		t.Errorf("converted %v, progress %+v", converted, last)
+	}
+}
+
+func TestMigrationsConvertLegacyInfo(t *testing.T) {
+	store := useMemoryStore(t)
+	inTempDir(t)
+	store.SaveInfo(&models.SC_Info{
+		InfoHash: "LEGACY",
+		Caption:  "Movie.2015.1080p.BluRay.x264-GROUP",
+		Files: []models.File{
+			{Path: "Movie/movie.mkv", Length: 1000},
+			{Path: "Movie/.pad/0", Length: 24, Attr: "p"},
+		},
+	})
+
+	m := NewMigrator(10)
+	if err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Pending() != 0 {
+		t.Errorf("pending = %d", m.Pending())
+	}
+
+	info, _ := store.GetInfo("LEGACY")
+	if len(info.Files) != 1 || info.Category != MediaVideo || info.Release == nil || info.Release.Group != "GROUP" || info.Fingerprint == "" {
+		t.Errorf("migrated info %+v", info)
+	}
+}
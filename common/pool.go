@@ -0,0 +1,177 @@
+// 入库工作池
+package common
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 入库结果状态
+const (
+	PutSuccess = iota // 入库成功
+	PutSkip           // 已入库, 跳过
+	PutFailed         // 下载失败
+)
+
+// 入库结果结构
+type PutResult struct {
+	InfoHash string        // InfoHash
+	Status   int           // 入库状态
+	Err      error         // 错误信息
+	Elapsed  time.Duration // 处理耗时
+}
+
+// 入库统计结构
+type PutStats struct {
+	Workers int   `json:"workers"` // 工作协程数量
+	Running int64 `json:"running"` // 正在处理的任务数量
+	Success int64 `json:"success"` // 入库成功数量
+	Skip    int64 `json:"skip"`    // 跳过数量
+	Failed  int64 `json:"failed"`  // 失败数量
+}
+
+// 入库工作池结构
+type PutPool struct {
+	workers int                 // 工作协程数量
+	timeout time.Duration       // 单个任务超时时间
+	jobs    chan models.SC_Hash // 任务通道
+	results chan PutResult      // 结果通道
+	ctx     context.Context     // 工作池上下文
+	cancel  context.CancelFunc  // 取消工作池
+	wg      sync.WaitGroup      // 等待工作协程退出
+	once    sync.Once           // 保证只关闭一次
+	stats   PutStats            // 统计数据
+}
+
+// 创建入库工作池
+func NewPutPool(workers int, timeout time.Duration) *PutPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &PutPool{
+		workers: workers,
+		timeout: timeout,
+		jobs:    make(chan models.SC_Hash, workers),
+		results: make(chan PutResult, workers),
+		ctx:     ctx,
+		cancel:  cancel,
+		stats:   PutStats{Workers: workers},
+	}
+}
+
+// 启动工作协程
+func (p *PutPool) Start() {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+// 提交任务, 工作池已停止时返回false
+func (p *PutPool) Submit(schash models.SC_Hash) bool {
+	select {
+	case <-p.ctx.Done():
+		return false
+	case p.jobs <- schash:
+		return true
+	}
+}
+
+// 结果通道
+func (p *PutPool) Results() <-chan PutResult {
+	return p.results
+}
+
+// 停止工作池, 等待所有工作协程退出后关闭结果通道
+func (p *PutPool) Stop() {
+	p.once.Do(func() {
+		p.cancel()
+		p.wg.Wait()
+		close(p.results)
+	})
+}
+
+// 记录一条结果到统计数据
+func (p *PutPool) Record(res PutResult) {
+	switch res.Status {
+	case PutSuccess:
+		atomic.AddInt64(&p.stats.Success, 1)
+	case PutSkip:
+		atomic.AddInt64(&p.stats.Skip, 1)
+	default:
+		atomic.AddInt64(&p.stats.Failed, 1)
+	}
+}
+
+// 获取统计数据
+func (p *PutPool) Stats() PutStats {
+	return PutStats{
+		Workers: p.workers,
+		Running: atomic.LoadInt64(&p.stats.Running),
+		Success: atomic.LoadInt64(&p.stats.Success),
+		Skip:    atomic.LoadInt64(&p.stats.Skip),
+		Failed:  atomic.LoadInt64(&p.stats.Failed),
+	}
+}
+
+// 工作协程
+func (p *PutPool) work() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case schash := <-p.jobs:
+			res := p.do(schash)
+
+			select {
+			case p.results <- res:
+			case <-p.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// 处理单个任务
+func (p *PutPool) do(schash models.SC_Hash) PutResult {
+	atomic.AddInt64(&p.stats.Running, 1)
+	defer atomic.AddInt64(&p.stats.Running, -1)
+
+	start := time.Now()
+	hash := strings.ToUpper(schash.InfoHash)
+	res := PutResult{InfoHash: hash}
+
+	// 检查infohash是否已经入库
+	if models.Db.HasInfo(hash) {
+		// 将hash设置为已入库
+		models.SetPut(hash)
+		res.Status = PutSkip
+		res.Elapsed = time.Since(start)
+		return res
+	}
+
+	// 每个任务使用独立的超时上下文
+	ctx, cancel := context.WithTimeout(p.ctx, p.timeout)
+	defer cancel()
+
+	ret, err := PullTorrentContext(ctx, hash)
+	if err == nil && ret == 0 {
+		res.Status = PutSuccess
+	} else {
+		res.Status = PutFailed
+		res.Err = err
+	}
+	res.Elapsed = time.Since(start)
+
+	return res
+}
@@ -0,0 +1,226 @@
+// hash清理
+package common
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// hash保留规则, 为0的规则不执行, 已入库的hash不会被删除
+type PruneRules struct {
+	InvalidDays int `json:"invalid_days"` // 失败超过3次的hash在最后获取后保留的天数
+	ColdDays    int `json:"cold_days"`    // 只获取到一次的hash在最后获取后保留的天数
+	MaxHashes   int `json:"max_hashes"`   // hash总数上限, 超出时删除最久未获取的未入库hash
+}
+
+// 从配置读取保留规则
+func PruneRulesFromConfig() PruneRules {
+	return PruneRules{
+		InvalidDays: beego.AppConfig.DefaultInt("pruneinvalid", 0),
+		ColdDays:    beego.AppConfig.DefaultInt("prunecold", 0),
+		MaxHashes:   beego.AppConfig.DefaultInt("prunemax", 0),
+	}
+}
+
+// 是否有需要执行的规则
+func (r PruneRules) Enabled() bool {
+	return r.InvalidDays > 0 || r.ColdDays > 0 || r.MaxHashes > 0
+}
+
+// 单次清理结果
+type PruneResult struct {
+	Invalid int   `json:"invalid"`          // 删除的无法下载的hash数量
+	Cold    int   `json:"cold"`             // 删除的只获取到一次的hash数量
+	Capped  int   `json:"capped"`           // 超出总数上限删除的hash数量
+	Total   int   `json:"total"`            // 清理后的hash总数
+	DryRun  bool  `json:"dryrun,omitempty"` // 是否为试运行, 试运行时为将要删除的数量
+	Elapsed int64 `json:"elapsed_ms"`       // 耗时, 单位毫秒
+}
+
+// 删除的总数量
+func (r PruneResult) Deleted() int {
+	return r.Invalid + r.Cold + r.Capped
+}
+
+// 按规则清理hash, dryRun为true时只统计将要删除的数量, 每批最多删除batch条
+func Prune(rules PruneRules, dryRun bool, batch int) (PruneResult, error) {
+	if batch < 1 {
+		batch = 1000
+	}
+
+	start := time.Now()
+	result := PruneResult{DryRun: dryRun}
+	var err error
+
+	// 无法下载的hash
+	if rules.InvalidDays > 0 {
+		query := models.HashQuery{Failed: true, Until: start.AddDate(0, 0, -rules.InvalidDays)}
+		if result.Invalid, err = pruneHashes(query, dryRun, batch); err != nil {
+			return result, err
+		}
+	}
+
+	// 只获取到一次且仍可下载的hash
+	if rules.ColdDays > 0 {
+		query := models.HashQuery{Pending: true, MaxHot: 1, Until: start.AddDate(0, 0, -rules.ColdDays)}
+		if result.Cold, err = pruneHashes(query, dryRun, batch); err != nil {
+			return result, err
+		}
+	}
+
+	// 超出总数上限
+	total := models.Db.CountHashes(models.HashQuery{})
+	if dryRun {
+		total -= result.Invalid + result.Cold
+	}
+	if rules.MaxHashes > 0 && total > rules.MaxHashes {
+		if result.Capped, err = capHashes(total-rules.MaxHashes, result.Cold, dryRun, batch); err != nil {
+			return result, err
+		}
+		total -= result.Capped
+	}
+
+	result.Total = total
+	result.Elapsed = int64(time.Since(start) / time.Millisecond)
+	return result, nil
+}
+
+// 删除满足条件的hash, 返回删除的数量
+func pruneHashes(query models.HashQuery, dryRun bool, batch int) (int, error) {
+	if dryRun {
+		return models.Db.CountHashes(query), nil
+	}
+
+	total := 0
+	for {
+		hashes := models.Db.ScanHashes(query, batch)
+		if len(hashes) == 0 {
+			return total, nil
+		}
+
+		n, err := models.Db.DeleteHashes(infoHashes(hashes))
+		total += n
+		if err != nil {
+			return total, err
+		}
+		query.After = hashes[len(hashes)-1].Id
+	}
+}
+
+// 按最后获取时间删除最久未获取的未入库hash, 返回删除的数量
+// cold为之前规则删除的未入库hash数量, 试运行时这些hash仍在库中, 不能重复计算
+func capHashes(excess, cold int, dryRun bool, batch int) (int, error) {
+	// 只能删除未入库的hash
+	pending := models.Db.CountHashes(models.HashQuery{Pending: true})
+	if dryRun {
+		pending -= cold
+	}
+	if excess > pending {
+		excess = pending
+	}
+	if dryRun {
+		return excess, nil
+	}
+
+	total := 0
+	for total < excess {
+		n := excess - total
+		if n > batch {
+			n = batch
+		}
+
+		hashes := models.Db.PendingHashes("lastseen", n)
+		if len(hashes) == 0 {
+			break
+		}
+
+		deleted, err := models.Db.DeleteHashes(infoHashes(hashes))
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted == 0 {
+			break
+		}
+	}
+
+	return total, nil
+}
+
+// 获取hash列表的infohash
+func infoHashes(hashes []models.SC_Hash) []string {
+	list := make([]string, len(hashes))
+	for i, h := range hashes {
+		list[i] = h.InfoHash
+	}
+	return list
+}
+
+// 后台清理统计
+type JanitorStats struct {
+	Rules    PruneRules  `json:"rules"`           // 当前规则
+	Interval int64       `json:"interval"`        // 清理间隔, 单位秒, 0为未启动
+	Runs     int64       `json:"runs"`            // 已执行次数
+	Invalid  int64       `json:"invalid"`         // 累计删除的无法下载的hash数量
+	Cold     int64       `json:"cold"`            // 累计删除的只获取到一次的hash数量
+	Capped   int64       `json:"capped"`          // 累计超出总数上限删除的hash数量
+	LastRun  time.Time   `json:"lastrun"`         // 最后执行时间
+	Last     PruneResult `json:"last"`            // 最后一次清理结果
+	Error    string      `json:"error,omitempty"` // 最后一次清理的错误
+}
+
+var (
+	janitorMu    sync.Mutex
+	janitorStats JanitorStats
+)
+
+// 后台清理统计数据
+func PruneStatus() JanitorStats {
+	janitorMu.Lock()
+	defer janitorMu.Unlock()
+
+	return janitorStats
+}
+
+// 按配置的间隔定时清理hash, 没有规则或间隔为0时不启动
+func Janitor() {
+	rules := PruneRulesFromConfig()
+	interval := beego.AppConfig.DefaultInt("pruneinterval", 3600)
+	if !rules.Enabled() || interval <= 0 {
+		return
+	}
+
+	janitorMu.Lock()
+	janitorStats.Rules = rules
+	janitorStats.Interval = int64(interval)
+	janitorMu.Unlock()
+
+	for {
+		time.Sleep(time.Duration(interval) * time.Second)
+
+		result, err := Prune(rules, false, 1000)
+
+		janitorMu.Lock()
+		janitorStats.Runs++
+		janitorStats.Invalid += int64(result.Invalid)
+		janitorStats.Cold += int64(result.Cold)
+		janitorStats.Capped += int64(result.Capped)
+		janitorStats.LastRun = time.Now()
+		janitorStats.Last = result
+		janitorStats.Error = ""
+		if err != nil {
+			janitorStats.Error = err.Error()
+		}
+		janitorMu.Unlock()
+
+		if err != nil {
+			beego.Error("prune hashes: " + err.Error())
+		} else if result.Deleted() > 0 {
+			beego.Info(fmt.Sprintf("prune hashes: %d invalid, %d cold, %d capped, %d left", result.Invalid, result.Cold, result.Capped, result.Total))
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 清理测试数据
+var pruneFixtures = []struct {
+	hash    string
+	hot     int64
+	invalid int
+	put     bool
+	days    int // 最后获取距今天数
+}{
+	{"invalid-old", 2, 5, false, 10},
+	{"invalid-new", 2, 5, false, 1},
+	{"cold-old", 1, 0, false, 10},
+	{"cold-new", 1, 0, false, 1},
+	{"warm-old", 4, 0, false, 20},
+	{"put-old", 1, 0, true, 30},
+	{"put-invalid", 1, 5, true, 30},
+}
+
+// 写入清理测试数据
+func savePruneHashes(t *testing.T) *models.MemoryStore {
+	store := useMemoryStore(t)
+	for _, h := range pruneFixtures {
+		seen := time.Now().AddDate(0, 0, -h.days)
+		store.ImportHash(&models.SC_Hash{
+			InfoHash:   h.hash,
+			Hot:        h.hot,
+			Invalid:    h.invalid,
+			IsPut:      h.put,
+			CreateTime: seen,
+			LastSeen:   seen,
+		})
+	}
+	return store
+}
+
+// 剩余的hash
+func leftHashes(store *models.MemoryStore) []string {
+	var left []string
+	for _, h := range pruneFixtures {
+		if store.HasHash(h.hash) {
+			left = append(left, h.hash)
+		}
+	}
+	sort.Strings(left)
+	return left
+}
+
+var pruneTests = []struct {
+	name  string
+	rules PruneRules
+	want  PruneResult
+	left  []string
+}{
+	{
+		name:  "invalid",
+		rules: PruneRules{InvalidDays: 7},
+		want:  PruneResult{Invalid: 1, Total: 6},
+		left:  []string{"cold-new", "cold-old", "invalid-new", "put-invalid", "put-old", "warm-old"},
+	},
+	{
+		name:  "cold",
+		rules: PruneRules{ColdDays: 7},
+		want:  PruneResult{Cold: 1, Total: 6},
+		left:  []string{"cold-new", "invalid-new", "invalid-old", "put-invalid", "put-old", "warm-old"},
+	},
+	{
+		name:  "max",
+		rules: PruneRules{MaxHashes: 5},
+		want:  PruneResult{Capped: 2, Total: 5},
+		left:  []string{"cold-new", "invalid-new", "invalid-old", "put-invalid", "put-old"},
+	},
+	{
+		name:  "all",
+		rules: PruneRules{InvalidDays: 7, ColdDays: 7, MaxHashes: 2},
+		want:  PruneResult{Invalid: 1, Cold: 1, Capped: 2, Total: 3},
+		left:  []string{"invalid-new", "put-invalid", "put-old"},
+	},
+}
+
+func TestPruneRules(t *testing.T) {
+	for _, tt := range pruneTests {
+		store := savePruneHashes(t)
+
+		result, err := Prune(tt.rules, false, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		result.Elapsed = 0
+		if result != tt.want {
+			t.Errorf("%s: result = %+v, want %+v", tt.name, result, tt.want)
+		}
+		if left := leftHashes(store); !reflect.DeepEqual(left, tt.left) {
+			t.Errorf("%s: left %v, want %v", tt.name, left, tt.left)
+		}
+	}
+}
+
+func TestPruneDryRun(t *testing.T) {
+	for _, tt := range pruneTests {
+		store := savePruneHashes(t)
+
+		dry, err := Prune(tt.rules, true, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if left := leftHashes(store); len(left) != len(pruneFixtures) {
+			t.Errorf("%s: dry run deleted hashes, left %v", tt.name, left)
+		}
+
+		// 试运行的统计应与实际清理一致
+		run, err := Prune(tt.rules, false, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		dry.DryRun, dry.Elapsed, run.Elapsed = false, 0, 0
+		if dry != run {
+			t.Errorf("%s: dry run %+v, real run %+v", tt.name, dry, run)
+		}
+	}
+}
+
+func TestPruneKeepsPutHashes(t *testing.T) {
+	store := savePruneHashes(t)
+
+	result, err := Prune(PruneRules{InvalidDays: 1, ColdDays: 1, MaxHashes: 1}, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left := leftHashes(store); !reflect.DeepEqual(left, []string{"put-invalid", "put-old"}) {
+		t.Errorf("left %v", left)
+	}
+	if result.Total != 2 {
+		t.Errorf("total = %d, want 2", result.Total)
+	}
+}
@@ -2,6 +2,7 @@
 package common
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
@@ -9,199 +10,211 @@ import (
 	"strings"
 	"time"
 
+	"github.com/astaxie/beego"
 	"github.com/ylqjgm/SCDht/models"
-	"gopkg.in/mgo.v2/bson"
 )
 
-type File struct {
-	Path   string // 文件路径
-	Length int64  // 文件长度
+// 下载种子使用的http客户端
+var pullClient = &http.Client{
+	Transport: &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout: 3 * time.Second,
+		}).Dial,
+		ResponseHeaderTimeout: 10 * time.Second,
+	},
 }
 
+// 当前运行的入库工作池
+var putPool *PutPool
+
 // 种子入库操作
 func PullTorrent(hash string) (int, error) {
-	// 定义一个int变量t
-	t := 0
+	return PullTorrentContext(context.Background(), hash)
+}
 
-	// 无限循环进行入库
-	for {
-		// 定义url和host变量
-		var url, host string
-		// 将infohash转换为大写格式
-		hash = strings.ToUpper(hash)
-
-		// 查看t为多少
-		switch t {
-		case 0:
-			// 为0则使用torrent-cache.bitcomet.org下载
-			url = fmt.Sprintf("http://torrent-cache.bitcomet.org:36869/get_torrent?info_hash=%s&size=226920869&key=%s", strings.ToLower(hash), GetKey(hash))
-			host = "torrent-cache.bitcomet.org"
-			break
-		case 1:
-			// 为1则使用bt.box.n0808.com下载
-			url = fmt.Sprintf("http://bt.box.n0808.com/%s/%s/%s.torrent", hash[0:2], hash[len(hash)-2:], hash)
-			host = "bt.box.n0808.com"
-			break
-		case 2:
-			// 为2则使用torcache.net下载
-			url = fmt.Sprintf("https://torcache.net/torrent/%s.torrent", hash)
-			host = "torcache.net"
-			break
-		default:
-			// 对infohash进行自增处理
-			models.SetAdd(models.DbHash, bson.M{"infohash": hash}, "invalid", true)
-			return 1, nil
+// 种子入库操作, 可通过ctx取消或设置超时
+func PullTorrentContext(ctx context.Context, hash string) (int, error) {
+	// 将infohash转换为大写格式
+	hash = strings.ToUpper(hash)
+	// 是否有下载源实际发出了请求
+	tried := false
+
+	// 依次尝试各个下载源
+	for _, src := range Sources() {
+		// 任务已超时或被取消则直接返回
+		if err := ctx.Err(); err != nil {
+			return 1, cancelTorrent(hash, err)
 		}
 
-		// t自增
-		t++
-
-		// 新建请求
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			// 失败则跳过本次循环
+		// 熔断中的下载源直接跳过
+		if !src.Breaker.Allow() {
 			continue
 		}
-
-		// 设置头部信息
-		req.Header.Add("User-Agent", "Mozilla/5.0")
-		req.Header.Add("Host", host)
-		req.Header.Add("Accept", "*/*")
-		req.Header.Add("Connection", "Keep-Alive")
-
-		// 设置超时时间
-		client := &http.Client{
-			Transport: &http.Transport{
-				Dial: func(netw, addr string) (net.Conn, error) {
-					deadline := time.Now().Add(3 * time.Second)
-					c, err := net.DialTimeout(netw, addr, time.Second*3)
-					if err != nil {
-						return nil, err
-					}
-					c.SetDeadline(deadline)
-					return c, nil
-				},
-			},
+		tried = true
+
+		// 下载种子信息
+		metaTorrent, fault, err := fetchTorrent(ctx, src.URL(hash), src.Host)
+		if ctx.Err() != nil {
+			// 任务超时或取消导致的失败不计入下载源统计
+			src.Breaker.Release()
+			return 1, cancelTorrent(hash, ctx.Err())
 		}
-
-		// 请求链接
-		resp, err := client.Do(req)
-		if err != nil {
-			// 失败则跳过本次循环
-			continue
+		if fault {
+			src.Breaker.Record(err)
+		} else {
+			src.Breaker.Record(nil)
 		}
-		// 保证关闭
-		defer resp.Body.Close()
-
-		// 定义一个MetaInfo
-		var metaTorrent MetaInfo
-		// 读取种子信息
-		metaTorrent, err = ReadTorrent(resp.Body)
 		if err != nil {
-			// 失败则跳过本次循环
+			// 失败则尝试下一个下载源
 			continue
 		}
 
-		err = PutTorrent(metaTorrent)
+		return 0, PutTorrent(metaTorrent)
+	}
+
+	// 所有下载源都在熔断中, 不能说明hash无效
+	if !tried {
+		return 1, ErrNoSource
+	}
+
+	failTorrent(hash)
+	return 1, nil
+}
+
+// 记录一次下载失败, 失败超过3次的hash不再下载
+func failTorrent(hash string) {
+	// 对infohash进行自增处理
+	models.Db.AddInvalid(hash)
+	models.SaveLog(models.LogFail)
+}
+
+// 处理任务超时或取消, 超时计入失败次数, 避免总是超时的hash每轮都被重新下载; 工作池停止导致的取消不计入
+func cancelTorrent(hash string, err error) error {
+	if err == context.DeadlineExceeded {
+		failTorrent(hash)
+	}
+	return err
+}
+
+// 从指定地址下载并读取种子信息, fault表示是否为下载源本身的故障
+func fetchTorrent(ctx context.Context, url, host string) (meta MetaInfo, fault bool, err error) {
+	// 新建请求
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+
+	// 设置头部信息
+	req.Header.Add("User-Agent", "Mozilla/5.0")
+	req.Header.Add("Host", host)
+	req.Header.Add("Accept", "*/*")
+	req.Header.Add("Connection", "Keep-Alive")
+
+	// 请求链接
+	resp, err := pullClient.Do(req)
+	if err != nil {
+		// 连接失败或超时
+		fault = true
+		return
+	}
+	// 保证关闭
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// 服务端错误才算下载源故障, 404等只说明没有此种子
+		fault = resp.StatusCode >= 500
+		err = fmt.Errorf("%s: %s", host, resp.Status)
+		return
+	}
+
+	// 读取种子信息
+	meta, err = ReadTorrent(resp.Body)
+	return
+}
 
-		return 0, err
+// 入库工作池统计数据
+func PutStatus() PutStats {
+	if putPool == nil {
+		return PutStats{}
 	}
+
+	return putPool.Stats()
 }
 
 // 入库主函数
 func Put() {
 	// 设置最大允许使用CPU核心数
 	runtime.GOMAXPROCS(2)
-	// 定义一个通道
-	chReq := make(chan models.SC_Hash, 10)
-	// 定义一个通道
-	chRes := make(chan string, 10)
+
+	// 获取工作协程数量
+	workers := beego.AppConfig.DefaultInt("putworkers", 10)
+	// 获取单个种子下载超时时间
+	timeout := beego.AppConfig.DefaultInt("puttimeout", 30)
+
+	// 创建并启动工作池, 整个运行期间只启动一次
+	putPool = NewPutPool(workers, time.Duration(timeout)*time.Second)
+	putPool.Start()
+
+	// 创建优先级调度器
+	sched := NewScheduler()
 
 	// 无限循环入库种子
 	for {
-		// 获取未入库hash总量
-		allcount := models.Count(models.DbHash, bson.M{"isput": false, "invalid": bson.M{"$lte": 3}})
+		// 按优先级获取100条未入库的infohash, 已处理的hash会被移出查询结果
+		sc_hash := sched.Next(100)
+		// 获取到的总量
+		count := len(sc_hash)
 
 		// 如果数量小于1
-		if allcount < 1 {
+		if count < 1 {
 			// 停顿10秒
 			time.Sleep(10 * time.Second)
 			// 跳过本次循环
 			continue
 		}
 
-		for ai := 0; ai < allcount; {
-			// 定义一个SC_Hash列表
-			var sc_hash []models.SC_Hash
-			// 获取100条infohash
-			models.GetDataByQuery(models.DbHash, ai, (ai + 100), "-hot", bson.M{"isput": false, "invalid": bson.M{"$lte": 3}}, &sc_hash)
-			// 获取到的总量
-			count := len(sc_hash)
-
-			// 循环处理infohash, 每次处理进程为自定义进程数
-			for i := 0; i < 10; i++ {
-				go func() {
-					// 循环处理
-					for {
-						// 循环接受通道传递过来的infohash
-						schash := <-chReq
-
-						// 检查infohash是否已经入库
-						if models.Has(models.DbInfo, bson.M{"infohash": strings.ToUpper(schash.InfoHash)}) {
-							// 入库则输出跳过信息
-							chRes <- fmt.Sprintf("'%s' Skip......", schash.InfoHash)
-							// 将hash设置为已入库
-							models.SetPut(schash.InfoHash)
-							// 跳过本次循环
-							continue
-						}
-
-						// 定义一个string变量, 记录处理结果
-						var str string
-
-						// 入库种子信息
-						ret, err := PullTorrent(schash.InfoHash)
-						if err == nil && ret == 0 {
-							// 设置成功信息
-							str = fmt.Sprintf("Storage InfoHash '%s' Success......", schash.InfoHash)
-						} else {
-							// 设置失败信息
-							str = fmt.Sprintf("Can not download '%s' torrent file......", schash.InfoHash)
-						}
-						// 传递处理结果
-						chRes <- str
-					}
-				}()
-			}
-
-			go func() {
-				// 定义一个hashs列表
-				hashs := make([]models.SC_Hash, count)
-
-				// 循环将infohash加入hashs列表
-				for i := 0; i < count; i++ {
-					hashs[i] = sc_hash[i]
+		// 传递infohash给工作池
+		go func() {
+			for _, schash := range sc_hash {
+				if !putPool.Submit(schash) {
+					return
 				}
+			}
+		}()
+
+		// 循环处理入库结果
+		unavailable := 0
+		for i := 0; i < count; i++ {
+			// 接收入库结果
+			res := <-putPool.Results()
+			// 记录统计数据
+			putPool.Record(res)
+			if res.Err == ErrNoSource {
+				unavailable++
+			}
 
-				// 传递infohash给通道
-				for i := 0; i < count; i++ {
-					chReq <- hashs[i]
-				}
-			}()
-
-			// 循环处理入库结果
-			for i := 0; i < count; i++ {
-				// 接收入库结果
-				str := <-chRes
-
-				// 如果允许显示则显示
-				if models.DbConfig.ShowMsg && str != "" {
-					fmt.Println(str)
-				} else {
-					// 否则不显示
-					_ = str
-				}
+			// 如果允许显示则显示
+			if models.DbConfig.ShowMsg {
+				fmt.Println(res.String())
 			}
 		}
+
+		// 所有下载源都在熔断中, 等待熔断恢复, 不立即重新获取同一批hash
+		if unavailable == count {
+			time.Sleep(10 * time.Second)
+		}
 	}
 }
+
+// 入库结果信息
+func (res PutResult) String() string {
+	switch res.Status {
+	case PutSuccess:
+		return fmt.Sprintf("Storage InfoHash '%s' Success......", res.InfoHash)
+	case PutSkip:
+		return fmt.Sprintf("'%s' Skip......", res.InfoHash)
+	}
+
+	return fmt.Sprintf("Can not download '%s' torrent file......", res.InfoHash)
+}
@@ -0,0 +1,163 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 使用测试下载源替换默认下载源
+func useSources(t *testing.T, handler http.HandlerFunc) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	Sources()
+	old := sources
+	sources = []*Source{{
+		Name:    "test",
+		Host:    "test",
+		URL:     func(hash string) string { return server.URL + "/" + hash },
+		Breaker: NewBreaker(20, 0.5, 5, time.Minute),
+	}}
+	t.Cleanup(func() { sources = old })
+}
+
+// 使用内存存储
+func useMemoryStore(t *testing.T) *models.MemoryStore {
+	store := models.NewMemoryStore()
+	old, oldRead := models.Db, models.ReadDb
+	models.Db, models.ReadDb = store, store
+	t.Cleanup(func() { models.Db, models.ReadDb = old, oldRead })
+	return store
+}
+
+func TestPullTorrentTimeoutCountsAsFailure(t *testing.T) {
+	store := useMemoryStore(t)
+	release := make(chan struct{})
+	defer close(release)
+	useSources(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	hash := "0123456789ABCDEF0123456789ABCDEF01234567"
+	store.SaveHash(&models.SC_Hash{InfoHash: hash})
+
+	for i := 1; i <= 4; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		ret, err := PullTorrentContext(ctx, hash)
+		cancel()
+		if ret != 1 || err != context.DeadlineExceeded {
+			t.Fatalf("attempt %d: got %d, %v", i, ret, err)
+		}
+	}
+
+	// 失败超过3次后不再进入待下载列表
+	for _, schash := range store.PendingHashes("lastseen", 10) {
+		if schash.InfoHash == hash {
+			t.Fatalf("hash still pending after timeouts: invalid=%d", schash.Invalid)
+		}
+	}
+}
+
+func TestPullTorrentCancelNotCounted(t *testing.T) {
+	store := useMemoryStore(t)
+	useSources(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	hash := "89ABCDEF0123456789ABCDEF0123456789ABCDEF"
+	store.SaveHash(&models.SC_Hash{InfoHash: hash})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := PullTorrentContext(ctx, hash); err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	hashes := store.ScanHashes(models.HashQuery{}, 10)
+	if len(hashes) != 1 || hashes[0].Invalid != 0 {
+		t.Fatalf("canceled pull counted as failure: %+v", hashes)
+	}
+}
+
+func TestPutTorrent(t *testing.T) {
+	data := readFixture(t, "v1multi.torrent")
+	store := useMemoryStore(t)
+	inTempDir(t)
+
+	meta, err := ReadTorrent(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SaveHash(&models.SC_Hash{InfoHash: meta.InfoHash})
+
+	// 重复入库只保存并统计一次
+	for i := 0; i < 2; i++ {
+		if err := PutTorrent(meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scinfo, ok := store.GetInfo(meta.InfoHash)
+	if !ok {
+		t.Fatal("torrent not stored")
+	}
+	if !store.IsPut(meta.InfoHash) {
+		t.Error("hash not marked as put")
+	}
+	if n := store.GetLog(models.LogDay(time.Now())).PutNums; n != 1 {
+		t.Errorf("PutNums = %d, want 1", n)
+	}
+
+	// 填充文件不计入文件数量与大小
+	if scinfo.FileCount != 2 || scinfo.Length != 25000 || len(scinfo.Files) != 2 {
+		t.Errorf("file count %d, length %d, files %+v", scinfo.FileCount, scinfo.Length, scinfo.Files)
+	}
+	if scinfo.Files[0].Path != "cd1/a.flac" || scinfo.Files[1].Path != "b.txt" {
+		t.Errorf("files = %q, %q", scinfo.Files[0].Path, scinfo.Files[1].Path)
+	}
+	if scinfo.Caption != "album" || scinfo.Comment != "注释" || scinfo.CreatedBy != "SCDht test" {
+		t.Errorf("caption %q, comment %q, created by %q", scinfo.Caption, scinfo.Comment, scinfo.CreatedBy)
+	}
+	if !scinfo.Private || scinfo.Source != "TEST" || scinfo.PieceLength != 16384 || scinfo.PieceCount != 3 {
+		t.Errorf("private %v, source %q, piece length %d, pieces %d", scinfo.Private, scinfo.Source, scinfo.PieceLength, scinfo.PieceCount)
+	}
+	if len(scinfo.Trackers) != 2 || len(scinfo.WebSeeds) != 2 || scinfo.Fingerprint == "" || scinfo.PutTime.IsZero() {
+		t.Errorf("trackers %v, web seeds %v, fingerprint %q", scinfo.Trackers, scinfo.WebSeeds, scinfo.Fingerprint)
+	}
+	if scinfo.CreateTime.Unix() != 1445212800 {
+		t.Errorf("CreateTime = %v", scinfo.CreateTime)
+	}
+}
+
+func TestPutTorrentV2(t *testing.T) {
+	data := readFixture(t, "v2.torrent")
+	store := useMemoryStore(t)
+	inTempDir(t)
+
+	meta, err := ReadTorrent(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := PutTorrent(meta); err != nil {
+		t.Fatal(err)
+	}
+
+	// 纯v2种子从文件树读取文件列表, 可以通过v2 infohash查找
+	scinfo, ok := store.GetInfoV2(meta.InfoHashV2)
+	if !ok || scinfo.InfoHash != meta.InfoHash || !scinfo.IsV2Only() {
+		t.Fatalf("GetInfoV2 = %+v, %v", scinfo, ok)
+	}
+	if scinfo.MetaVersion != 2 || scinfo.FileCount != 2 || scinfo.Length != 53000 {
+		t.Errorf("meta version %d, file count %d, length %d", scinfo.MetaVersion, scinfo.FileCount, scinfo.Length)
+	}
+	if scinfo.Files[0].Path != "dir/d.bin" || scinfo.Files[0].PiecesRoot == "" {
+		t.Errorf("first file = %+v", scinfo.Files[0])
+	}
+}
@@ -0,0 +1,361 @@
+// 发布名称解析
+package common
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 名称中的分隔符
+const sep = `(?:^|[\s._\-\[\]\(\)\{\}+,])`
+const sepEnd = `(?:$|[\s._\-\[\]\(\)\{\}+,])`
+
+// 带标准名称的匹配规则
+type releaseRule struct {
+	re   *regexp.Regexp // 匹配规则
+	name string         // 标准名称
+}
+
+// 生成带分隔符边界的规则
+func releaseRe(pattern string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)` + sep + `(` + pattern + `)` + sepEnd)
+}
+
+var (
+	// 剧集编号, 如S01E02, S01E01-E03
+	episodeRe = releaseRe(`s(\d{1,2})[\s.]?e(\d{1,3})(?:-?e(\d{1,3}))?`)
+	// 剧集编号, 如1x02
+	crossEpisodeRe = releaseRe(`(\d{1,2})x(\d{2,3})`)
+	// 整季, 如S01, Season 1
+	seasonRe = releaseRe(`s(\d{1,2})|season[\s.]?(\d{1,2})`)
+	// 中文剧集编号, 支持中文数字
+	cnEpisodeRe = regexp.MustCompile(`第\s*(\d{1,3}|[零〇一二两三四五六七八九十百]{1,5})\s*[集话話]`)
+	cnSeasonRe  = regexp.MustCompile(`第\s*(\d{1,2}|[一二两三四五六七八九十]{1,3})\s*季`)
+	// 年份
+	yearRe = releaseRe(`(?:19|20)\d{2}`)
+	// 码率与位深
+	bitrateRe  = releaseRe(`\d{2,4}\s?kbps|v0|v2`)
+	bitDepthRe = releaseRe(`(?:16|24|32)[\s\-]?bits?`)
+	// 行首的发布组, 如[Group] Title
+	prefixGroupRe = regexp.MustCompile(`^\s*[\[【]([^\]】]+)[\]】]\s*`)
+	// 行尾的发布组, 如Title-GROUP
+	suffixGroupRe = regexp.MustCompile(`-([A-Za-z0-9][A-Za-z0-9@]{1,19})(?:\s*\[[^\]]*\])?\s*$`)
+	// 动漫剧集编号
+	animeEpisodeRe = regexp.MustCompile(`\s-\s(\d{1,4})(?:v\d)?(?:\s|\[|\(|$)`)
+	// 视频文件扩展名
+	videoExtRe = regexp.MustCompile(`(?i)\.(mkv|mp4|avi|wmv|m4v|ts|m2ts|rmvb)$`)
+)
+
+// 分辨率
+var resolutionRules = []releaseRule{
+	{releaseRe(`2160p|4k|uhd`), "2160p"},
+	{releaseRe(`1080p`), "1080p"},
+	{releaseRe(`1080i`), "1080i"},
+	{releaseRe(`720p`), "720p"},
+	{releaseRe(`576p`), "576p"},
+	{releaseRe(`480p`), "480p"},
+}
+
+// 片源
+var sourceRules = []releaseRule{
+	{releaseRe(`bd-?remux|blu-?ray[\s.\-]?remux|remux`), "Remux"},
+	{releaseRe(`blu-?ray|bdrip|brrip|bd25|bd50|bdmv`), "BluRay"},
+	{releaseRe(`web-?dl|webdl`), "WEB-DL"},
+	{releaseRe(`web-?rip`), "WEBRip"},
+	{releaseRe(`hdtv|pdtv|dsr`), "HDTV"},
+	{releaseRe(`dvd-?rip|dvdscr`), "DVDRip"},
+	{releaseRe(`dvd5|dvd9`), "DVD"},
+	{releaseRe(`hd-?rip`), "HDRip"},
+	{releaseRe(`hdcam|cam-?rip`), "CAM"},
+	{releaseRe(`telesync|hdts`), "TS"},
+}
+
+// 容易与标题中的单词混淆的片源, 只在其它属性之后匹配
+var weakSourceRules = []releaseRule{
+	{releaseRe(`web`), "WEB"},
+	{releaseRe(`cam`), "CAM"},
+	{releaseRe(`cd|vinyl|lp`), "CD"},
+}
+
+// 视频编码
+var videoCodecRules = []releaseRule{
+	{releaseRe(`x265|h\.?265|hevc`), "H.265"},
+	{releaseRe(`x264|h\.?264|avc`), "H.264"},
+	{releaseRe(`xvid`), "XviD"},
+	{releaseRe(`divx`), "DivX"},
+	{releaseRe(`av1`), "AV1"},
+	{releaseRe(`vp9`), "VP9"},
+	{releaseRe(`mpeg-?2`), "MPEG-2"},
+}
+
+// 音频格式
+var audioRules = []releaseRule{
+	{releaseRe(`dts-?hd[\s.\-]?ma|dts-?hd`), "DTS-HD"},
+	{releaseRe(`dts-?x`), "DTS:X"},
+	{releaseRe(`truehd`), "TrueHD"},
+	{releaseRe(`atmos`), "Atmos"},
+	{releaseRe(`dts`), "DTS"},
+	{releaseRe(`ddp[\s.]?\d\.\d|dd\+[\s.]?\d\.\d|e-?ac-?3`), "E-AC3"},
+	{releaseRe(`dd[\s.]?\d\.\d|ac-?3`), "AC3"},
+	{releaseRe(`aac(?:[\s.]?\d\.\d)?`), "AAC"},
+	{releaseRe(`flac`), "FLAC"},
+	{releaseRe(`alac`), "ALAC"},
+	{releaseRe(`lpcm`), "PCM"},
+	{releaseRe(`mp3`), "MP3"},
+}
+
+// 容易与标题中的单词混淆的音频格式, 只在其它属性之后匹配
+var weakAudioRules = []releaseRule{
+	{releaseRe(`ape`), "APE"},
+	{releaseRe(`pcm`), "PCM"},
+	{releaseRe(`opus`), "Opus"},
+}
+
+// 语言标记
+var languageRules = []releaseRule{
+	{releaseRe(`multi|multisubs?`), "multi"},
+	{releaseRe(`dual[\s.\-]?audio|dual`), "dual"},
+	{releaseRe(`english|eng`), "en"},
+	{releaseRe(`french|vostfr|vff|truefrench`), "fr"},
+	{releaseRe(`german`), "de"},
+	{releaseRe(`spanish|castellano|latino`), "es"},
+	{releaseRe(`italian|ita`), "it"},
+	{releaseRe(`russian|rus`), "ru"},
+	{releaseRe(`japanese|jpn`), "ja"},
+	{releaseRe(`korean|kor`), "ko"},
+	{releaseRe(`chinese|chs|cht|big5`), "zh"},
+}
+
+// 中日韩语言标记, 可出现在任意位置
+var cjkLanguageRules = []releaseRule{
+	{regexp.MustCompile(`国语|國語|粤语|粵語|中字|双字|雙字|中文字幕|简体|簡體|繁体|繁體|简繁|簡繁|双语|雙語`), "zh"},
+	{regexp.MustCompile(`日语|日語`), "ja"},
+	{regexp.MustCompile(`韩语|韓語`), "ko"},
+}
+
+// 解析发布名称, 无法识别任何属性时返回nil
+func ParseRelease(caption string) *models.Release {
+	name := strings.TrimSpace(videoExtRe.ReplaceAllString(strings.TrimSpace(caption), ""))
+	if name == "" {
+		return nil
+	}
+
+	var r models.Release
+	// 标题结束的位置, 即第一个属性出现的位置
+	end := len(name)
+	// 标记属性出现的位置
+	mark := func(loc []int) {
+		if loc != nil && loc[2] < end {
+			end = loc[2]
+		}
+	}
+
+	// 行首的发布组, 常见于动漫
+	start := 0
+	if m := prefixGroupRe.FindStringSubmatchIndex(name); m != nil {
+		r.Group = strings.TrimSpace(name[m[2]:m[3]])
+		start = m[1]
+	}
+
+	// 剧集编号
+	if m := episodeRe.FindStringSubmatchIndex(name); m != nil {
+		r.Season = atoiMatch(name, m, 2)
+		r.Episode = atoiMatch(name, m, 3)
+		r.EpisodeEnd = atoiMatch(name, m, 4)
+		mark(m)
+	} else if m := crossEpisodeRe.FindStringSubmatchIndex(name); m != nil {
+		r.Season = atoiMatch(name, m, 2)
+		r.Episode = atoiMatch(name, m, 3)
+		mark(m)
+	} else if m := seasonRe.FindStringSubmatchIndex(name); m != nil {
+		r.Season = atoiMatch(name, m, 2)
+		if r.Season == 0 {
+			r.Season = atoiMatch(name, m, 3)
+		}
+		mark(m)
+	}
+	if m := cnEpisodeRe.FindStringSubmatchIndex(name); m != nil && r.Episode == 0 {
+		r.Episode = cnAtoi(name[m[2]:m[3]])
+		mark([]int{m[0], m[1], m[0], m[1]})
+	}
+	if m := cnSeasonRe.FindStringSubmatchIndex(name); m != nil && r.Season == 0 {
+		r.Season = cnAtoi(name[m[2]:m[3]])
+		mark([]int{m[0], m[1], m[0], m[1]})
+	}
+
+	// 动漫的剧集编号, 如[Group] Title - 05 [1080p]
+	if r.Episode == 0 && start > 0 {
+		if m := animeEpisodeRe.FindStringSubmatchIndex(name); m != nil {
+			r.Episode = atoiMatch(name, m, 1)
+			mark([]int{m[0], m[1], m[0], m[1]})
+		}
+	}
+
+	// 年份, 取最后一个不在名称开头的年份, 避免把1917等片名当作年份
+	// 相邻年份共用分隔符, 每次从上一个年份结束处继续查找
+	var year []int
+	for pos := 0; pos < len(name); {
+		m := yearRe.FindStringSubmatchIndex(name[pos:])
+		if m == nil {
+			break
+		}
+		for i := range m {
+			m[i] += pos
+		}
+		if m[2] > start {
+			year = m
+		}
+		pos = m[3]
+	}
+	if year != nil {
+		r.Year, _ = strconv.Atoi(name[year[2]:year[3]])
+		mark(year)
+	}
+
+	r.Resolution = matchRule(name, start, resolutionRules, mark)
+	r.Source = matchRule(name, start, sourceRules, mark)
+	r.VideoCodec = matchRule(name, start, videoCodecRules, mark)
+	r.Audio = matchRule(name, start, audioRules, mark)
+	if r.Source == "" && end < len(name) {
+		r.Source = matchRule(name, end, weakSourceRules, mark)
+	}
+	if r.Audio == "" && end < len(name) {
+		r.Audio = matchRule(name, end, weakAudioRules, mark)
+	}
+
+	if m := bitrateRe.FindStringSubmatchIndex(name); m != nil {
+		r.Bitrate = strings.ToLower(strings.Replace(name[m[2]:m[3]], " ", "", -1))
+		mark(m)
+	}
+	if m := bitDepthRe.FindStringSubmatchIndex(name); m != nil {
+		r.BitDepth, _ = strconv.Atoi(name[m[2] : m[2]+2])
+		mark(m)
+	}
+
+	// 语言标记可能有多个, 其它语言只在标题之后匹配
+	has := make(map[string]bool)
+	addLanguages := func(text string, rules []releaseRule) {
+		for _, rule := range rules {
+			if !has[rule.name] && rule.re.MatchString(text) {
+				has[rule.name] = true
+				r.Languages = append(r.Languages, rule.name)
+			}
+		}
+	}
+	addLanguages(name[end:], languageRules)
+	addLanguages(name, cjkLanguageRules)
+
+	// 行尾的发布组
+	if r.Group == "" {
+		if m := suffixGroupRe.FindStringSubmatchIndex(name); m != nil && m[0] >= end && !insideTag(name, m[0]) {
+			r.Group = name[m[2]:m[3]]
+		}
+	}
+
+	// 没有任何属性时不作为发布名称处理
+	if r.Year == 0 && r.Season == 0 && r.Episode == 0 && r.Resolution == "" && r.Source == "" &&
+		r.VideoCodec == "" && r.Audio == "" && r.Bitrate == "" && r.BitDepth == 0 {
+		return nil
+	}
+
+	// 标题为第一个属性之前的部分
+	if end < start {
+		end = start
+	}
+	r.Title = cleanTitle(name[start:end])
+
+	// 音乐发布常见格式为 艺术家 - 专辑
+	if r.Audio != "" && r.Resolution == "" && r.VideoCodec == "" {
+		if i := strings.Index(r.Title, " - "); i > 0 {
+			r.Artist = strings.TrimSpace(r.Title[:i])
+			r.Title = strings.TrimSpace(r.Title[i+3:])
+		}
+	}
+
+	return &r
+}
+
+// 从from开始依次匹配规则, 返回第一个命中的标准名称
+func matchRule(name string, from int, rules []releaseRule, mark func([]int)) string {
+	for _, rule := range rules {
+		if m := rule.re.FindStringSubmatchIndex(name[from:]); m != nil {
+			mark([]int{m[0] + from, m[1] + from, m[2] + from, m[3] + from})
+			return rule.name
+		}
+	}
+
+	return ""
+}
+
+// 位置是否在某个属性中间, 如WEB-DL中的-
+func insideTag(name string, pos int) bool {
+	for _, rules := range [][]releaseRule{sourceRules, videoCodecRules, audioRules} {
+		for _, rule := range rules {
+			for _, m := range rule.re.FindAllStringSubmatchIndex(name, -1) {
+				if m[2] < pos && pos < m[3] {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// 获取匹配的数字
+func atoiMatch(s string, m []int, group int) int {
+	if len(m) <= group*2+1 || m[group*2] < 0 {
+		return 0
+	}
+
+	n, _ := strconv.Atoi(s[m[group*2]:m[group*2+1]])
+	return n
+}
+
+// 中文数字的值
+var cnDigits = map[rune]int{
+	'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4,
+	'五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+}
+
+// 转换阿拉伯数字或中文数字, 如十二、一百零五
+func cnAtoi(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+
+	total, num := 0, 0
+	for _, c := range s {
+		switch c {
+		case '十', '百':
+			unit := 10
+			if c == '百' {
+				unit = 100
+			}
+			// 十二中省略了一
+			if num == 0 {
+				num = 1
+			}
+			total += num * unit
+			num = 0
+		default:
+			num = cnDigits[c]
+		}
+	}
+
+	return total + num
+}
+
+// 清理标题中的分隔符
+func cleanTitle(s string) string {
+	// 没有空格时点与下划线为分隔符
+	if !strings.Contains(strings.TrimSpace(s), " ") {
+		s = strings.NewReplacer(".", " ", "_", " ").Replace(s)
+	}
+
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.Trim(s, " -[](){}【】_.")
+}
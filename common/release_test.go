@@ -0,0 +1,44 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 发布名称测试数据, want为null表示没有解析到任何属性
+type releaseCase struct {
+	Caption string          `json:"caption"`
+	Want    *models.Release `json:"want"`
+}
+
+func TestParseRelease(t *testing.T) {
+	var tests []releaseCase
+	if err := json.Unmarshal(readFixture(t, "releases.json"), &tests); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		got := ParseRelease(tt.Caption)
+		if got != nil && len(got.Languages) == 0 {
+			got.Languages = nil
+		}
+		if !reflect.DeepEqual(got, tt.Want) {
+			t.Errorf("ParseRelease(%q)\n got %+v\nwant %+v", tt.Caption, got, tt.Want)
+		}
+	}
+}
+
+func TestCnAtoi(t *testing.T) {
+	tests := map[string]int{
+		"3": 3, "12": 12, "八": 8, "十": 10, "十二": 12, "二十": 20,
+		"二十三": 23, "七十六": 76, "两": 2, "一百": 100, "一百零五": 105, "〇": 0, "〇五": 5,
+	}
+	for s, want := range tests {
+		if got := cnAtoi(s); got != want {
+			t.Errorf("cnAtoi(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// 入库优先级调度
+package common
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 调度器结构
+type Scheduler struct {
+	Window   int           // 每次从各个维度载入的候选数量
+	Hot      float64       // 热度权重
+	Recent   float64       // 最近获取权重
+	HalfLife time.Duration // 最近获取权重的半衰期
+	Announce float64       // announce_peer占比权重
+	Retry    float64       // 每次失败的扣分
+	MaxWait  time.Duration // 最长等待时间, 超过则优先处理
+}
+
+// 调度候选项
+type schedItem struct {
+	hash  models.SC_Hash // Hash信息
+	score float64        // 优先级分数
+}
+
+// 根据配置创建调度器
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		Window:   beego.AppConfig.DefaultInt("schedwindow", 500),
+		Hot:      beego.AppConfig.DefaultFloat("schedhot", 1.0),
+		Recent:   beego.AppConfig.DefaultFloat("schedrecent", 4.0),
+		HalfLife: time.Duration(beego.AppConfig.DefaultInt("schedhalflife", 6)) * time.Hour,
+		Announce: beego.AppConfig.DefaultFloat("schedannounce", 2.0),
+		Retry:    beego.AppConfig.DefaultFloat("schedretry", 1.5),
+		MaxWait:  time.Duration(beego.AppConfig.DefaultInt("schedmaxwait", 24)) * time.Hour,
+	}
+}
+
+// 计算优先级分数
+func (s *Scheduler) Score(schash models.SC_Hash, now time.Time) float64 {
+	// 热度按对数增长, 避免极热门hash独占队列
+	score := s.Hot * math.Log2(float64(schash.Hot)+1)
+
+	// 最近获取过的hash加分, 按半衰期衰减
+	if !schash.LastSeen.IsZero() && s.HalfLife > 0 {
+		age := now.Sub(schash.LastSeen)
+		if age < 0 {
+			age = 0
+		}
+		score += s.Recent * math.Pow(0.5, float64(age)/float64(s.HalfLife))
+	}
+
+	// announce_peer表示确实有节点在下载, 比get_peers更有价值
+	if total := schash.Announce + schash.GetPeers; total > 0 {
+		score += s.Announce * float64(schash.Announce) / float64(total)
+	}
+
+	// 失败次数扣分, 下载超时同样计入失败次数
+	score -= s.Retry * float64(schash.Invalid)
+
+	return score
+}
+
+// 是否等待过久
+func (s *Scheduler) starving(schash models.SC_Hash, now time.Time) bool {
+	if s.MaxWait <= 0 || schash.CreateTime.IsZero() {
+		return false
+	}
+
+	return now.Sub(schash.CreateTime) > s.MaxWait
+}
+
+// 获取接下来需要入库的n个hash
+func (s *Scheduler) Next(n int) []models.SC_Hash {
+	// 从热度, 最近获取和等待时间三个维度载入未入库且失败次数不超过3次的候选
+	hots := models.Db.PendingHashes("-hot", s.Window)
+	recents := models.Db.PendingHashes("-lastseen", s.Window)
+	olds := models.Db.PendingHashes("createtime", s.Window)
+
+	return s.pick(n, time.Now(), hots, recents, olds)
+}
+
+// 对候选去重打分并选出前n个
+func (s *Scheduler) pick(n int, now time.Time, lists ...[]models.SC_Hash) []models.SC_Hash {
+	// 定义一个map用以去重
+	has := make(map[string]bool)
+	// 等待过久的候选与普通候选
+	var starved, items []schedItem
+
+	for _, list := range lists {
+		for _, schash := range list {
+			if has[schash.InfoHash] {
+				continue
+			}
+			has[schash.InfoHash] = true
+
+			item := schedItem{hash: schash, score: s.Score(schash, now)}
+			if s.starving(schash, now) {
+				starved = append(starved, item)
+			} else {
+				items = append(items, item)
+			}
+		}
+	}
+
+	// 等待过久的按获取时间先后排序
+	sort.SliceStable(starved, func(i, j int) bool {
+		return starved[i].hash.CreateTime.Before(starved[j].hash.CreateTime)
+	})
+
+	// 等待过久的最多占一半, 保证热门hash仍能及时处理, 超出的与其余候选一起按分数排序
+	limit := n / 2
+	if limit < 1 {
+		limit = 1
+	}
+	if len(starved) > limit {
+		items = append(items, starved[limit:]...)
+		starved = starved[:limit]
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].score > items[j].score
+	})
+
+	var result []models.SC_Hash
+	for _, item := range append(starved, items...) {
+		if len(result) >= n {
+			break
+		}
+		result = append(result, item.hash)
+	}
+
+	return result
+}
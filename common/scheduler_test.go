@@ -0,0 +1,103 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 测试使用的调度器, 与默认配置相同
+func testScheduler() *Scheduler {
+	return &Scheduler{
+		Window:   500,
+		Hot:      1.0,
+		Recent:   4.0,
+		HalfLife: 6 * time.Hour,
+		Announce: 2.0,
+		Retry:    1.5,
+		MaxWait:  24 * time.Hour,
+	}
+}
+
+func TestSchedulerScore(t *testing.T) {
+	s := testScheduler()
+	now := time.Now()
+	base := models.SC_Hash{Hot: 10, LastSeen: now.Add(-time.Hour), GetPeers: 10}
+
+	hotter := base
+	hotter.Hot = 100
+	if s.Score(hotter, now) <= s.Score(base, now) {
+		t.Error("higher hot should score higher")
+	}
+
+	older := base
+	older.LastSeen = now.Add(-48 * time.Hour)
+	if s.Score(older, now) >= s.Score(base, now) {
+		t.Error("recently seen hash should score higher")
+	}
+
+	announced := base
+	announced.Announce, announced.GetPeers = 5, 5
+	if s.Score(announced, now) <= s.Score(base, now) {
+		t.Error("announce_peer should score higher than get_peers")
+	}
+
+	// 每次失败(包括超时)扣除固定分数
+	failed := base
+	failed.Invalid = 2
+	if got, want := s.Score(base, now)-s.Score(failed, now), 2*s.Retry; got < want-1e-9 || got > want+1e-9 {
+		t.Errorf("retry penalty = %v, want %v", got, want)
+	}
+
+	// 热度增长按对数计算, 极热门hash不会无限领先
+	if s.Score(models.SC_Hash{Hot: 1 << 20}, now) > 21 {
+		t.Error("hot score should grow logarithmically")
+	}
+}
+
+func TestSchedulerStarvationGuard(t *testing.T) {
+	s := testScheduler()
+	now := time.Now()
+
+	var hots, olds []models.SC_Hash
+	for i := 0; i < 10; i++ {
+		hots = append(hots, models.SC_Hash{
+			InfoHash:   string(rune('A' + i)),
+			Hot:        int64(1000 - i),
+			LastSeen:   now,
+			CreateTime: now,
+		})
+		olds = append(olds, models.SC_Hash{
+			InfoHash:   string(rune('a' + i)),
+			Hot:        1,
+			CreateTime: now.Add(-time.Duration(100-i) * time.Hour),
+		})
+	}
+
+	// 重复出现的hash只选一次
+	result := s.pick(6, now, hots, olds, hots)
+	if len(result) != 6 {
+		t.Fatalf("got %d hashes, want 6", len(result))
+	}
+
+	// 等待过久的最多占一半, 并按获取时间先后排列
+	for i, want := range []string{"a", "b", "c", "A", "B", "C"} {
+		if result[i].InfoHash != want {
+			t.Errorf("result[%d] = %q, want %q", i, result[i].InfoHash, want)
+		}
+	}
+
+	// 没有热门候选时等待过久的仍可填满
+	result = s.pick(4, now, olds)
+	if len(result) != 4 || result[0].InfoHash != "a" || result[1].InfoHash != "b" {
+		t.Errorf("unexpected result without hot candidates: %v", result)
+	}
+
+	// 未设置最长等待时间时不保护
+	s.MaxWait = 0
+	result = s.pick(2, now, hots, olds)
+	if result[0].InfoHash != "A" || result[1].InfoHash != "B" {
+		t.Errorf("starvation guard should be disabled: %v", result)
+	}
+}
@@ -0,0 +1,84 @@
+// 种子下载源
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+)
+
+// 下载源结构
+type Source struct {
+	Name    string                   // 下载源名称
+	Host    string                   // 请求头中的Host
+	URL     func(hash string) string // 生成下载地址
+	Breaker *Breaker                 // 熔断器
+}
+
+// 下载源状态信息
+type SourceStatus struct {
+	Name string `json:"name"` // 下载源名称
+	BreakerStatus
+}
+
+// 没有可用的下载源
+var ErrNoSource = errors.New("all torrent sources are unavailable")
+
+var (
+	sources     []*Source
+	sourcesOnce sync.Once
+)
+
+// 获取下载源列表, 首次调用时根据配置创建熔断器
+func Sources() []*Source {
+	sourcesOnce.Do(func() {
+		window := beego.AppConfig.DefaultInt("breakerwindow", 20)
+		rate := beego.AppConfig.DefaultFloat("breakerrate", 0.5)
+		min := beego.AppConfig.DefaultInt("breakermin", 5)
+		cooldown := time.Duration(beego.AppConfig.DefaultInt("breakercooldown", 60)) * time.Second
+
+		sources = []*Source{
+			{
+				Name: "bitcomet",
+				Host: "torrent-cache.bitcomet.org",
+				URL: func(hash string) string {
+					return fmt.Sprintf("http://torrent-cache.bitcomet.org:36869/get_torrent?info_hash=%s&size=226920869&key=%s", strings.ToLower(hash), GetKey(hash))
+				},
+			},
+			{
+				Name: "n0808",
+				Host: "bt.box.n0808.com",
+				URL: func(hash string) string {
+					return fmt.Sprintf("http://bt.box.n0808.com/%s/%s/%s.torrent", hash[0:2], hash[len(hash)-2:], hash)
+				},
+			},
+			{
+				Name: "torcache",
+				Host: "torcache.net",
+				URL: func(hash string) string {
+					return fmt.Sprintf("https://torcache.net/torrent/%s.torrent", hash)
+				},
+			},
+		}
+
+		for _, src := range sources {
+			src.Breaker = NewBreaker(window, rate, min, cooldown)
+		}
+	})
+
+	return sources
+}
+
+// 所有下载源的状态
+func SourcesStatus() []SourceStatus {
+	var status []SourceStatus
+	for _, src := range Sources() {
+		status = append(status, SourceStatus{Name: src.Name, BreakerStatus: src.Breaker.Status()})
+	}
+
+	return status
+}
@@ -0,0 +1,156 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zeebo/bencode"
+)
+
+// 读取测试种子文件
+func readFixture(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeMetaInfoKeys(t *testing.T) {
+	var meta MetaInfo
+	if err := bencode.DecodeBytes(readFixture(t, "v1multi.torrent"), &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	wantTiers := [][]string{
+		{"http://tracker.example/announce", "udp://backup.example:80"},
+		{"udp://tier2.example:6969"},
+	}
+	if got := meta.Trackers(); !reflect.DeepEqual(got, wantTiers) {
+		t.Errorf("Trackers() = %v, want %v", got, wantTiers)
+	}
+	if got, want := meta.WebSeeds(), []string{"http://seed.example/files/", "http://seed2.example/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WebSeeds() = %v, want %v", got, want)
+	}
+	if got, want := meta.HttpSeeds, []string{"http://hs.example/seed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HttpSeeds = %v, want %v", got, want)
+	}
+	if meta.CreatedBy != "SCDht test" {
+		t.Errorf("CreatedBy = %q", meta.CreatedBy)
+	}
+	if meta.CreationDate != 1445212800 {
+		t.Errorf("CreationDate = %d", meta.CreationDate)
+	}
+	if meta.Comment != "plain comment" || meta.GetComment() != "注释" {
+		t.Errorf("Comment = %q, GetComment() = %q", meta.Comment, meta.GetComment())
+	}
+
+	info := meta.Info
+	if info.Name != "album" || info.PieceLength != 16384 || info.PieceCount() != 3 {
+		t.Errorf("name %q, piece length %d, pieces %d", info.Name, info.PieceLength, info.PieceCount())
+	}
+	if info.Private != 1 || info.Source != "TEST" {
+		t.Errorf("private %d, source %q", info.Private, info.Source)
+	}
+	if len(info.Files) != 3 || !reflect.DeepEqual(info.Files[0].Path, []string{"cd1", "a.flac"}) || info.Files[0].Length != 20000 {
+		t.Fatalf("files = %+v", info.Files)
+	}
+	if info.Files[1].Attr != "p" {
+		t.Errorf("padding attr = %q", info.Files[1].Attr)
+	}
+}
+
+func TestDecodeSingleFileWebSeed(t *testing.T) {
+	var meta MetaInfo
+	if err := bencode.DecodeBytes(readFixture(t, "v1single.torrent"), &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := meta.WebSeeds(), []string{"http://seed.example/movie.mkv"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WebSeeds() = %v, want %v", got, want)
+	}
+	if got, want := meta.Trackers(), [][]string{{"http://single.example/announce"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Trackers() = %v, want %v", got, want)
+	}
+	if meta.Info.Name != "movie.mkv" || meta.Info.Length != 40000 || meta.Info.PieceCount() != 3 {
+		t.Errorf("info = %q %d %d", meta.Info.Name, meta.Info.Length, meta.Info.PieceCount())
+	}
+}
+
+func TestReadTorrentInfoHash(t *testing.T) {
+	tests := []struct {
+		name   string
+		hash   string
+		hashV2 string
+		v1, v2 bool
+		pieces int64
+	}{
+		{"v1multi.torrent", "C2B8034ADB94D5CFFD8F5406DB981CCA4DAB5AE1", "", true, false, 3},
+		{"v1single.torrent", "D4A794CF6FFC4DFB880784A810E1497DE2DDCC1E", "", true, false, 3},
+		{"v2.torrent", "C42EB94B4AF2684552B7363BD590826204C37CB8", "C42EB94B4AF2684552B7363BD590826204C37CB81958D9217DE914D083210500", false, true, 5},
+		{"hybrid.torrent", "D64EBD1B97C214A1E8FFAE27CEF1BFF4AF167FA1", "83D5336652D26214178152EE1F3C97AC47453B237D210E9CDB5C1B7A681E8493", true, true, 5},
+	}
+
+	for _, tt := range tests {
+		meta, err := ReadTorrent(bytes.NewReader(readFixture(t, tt.name)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if meta.InfoHash != tt.hash || meta.InfoHashV2 != tt.hashV2 {
+			t.Errorf("%s: infohash %s / %s, want %s / %s", tt.name, meta.InfoHash, meta.InfoHashV2, tt.hash, tt.hashV2)
+		}
+		if meta.Info.IsV1() != tt.v1 || meta.Info.IsV2() != tt.v2 {
+			t.Errorf("%s: IsV1 %v IsV2 %v", tt.name, meta.Info.IsV1(), meta.Info.IsV2())
+		}
+		if got := meta.Info.PieceCount(); got != tt.pieces {
+			t.Errorf("%s: PieceCount() = %d, want %d", tt.name, got, tt.pieces)
+		}
+	}
+}
+
+func TestReadTorrentV2FileTree(t *testing.T) {
+	for _, name := range []string{"v2.torrent", "hybrid.torrent"} {
+		meta, err := ReadTorrent(bytes.NewReader(readFixture(t, name)))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		files := meta.Info.V2Files()
+		if len(files) != 2 {
+			t.Fatalf("%s: V2Files() = %+v", name, files)
+		}
+		if !reflect.DeepEqual(files[0].Path, []string{"dir", "d.bin"}) || files[0].Length != 50000 {
+			t.Errorf("%s: first file %+v", name, files[0])
+		}
+		if !reflect.DeepEqual(files[1].Path, []string{"e.txt"}) || files[1].Length != 3000 {
+			t.Errorf("%s: second file %+v", name, files[1])
+		}
+
+		// 超过一个分块的文件才有分块层, 每个分块32字节
+		root := "3730258025BC49F0CC8AC5DF97F449FCD2A08879E5554F2D5E8C2BD29EB3D4EC"
+		if files[0].PiecesRoot != root {
+			t.Errorf("%s: pieces root %s", name, files[0].PiecesRoot)
+		}
+		if len(meta.PieceLayers) != 1 {
+			t.Fatalf("%s: %d piece layers", name, len(meta.PieceLayers))
+		}
+		for key, layer := range meta.PieceLayers {
+			if strings.ToUpper(hex.EncodeToString([]byte(key))) != root || len(layer) != 4*32 {
+				t.Errorf("%s: piece layer %x has %d bytes", name, key, len(layer))
+			}
+		}
+	}
+}
+
+func TestReadTorrentInvalid(t *testing.T) {
+	for _, data := range []string{"", "de", "d4:infoi1ee", "not bencode"} {
+		if _, err := ReadTorrent(strings.NewReader(data)); err == nil {
+			t.Errorf("%q: expected error", data)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// 文件树生成
+package common
+
+import (
+	"strings"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 文件树生成器, 每个种子单独创建, 不在多个goroutine间共享
+type TreeBuilder struct {
+	root  *models.FileTree            // 根目录
+	dirs  map[string]*models.FileTree // 以完整路径为键的目录索引
+	files map[string]bool             // 已加入的文件路径
+}
+
+// 创建文件树生成器
+func NewTreeBuilder() *TreeBuilder {
+	return &TreeBuilder{
+		root:  new(models.FileTree),
+		dirs:  make(map[string]*models.FileTree),
+		files: make(map[string]bool),
+	}
+}
+
+// 加入文件, path为分割后的路径
+func (t *TreeBuilder) Add(path []string, length int64) {
+	if len(path) == 0 {
+		return
+	}
+
+	// 重复的文件只保留第一个
+	full := strings.Join(path, "/")
+	if t.files[full] {
+		return
+	}
+	t.files[full] = true
+
+	// 逐级查找或创建目录, 同时累加目录大小
+	parent := t.root
+	parent.Size += length
+	for i := 0; i < len(path)-1; i++ {
+		key := strings.Join(path[:i+1], "/")
+		dir, ok := t.dirs[key]
+		if !ok {
+			dir = &models.FileTree{Name: path[i]}
+			t.dirs[key] = dir
+			parent.Dirs = append(parent.Dirs, dir)
+		}
+		dir.Size += length
+		parent = dir
+	}
+
+	parent.Files = append(parent.Files, &models.TreeFile{
+		Name:   path[len(path)-1],
+		Length: length,
+	})
+}
+
+// 获取生成的文件树
+func (t *TreeBuilder) Tree() *models.FileTree {
+	return t.root
+}
+
+// 根据文件列表生成文件树
+func BuildFileTree(files []models.File) *models.FileTree {
+	t := NewTreeBuilder()
+	for _, f := range files {
+		// 旧数据没有路径数组时分割路径
+		parts := f.Parts
+		if len(parts) == 0 {
+			parts = strings.Split(f.Path, "/")
+		}
+		t.Add(parts, f.Length)
+	}
+
+	return t.Tree()
+}
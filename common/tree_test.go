@@ -0,0 +1,61 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/ylqjgm/SCDht/models"
+)
+
+func TestTreeBuilder(t *testing.T) {
+	b := NewTreeBuilder()
+	b.Add([]string{"Show", "Season 1", "E01.mkv"}, 100)
+	b.Add([]string{"Show", "Season 1", "E02.mkv"}, 200)
+	b.Add([]string{"Show", "Season 2", "E01.mkv"}, 300)
+	b.Add([]string{"Show", "cover.jpg"}, 5)
+	b.Add([]string{"readme.txt"}, 1)
+	// 重复的文件与空路径被忽略
+	b.Add([]string{"Show", "Season 1", "E01.mkv"}, 100)
+	b.Add(nil, 50)
+
+	want := &models.FileTree{
+		Size: 606,
+		Dirs: []*models.FileTree{{
+			Name: "Show",
+			Size: 605,
+			Dirs: []*models.FileTree{
+				{Name: "Season 1", Size: 300, Files: []*models.TreeFile{{Name: "E01.mkv", Length: 100}, {Name: "E02.mkv", Length: 200}}},
+				{Name: "Season 2", Size: 300, Files: []*models.TreeFile{{Name: "E01.mkv", Length: 300}}},
+			},
+			Files: []*models.TreeFile{{Name: "cover.jpg", Length: 5}},
+		}},
+		Files: []*models.TreeFile{{Name: "readme.txt", Length: 1}},
+	}
+
+	if got := b.Tree(); !reflect.DeepEqual(got, want) {
+		g, _ := json.Marshal(got)
+		w, _ := json.Marshal(want)
+		t.Errorf("tree\n got %s\nwant %s", g, w)
+	}
+}
+
+func TestBuildFileTreeLegacyPaths(t *testing.T) {
+	// 旧数据只有以/连接的路径, 同名目录不能与其它层级的目录合并
+	tree := BuildFileTree([]models.File{
+		{Path: "a/b/1.txt", Length: 1},
+		{Parts: []string{"a", "2.txt"}, Length: 2},
+		{Path: "b/1.txt", Length: 4},
+	})
+
+	if tree.Size != 7 || len(tree.Dirs) != 2 {
+		t.Fatalf("root = %+v", tree)
+	}
+	a, b := tree.Dirs[0], tree.Dirs[1]
+	if a.Name != "a" || a.Size != 3 || len(a.Dirs) != 1 || a.Dirs[0].Name != "b" || a.Dirs[0].Size != 1 || len(a.Files) != 1 {
+		t.Errorf("dir a = %+v", a)
+	}
+	if b.Name != "b" || b.Size != 4 || len(b.Dirs) != 0 || len(b.Files) != 1 {
+		t.Errorf("dir b = %+v", b)
+	}
+}
@@ -2,7 +2,10 @@ package common
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"html/template"
 	"image/png"
@@ -10,6 +13,7 @@ import (
 	"io/ioutil"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -17,69 +21,225 @@ import (
 	"github.com/astaxie/beego"
 	"github.com/beego/i18n"
 	"github.com/wangbin/jiebago"
+	"github.com/ylqjgm/SCDht/magnet"
 	"github.com/ylqjgm/SCDht/models"
 	"github.com/zeebo/bencode"
-	"gopkg.in/mgo.v2/bson"
 )
 
-// 目录信息结构
-type Directory struct {
-	Name  string
-	Dirs  []*Directory
-	Files []*File
-}
-
 // 种子文件信息结构
 type FileDict struct {
-	Length int64    "length"
-	Path   []string "path"
-	Path8  []string "path.utf-8" // 文件utf-8格式路径数组
-	Md5sum string   "md5sum"
+	Length int64    `bencode:"length"`
+	Path   []string `bencode:"path"`
+	Path8  []string `bencode:"path.utf-8"` // 文件utf-8格式路径数组
+	Md5sum string   `bencode:"md5sum"`
+	Attr   string   `bencode:"attr"` // 文件属性, p为填充文件, h为隐藏, x为可执行(BEP 47)
 }
 
 // 种子Info信息结构
 type InfoDict struct {
-	FileDuration []int64 "file-duration"
-	FileMedia    []int64 "file-media"
+	FileDuration []int64 `bencode:"file-duration"`
+	FileMedia    []int64 `bencode:"file-media"`
 
 	// Single file
-	Name   string "name"
-	Name8  string "name.utf-8" // 种子utf-8名称
-	Length int64  "length"
-	Md5sum string "md5sum"
+	Name   string `bencode:"name"`
+	Name8  string `bencode:"name.utf-8"` // 种子utf-8名称
+	Length int64  `bencode:"length"`
+	Md5sum string `bencode:"md5sum"`
 
 	// Multiple files
-	Files       []FileDict "files"
-	PieceLength int64      "piece length"
-	Pieces      string     "pieces"
-	Private     int64      "private"
+	Files       []FileDict `bencode:"files"`
+	PieceLength int64      `bencode:"piece length"`
+	Pieces      string     `bencode:"pieces"`
+	Private     int64      `bencode:"private"`
+	Source      string     `bencode:"source"` // 发布来源, 私有tracker用于区分infohash
+
+	// BitTorrent v2 (BEP 52)
+	MetaVersion int64                  `bencode:"meta version"` // 元数据版本, v2为2
+	FileTree    map[string]interface{} `bencode:"file tree"`    // v2文件树
+}
+
+// v2文件信息结构
+type FileV2 struct {
+	Path       []string // 文件路径
+	Length     int64    // 文件长度
+	PiecesRoot string   // 文件merkle树根hash
+	Attr       string   // 文件属性(BEP 47)
 }
 
 // 种子信息结构
 type MetaInfo struct {
-	Info         InfoDict "info"
-	InfoHash     string   "info hash"
-	Announce     string   "announce"
-	CreationDate int64    "creation date"
-	Comment      string   "comment"
-	CreatedBy    string   "created by"
-	Encoding     string   "encoding"
+	Info         InfoDict          `bencode:"info"`
+	InfoHash     string            `bencode:"info hash"`
+	InfoHashV2   string            `bencode:"info hash v2"` // v2格式infohash, 即info的SHA-256
+	PieceLayers  map[string]string `bencode:"piece layers"` // v2分块层, 以pieces root为键(BEP 52)
+	Announce     string            `bencode:"announce"`
+	AnnounceList [][]string        `bencode:"announce-list"` // 分层tracker列表(BEP 12)
+	UrlList      interface{}       `bencode:"url-list"`      // web种子, 可能为字符串或列表(BEP 19)
+	HttpSeeds    []string          `bencode:"httpseeds"`     // http种子(BEP 17)
+	CreationDate int64             `bencode:"creation date"`
+	Comment      string            `bencode:"comment"`
+	Comment8     string            `bencode:"comment.utf-8"` // utf-8格式注释
+	CreatedBy    string            `bencode:"created by"`
+	Encoding     string            `bencode:"encoding"`
+}
+
+// 获取分层tracker列表, 没有announce-list时使用announce
+func (meta *MetaInfo) Trackers() [][]string {
+	// 定义一个map用以去重
+	has := make(map[string]bool)
+	var tiers [][]string
+
+	for _, tier := range meta.AnnounceList {
+		var t []string
+		for _, tr := range tier {
+			tr = strings.TrimSpace(tr)
+			if tr != "" && !has[tr] {
+				has[tr] = true
+				t = append(t, tr)
+			}
+		}
+		if len(t) > 0 {
+			tiers = append(tiers, t)
+		}
+	}
+
+	// announce-list存在时应忽略announce
+	if len(tiers) == 0 {
+		if tr := strings.TrimSpace(meta.Announce); tr != "" {
+			tiers = append(tiers, []string{tr})
+		}
+	}
+
+	return tiers
+}
+
+// 获取web种子列表
+func (meta *MetaInfo) WebSeeds() []string {
+	var seeds []string
+
+	switch v := meta.UrlList.(type) {
+	case string:
+		seeds = append(seeds, v)
+	case []interface{}:
+		for _, u := range v {
+			if s, ok := u.(string); ok {
+				seeds = append(seeds, s)
+			}
+		}
+	case []string:
+		seeds = append(seeds, v...)
+	}
+
+	var result []string
+	for _, seed := range seeds {
+		if seed = strings.TrimSpace(seed); seed != "" {
+			result = append(result, seed)
+		}
+	}
+
+	return result
+}
+
+// 获取种子注释, 优先使用utf-8格式
+func (meta *MetaInfo) GetComment() string {
+	if meta.Comment8 != "" {
+		return strings.TrimSpace(meta.Comment8)
+	}
+
+	return strings.TrimSpace(meta.Comment)
+}
+
+// 获取种子名称, 优先使用utf-8格式, 否则按检测到的字符集转换
+func (meta *MetaInfo) GetName() string {
+	if meta.Info.Name8 != "" {
+		return strings.TrimSpace(meta.Info.Name8)
+	}
+
+	return strings.TrimSpace(DecodeCharset([]byte(meta.Info.Name), TorrentCharset(meta)))
+}
+
+// 分块数量
+func (info *InfoDict) PieceCount() int64 {
+	// v1种子分块hash保存在pieces中
+	if info.Pieces != "" || info.PieceLength <= 0 {
+		return int64(len(info.Pieces) / sha1.Size)
+	}
+
+	// v2种子每个文件单独分块
+	var count int64
+	for _, f := range info.V2Files() {
+		count += (f.Length + info.PieceLength - 1) / info.PieceLength
+	}
+
+	return count
+}
+
+// 是否包含v1元数据
+func (info *InfoDict) IsV1() bool {
+	return info.Pieces != ""
+}
+
+// 是否包含v2元数据
+func (info *InfoDict) IsV2() bool {
+	return info.MetaVersion == 2 && len(info.FileTree) > 0
+}
+
+// 获取v2文件树中的文件列表
+func (info *InfoDict) V2Files() []FileV2 {
+	var files []FileV2
+	walkFileTree(info.FileTree, nil, &files)
+	return files
+}
+
+// 递归遍历v2文件树
+func walkFileTree(tree map[string]interface{}, path []string, files *[]FileV2) {
+	// 字典按键名排序, 保证与种子中的顺序一致
+	var names []string
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		node, ok := tree[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// 空键表示文件本身
+		if name == "" {
+			if len(path) == 0 {
+				continue
+			}
+			f := FileV2{Path: append([]string(nil), path...)}
+			f.Length, _ = node["length"].(int64)
+			f.Attr, _ = node["attr"].(string)
+			if root, ok := node["pieces root"].(string); ok {
+				f.PiecesRoot = strings.ToUpper(hex.EncodeToString([]byte(root)))
+			}
+			*files = append(*files, f)
+			continue
+		}
+
+		walkFileTree(node, append(path, name), files)
+	}
 }
 
+// 不是有效的种子文件
+var ErrInvalidTorrent = errors.New("not a valid .torrent file")
+
 // 定义一个分词对象
 var (
 	seg   jiebago.Segmenter
 	Langs []string
-	root  Directory
 )
 
 func init() {
 	// 载入分词字典
 	seg.LoadDictionary("dict.txt")
-	SetLang()
 }
 
-// 设置语言
+// 设置语言, 由Web服务启动时调用, 语言文件缺失时退出
 func SetLang() {
 	// 设定语言类型
 	langs := "en-US|zh-CN|ja-JP|zh-TW|ko-KR"
@@ -150,29 +310,32 @@ func HightLight(str, key string) interface{} {
 }
 
 // 递归循环输出树结构
-func TreeShow(dirs []*Directory) interface{} {
+func TreeShow(trees []*models.FileTree) interface{} {
 	// 定义一个变量保存要输出的内容
 	str := ""
 
-	for _, ds := range dirs {
-		for _, s := range ds.Dirs {
-			str += fmt.Sprintf(`<li class="closed"><span class="folder">%s</span><ul>`, s.Name)
+	for _, tree := range trees {
+		str += treeHTML(tree)
+	}
 
-			if len(s.Dirs) > 0 {
-				str += TreeShow(s.Dirs).(string)
-			}
+	return UnEscaped(str)
+}
 
-			if len(s.Files) > 0 {
-				for _, f := range s.Files {
-					str += fmt.Sprintf(`<li><span><i class="fa %s"></i> %s<small>%s</small></span></li>`, FileType(f.Path), f.Path, Size(f.Length))
-				}
-			}
+// 输出目录下的子目录与文件
+func treeHTML(tree *models.FileTree) string {
+	str := ""
 
-			str += `</ul>`
-		}
+	for _, d := range tree.Dirs {
+		str += fmt.Sprintf(`<li class="closed"><span class="folder">%s<small>%s</small></span><ul>`, template.HTMLEscapeString(d.Name), Size(d.Total()))
+		str += treeHTML(d)
+		str += `</ul></li>`
 	}
 
-	return UnEscaped(str)
+	for _, f := range tree.Files {
+		str += fmt.Sprintf(`<li><span><i class="fa %s"></i> %s<small>%s</small></span></li>`, FileType(f.Name), template.HTMLEscapeString(f.Name), Size(f.Length))
+	}
+
+	return str
 }
 
 // 根据文件名返回文件类型
@@ -219,6 +382,29 @@ func FileType(str string) string {
 	return "fa-file-o"
 }
 
+// 输出每日获取次数的折线图, 每天占6像素宽, 最大值对应图片高度
+func Sparkline(series []int64) interface{} {
+	if len(series) == 0 {
+		return UnEscaped("")
+	}
+
+	const step, height = 6, 30
+	var max int64 = 1
+	for _, n := range series {
+		if n > max {
+			max = n
+		}
+	}
+
+	points := make([]string, len(series))
+	for i, n := range series {
+		points[i] = fmt.Sprintf("%d,%d", i*step, height-int(n*height/max))
+	}
+
+	width := (len(series) - 1) * step
+	return UnEscaped(fmt.Sprintf(`<svg class="sparkline" width="%d" height="%d" viewBox="0 -1 %d %d"><polyline fill="none" stroke="#5bc0de" stroke-width="1.5" points="%s"/></svg>`, width, height+2, width, height+2, strings.Join(points, " ")))
+}
+
 // 转换字节数为对应大小格式
 func Size(length int64) string {
 	units := []string{"B", "KB", "MB", "GB", "TB", "PB"}
@@ -289,12 +475,57 @@ func DateFormat(t time.Time) string {
 	return fmt.Sprintf("%d秒前", unix)
 }
 
+// 生成磁力链接, 包含名称, 大小, tracker与web种子
+func MagnetLink(info models.SC_Info) string {
+	return richMagnet(info, -1).String()
+}
+
+// 生成磁力链接结构, maxTrackers小于0时不限制tracker数量
+func richMagnet(info models.SC_Info, maxTrackers int) *magnet.Magnet {
+	m := &magnet.Magnet{
+		InfoHashV2: info.InfoHashV2,
+		Name:       info.Caption,
+		Length:     info.Length,
+		WebSeeds:   info.WebSeeds,
+	}
+
+	// 纯v2种子只使用btmh, 混合种子同时提供两种infohash
+	if !info.IsV2Only() {
+		m.InfoHash = info.InfoHash
+	}
+
+	// 按层级顺序加入tracker
+	for _, tier := range info.Trackers {
+		for _, tr := range tier {
+			if maxTrackers >= 0 && len(m.Trackers) >= maxTrackers {
+				return m
+			}
+			m.Trackers = append(m.Trackers, tr)
+		}
+	}
+
+	return m
+}
+
+// 生成二维码使用的磁力链接, 限制长度以保证能生成二维码
+func qrMagnet(info models.SC_Info) string {
+	m := richMagnet(info, 3)
+	m.WebSeeds = nil
+
+	return m.String()
+}
+
+// 模板中使用的磁力链接
+func Magnet(info models.SC_Info) template.URL {
+	return template.URL(MagnetLink(info))
+}
+
 // InfoHash转迅雷链接
 func Thunder(infohash string) string {
 	// 先获取磁力链接
-	magnet := fmt.Sprintf("magnet:?xt=urn:btih:%s", strings.ToUpper(infohash))
+	link := (&magnet.Magnet{InfoHash: infohash}).String()
 
-	return base64.StdEncoding.EncodeToString([]byte("AA" + magnet + "ZZ"))
+	return base64.StdEncoding.EncodeToString([]byte("AA" + link + "ZZ"))
 }
 
 // 转换字符串为html
@@ -333,13 +564,31 @@ func ReadTorrent(r io.Reader) (meta MetaInfo, err error) {
 		return
 	}
 
-	hash := sha1.New()
-	err = bencode.NewEncoder(hash).Encode(meta.Info)
+	// 使用原始info数据计算infohash, 重新编码会丢失未知字段
+	var raw struct {
+		Info bencode.RawMessage `bencode:"info"`
+	}
+	err = bencode.DecodeBytes(s, &raw)
 	if err != nil {
 		return
 	}
+	if len(raw.Info) == 0 {
+		err = ErrInvalidTorrent
+		return
+	}
+
+	v1 := sha1.Sum(raw.Info)
+	meta.InfoHash = fmt.Sprintf("%02X", v1[:])
 
-	meta.InfoHash = fmt.Sprintf("%02X", string(hash.Sum(nil)))
+	if meta.Info.IsV2() {
+		v2 := sha256.Sum256(raw.Info)
+		meta.InfoHashV2 = fmt.Sprintf("%02X", v2[:])
+
+		// 纯v2种子使用截断的v2 infohash作为兼容的infohash
+		if !meta.Info.IsV1() {
+			meta.InfoHash = meta.InfoHashV2[:40]
+		}
+	}
 
 	return
 	/*var m interface{}
@@ -381,88 +630,20 @@ func ReadTorrent(r io.Reader) (meta MetaInfo, err error) {
 	return*/
 }
 
-// 查找目录
-func (d *Directory) findDir(name string) *Directory {
-	// 循环目录列表
-	for i := 0; i < len(d.Dirs); i++ {
-		// 如果目录存在
-		if d.Dirs[i].Name == name {
-			// 返回当前目录
-			return d.Dirs[i]
-		}
-	}
-
-	// 返回空数据
-	return nil
-}
-
-// 创建目录
-func (d *Directory) makeDir(name string) *Directory {
-	// 创建一个目录
-	dir := &Directory{
-		Name: name,
-	}
-	// 将目录加入自身
-	d.Dirs = append(d.Dirs, dir)
-
-	// 返回目录
-	return dir
-}
-
-// 查找文件
-func (d *Directory) findFile(name string) *File {
-	// 循环文件列表
-	for i := 0; i < len(d.Files); i++ {
-		// 如果文件存在
-		if d.Files[i].Path == name {
-			// 返回当前文件
-			return d.Files[i]
-		}
+// 读取种子文件
+func ReadTorrentFile(path string) (meta MetaInfo, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
 	}
+	defer f.Close()
 
-	// 返回空数据
-	return nil
-}
-
-// 创建文件
-func (d *Directory) makeFile(name string, length int64) *File {
-	// 创建一个文件
-	file := &File{
-		Path:   name,
-		Length: length,
+	meta, err = ReadTorrent(f)
+	if err == nil && len(meta.InfoHash) != 40 {
+		err = ErrInvalidTorrent
 	}
-	// 将文件加入自身
-	d.Files = append(d.Files, file)
 
-	// 返回文件
-	return file
-}
-
-// 目录树结构
-func treeDir(path []string, length int64) {
-	// 引用root变量
-	parent := &root
-
-	// 循环分割的路径
-	for i := 0; i < len(path)-1; i++ {
-		// 查找目录看是否存在了
-		dir := parent.findDir(path[i])
-		// 如果不存在
-		if dir == nil {
-			// 创建目录
-			dir = parent.makeDir(path[i])
-		}
-		// 目录复制
-		parent = dir
-	}
-
-	// 获取文件
-	file := path[len(path)-1]
-	// 查找文件是否存在
-	if parent.findFile(file) == nil {
-		// 不存在则创建文件
-		parent.makeFile(file, length)
-	}
+	return
 }
 
 // 种子入库
@@ -470,13 +651,23 @@ func PutTorrent(metaTorrent MetaInfo) error {
 	// 定义一个SC_Info
 	var scinfo models.SC_Info
 
+	// 检测非utf-8名称与路径使用的字符集
+	charset := TorrentCharset(&metaTorrent)
+	if charset != "UTF-8" {
+		scinfo.Charset = charset
+	}
+
 	// 如果有utf-8格式名称
 	if metaTorrent.Info.Name8 != "" {
 		// 直接使用utf-8格式的
 		scinfo.Caption = strings.TrimSpace(metaTorrent.Info.Name8)
 	} else {
-		// 否则使用非utf-8格式
-		scinfo.Caption = strings.TrimSpace(metaTorrent.Info.Name)
+		// 否则按检测到的字符集转换为utf-8
+		scinfo.Caption = strings.TrimSpace(DecodeCharset([]byte(metaTorrent.Info.Name), charset))
+		// 转换过则保留原始名称
+		if scinfo.Charset != "" {
+			scinfo.RawCaption = []byte(metaTorrent.Info.Name)
+		}
 	}
 
 	// 设置infohash, 并转换为大写格式
@@ -491,75 +682,99 @@ func PutTorrent(metaTorrent MetaInfo) error {
 		scinfo.CreateTime = time.Unix(metaTorrent.CreationDate, 0)
 	}
 
-	// 定义一个File
-	var file File
-	// 定义一个File列表保存路径信息
-	var spath []File
+	// 设置v2格式infohash
+	scinfo.InfoHashV2 = strings.ToUpper(metaTorrent.InfoHashV2)
+	scinfo.MetaVersion = metaTorrent.Info.MetaVersion
+
+	// 定义一个File列表保存所有文件
+	var files []models.File
 
 	// 判断文件列表是否大于0
-	if len(metaTorrent.Info.Files) > 0 {
+	if !metaTorrent.Info.IsV1() && metaTorrent.Info.IsV2() {
+		// 纯v2种子从文件树中读取文件列表
+		for _, f := range metaTorrent.Info.V2Files() {
+			// 设置文件信息
+			file := NewFile(f.Path, f.Length, f.Attr)
+			file.PiecesRoot = f.PiecesRoot
+			// 将文件信息加入列表
+			files = append(files, file)
+		}
+	} else if len(metaTorrent.Info.Files) > 0 {
 		// 循环处理文件列表
 		for _, FileDict := range metaTorrent.Info.Files {
-			// 加上文件长度
-			scinfo.Length += FileDict.Length
-			// 文件数量+1
-			scinfo.FileCount += 1
-			// 设置文件长度
-			file.Length = FileDict.Length
-			// 清空文件路径
-			file.Path = ""
+			// 定义文件路径及原始路径
+			var parts []string
+			var raw [][]byte
 
 			if FileDict.Path8 != nil {
 				// 如果存在utf8编码则使用utf8编码
 				for _, path := range FileDict.Path8 {
-					file.Path += strings.TrimSpace(path) + "/"
+					parts = append(parts, strings.TrimSpace(path))
 				}
 			} else {
-				// 否则使用默认
+				// 否则按检测到的字符集转换
 				for _, path := range FileDict.Path {
-					file.Path += strings.TrimSpace(path) + "/"
+					parts = append(parts, strings.TrimSpace(DecodeCharset([]byte(path), charset)))
+					// 转换过则保留原始路径
+					if scinfo.Charset != "" {
+						raw = append(raw, []byte(path))
+					}
 				}
 			}
 
-			// 获取最后一个/的位置
-			index := strings.LastIndex(file.Path, "/")
-			if index <= len(file.Path) && index != -1 {
-				// 将最后一个/去掉
-				file.Path = file.Path[0:index]
-			}
+			// 设置文件信息
+			file := NewFile(parts, FileDict.Length, FileDict.Attr)
+			file.Md5sum = FileDict.Md5sum
+			file.Raw = raw
 			// 将文件信息加入列表
-			scinfo.Files = append(scinfo.Files, file)
-			// 加入路径信息
-			spath = append(spath, file)
+			files = append(files, file)
 		}
 	} else {
-		// 设置文件长度
-		scinfo.Length = metaTorrent.Info.Length
-		// 设置文件数量
-		scinfo.FileCount = 1
-		// 设置文件路径
-		file.Path = scinfo.Caption
-		// 设置文件长度
-		file.Length = scinfo.Length
 		// 将文件本身加入列表
-		scinfo.Files = append(scinfo.Files, file)
-		// 加入路径信息
-		spath = append(spath, file)
+		file := NewFile([]string{scinfo.Caption}, metaTorrent.Info.Length, "")
+		file.Md5sum = metaTorrent.Info.Md5sum
+		files = append(files, file)
 	}
 
-	// 对路径数组循环
-	for _, f := range spath {
-		// 分割路径
-		p := strings.Split(f.Path, "/")
-		// 创建树结构
-		treeDir(p, f.Length)
+	// 填充文件不入库, 也不计入文件数量与大小
+	for _, file := range files {
+		if file.IsPadding() {
+			continue
+		}
+		// 加上文件长度
+		scinfo.Length += file.Length
+		// 文件数量+1
+		scinfo.FileCount += 1
+		// 将文件信息加入列表
+		scinfo.Files = append(scinfo.Files, file)
 	}
 
-	// 设置树结构
-	scinfo.FileList = append(scinfo.FileList, root)
-	// 清空
-	root.Dirs = nil
-	root.Files = nil
+	// 设置树结构, 每个种子单独生成
+	scinfo.FileList = []*models.FileTree{BuildFileTree(scinfo.Files)}
+	// 设置内容分类
+	scinfo.Category = Classify(scinfo.Caption, scinfo.Files)
+	// 解析发布名称
+	scinfo.Release = ParseRelease(scinfo.Caption)
+	// 计算内容指纹
+	scinfo.Fingerprint = Fingerprint(scinfo.Files)
+
+	// 设置tracker与web种子
+	scinfo.Trackers = metaTorrent.Trackers()
+	scinfo.WebSeeds = metaTorrent.WebSeeds()
+	scinfo.HttpSeeds = metaTorrent.HttpSeeds
+	// 设置分块信息
+	scinfo.PieceLength = metaTorrent.Info.PieceLength
+	scinfo.PieceCount = metaTorrent.Info.PieceCount()
+	// 设置是否为私有种子
+	scinfo.Private = metaTorrent.Info.Private == 1
+	// 设置发布来源, 注释及创建工具
+	scinfo.Source = strings.TrimSpace(metaTorrent.Info.Source)
+	if metaTorrent.Comment8 != "" {
+		scinfo.Comment = metaTorrent.GetComment()
+	} else {
+		scinfo.Comment = strings.TrimSpace(DecodeCharset([]byte(metaTorrent.Comment), charset))
+	}
+	scinfo.CreatedBy = strings.TrimSpace(metaTorrent.CreatedBy)
 
 	// 设置文件热度为1
 	scinfo.Hot = 1
@@ -570,50 +785,53 @@ func PutTorrent(metaTorrent MetaInfo) error {
 		re, _ := regexp.Compile("\\pP|\\pS")
 		// 去除种子名称中的所有符号
 		caption := re.ReplaceAllString(scinfo.Caption, " ")
-		// 检测是否存在
-		if !models.Has(models.DbInfo, bson.M{"infohash": scinfo.InfoHash}) {
-			// 设置种子分词
-			scinfo.Keys = Sego(caption)
-			// 设置种子发布时间
-			scinfo.PutTime = time.Now()
-			// 保存种子信息
-			err := scinfo.Save()
-			if err == nil {
-				// 设置当前hash已经入库
-				models.SetPut(scinfo.InfoHash)
-				// 自增统计数据
-				models.SaveLog(time.Now().Format("20060102"), "putnums")
-
-				// 定义一个Qrcode对象
-				qr := &Qrcode{
-					Version:        0,
-					Level:          ECLevelM,
-					ModuleSize:     7,
-					QuietZoneWidth: 0,
-				}
+		// 设置种子分词
+		scinfo.Keys = Sego(caption)
+		// 设置种子发布时间, 已入库的种子保留原有时间
+		scinfo.PutTime = time.Now()
+		// 不存在则插入, 已存在则更新种子信息, 多个工作协程同时入库同一种子时只有插入的一方返回isNew
+		isNew, err := scinfo.Save()
+		if err != nil {
+			return err
+		}
+		// 设置当前hash已经入库
+		if err := models.SetPut(scinfo.InfoHash); err != nil {
+			return err
+		}
+		if !isNew {
+			return nil
+		}
 
-				// 生成二维码
-				img, _ := qr.Encode("magnet:?xt=urn:btih:" + scinfo.InfoHash)
-				// 截取infohash作为目录
-				dir := "./static/qrcode/" + scinfo.InfoHash[0:1] + "/" + scinfo.InfoHash[1:2] + "/" + scinfo.InfoHash[2:3] + "/" + scinfo.InfoHash[3:4] + "/" + scinfo.InfoHash[4:5] + "/" + scinfo.InfoHash[5:6] + "/" + scinfo.InfoHash[6:7]
-				// 创建目录
-				os.MkdirAll(dir, 0777)
-				// 创建二维码图片文件
-				f, _ := os.Create(fmt.Sprintf("%s/%s.png", dir, scinfo.InfoHash))
-				// 保证正确关闭
-				defer f.Close()
-				// 将二维码写入文件
-				png.Encode(f, img)
-			}
+		// 获取历史从hash首次获取时开始
+		models.SeedSwarm(scinfo.InfoHash)
+		// 相同内容的种子只保留热度最高的在列表中显示
+		models.RefreshGroup(scinfo.Fingerprint)
+		// 自增统计数据
+		models.SaveLog(models.LogPut)
+
+		// 定义一个Qrcode对象
+		qr := &Qrcode{
+			Version:        0,
+			Level:          ECLevelM,
+			ModuleSize:     7,
+			QuietZoneWidth: 0,
+		}
 
-			return err
-		} else {
-			// 如果没有设置为入库
-			if !models.IsPut(scinfo.InfoHash) {
-				// 设置为入库
-				return models.SetPut(scinfo.InfoHash)
-			}
+		// 生成二维码, 内容过长时使用最简磁力链接
+		img, qerr := qr.Encode(qrMagnet(scinfo))
+		if qerr != nil {
+			img, _ = qr.Encode((&magnet.Magnet{InfoHash: scinfo.InfoHash, InfoHashV2: scinfo.InfoHashV2}).String())
 		}
+		// 截取infohash作为目录
+		dir := "./static/qrcode/" + scinfo.InfoHash[0:1] + "/" + scinfo.InfoHash[1:2] + "/" + scinfo.InfoHash[2:3] + "/" + scinfo.InfoHash[3:4] + "/" + scinfo.InfoHash[4:5] + "/" + scinfo.InfoHash[5:6] + "/" + scinfo.InfoHash[6:7]
+		// 创建目录
+		os.MkdirAll(dir, 0777)
+		// 创建二维码图片文件
+		f, _ := os.Create(fmt.Sprintf("%s/%s.png", dir, scinfo.InfoHash))
+		// 保证正确关闭
+		defer f.Close()
+		// 将二维码写入文件
+		png.Encode(f, img)
 	}
 
 	return nil
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/ylqjgm/SCDht/common"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 管理Controller结构
+type AdminController struct {
+	beego.Controller
+}
+
+// 管理密钥请求头, 不放在查询参数中, 以免记录到访问日志
+const adminKeyHeader = "X-Admin-Key"
+
+// 校验管理密钥
+func (this *AdminController) Prepare() {
+	// 获取配置中的管理密钥
+	key := beego.AppConfig.String("adminkey")
+	// 未设置密钥或密钥不正确则不允许访问
+	given := this.Ctx.Request.Header.Get(adminKeyHeader)
+	if key == "" || subtle.ConstantTimeCompare([]byte(given), []byte(key)) != 1 {
+		this.Abort("404")
+	}
+}
+
+// 运行状态
+func (this *AdminController) Status() {
+	this.Data["json"] = map[string]interface{}{
+		"put":     common.PutStatus(),     // 入库工作池状态
+		"sources": common.SourcesStatus(), // 下载源状态
+		"prune":   common.PruneStatus(),   // hash清理状态
+	}
+	this.ServeJson()
+}
+
+// hash清理报告, 按当前规则试运行, 返回将要删除的数量及后台清理统计
+func (this *AdminController) Prune() {
+	rules := common.PruneRulesFromConfig()
+	report, err := common.Prune(rules, true, 0)
+	if err != nil {
+		this.Ctx.Output.SetStatus(500)
+		this.Data["json"] = map[string]interface{}{"error": err.Error()}
+		this.ServeJson()
+		return
+	}
+
+	this.Data["json"] = map[string]interface{}{
+		"rules":   rules,                // 当前规则
+		"report":  report,               // 将要删除的数量
+		"janitor": common.PruneStatus(), // 后台清理统计
+	}
+	this.ServeJson()
+}
+
+// 统计数据, from与to为yyyymmdd格式的日期, hourly=1时按小时返回
+func (this *AdminController) Stats() {
+	hourly, _ := this.GetBool("hourly")
+
+	// 默认获取最近7天或最近24小时
+	to := time.Now()
+	from := to.AddDate(0, 0, -6)
+	if hourly {
+		from = to.Add(-23 * time.Hour)
+	}
+
+	var err error
+	if s := this.GetString("from"); s != "" {
+		if from, err = time.ParseInLocation("20060102", s, time.Local); err != nil {
+			this.statsError("invalid from: " + s)
+			return
+		}
+	}
+	if s := this.GetString("to"); s != "" {
+		if to, err = time.ParseInLocation("20060102", s, time.Local); err != nil {
+			this.statsError("invalid to: " + s)
+			return
+		}
+		// 包含结束日期的全天
+		to = to.AddDate(0, 0, 1).Add(-time.Second)
+	}
+
+	// 限制查询范围, 按小时最多31天, 按天最多一年
+	limit := 366 * 24 * time.Hour
+	if hourly {
+		limit = 31 * 24 * time.Hour
+	}
+	if to.Before(from) || to.Sub(from) > limit {
+		this.statsError("invalid range")
+		return
+	}
+
+	this.Data["json"] = models.Stats(from, to, hourly)
+	this.ServeJson()
+}
+
+// 输出统计参数错误
+func (this *AdminController) statsError(msg string) {
+	this.Ctx.Output.SetStatus(400)
+	this.Data["json"] = map[string]interface{}{"error": msg}
+	this.ServeJson()
+}
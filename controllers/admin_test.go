@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/astaxie/beego"
+)
+
+// 请求管理接口
+func getAdmin(t *testing.T, url, key string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest("GET", url, nil)
+	if key != "" {
+		r.Header.Set(adminKeyHeader, key)
+	}
+	rw := httptest.NewRecorder()
+	handlers := beego.NewControllerRegister()
+	handlers.Add("/admin/status", &AdminController{}, "get:Status")
+	handlers.ServeHTTP(rw, r)
+	return rw
+}
+
+func TestAdminKeyHeader(t *testing.T) {
+	useMemoryStore(t)
+	old := beego.AppConfig.String("adminkey")
+	beego.AppConfig.Set("adminkey", "secret")
+	defer beego.AppConfig.Set("adminkey", old)
+
+	if rw := getAdmin(t, "/admin/status", "secret"); rw.Code != 200 {
+		t.Errorf("header key: status %d", rw.Code)
+	}
+	// 查询参数中的密钥不再有效
+	if rw := getAdmin(t, "/admin/status?key=secret", ""); rw.Code == 200 {
+		t.Error("query key accepted")
+	}
+	if rw := getAdmin(t, "/admin/status", "wrong"); rw.Code == 200 {
+		t.Error("wrong key accepted")
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/beego/i18n"
 	"github.com/ylqjgm/SCDht/common"
 	"github.com/ylqjgm/SCDht/models"
-	"gopkg.in/mgo.v2/bson"
 )
 
 type BaseController struct {
@@ -20,15 +19,13 @@ type BaseController struct {
 func (this *BaseController) Prepare() {
 	// 获取今天日期
 	t := time.Now().Format("20060102")
-	// 定义一个日志模型
-	var sclog models.SC_Log
 	// 查询今天入库数量
-	models.GetOneByQuery(models.DbLog, bson.M{"day": t}, &sclog)
+	sclog := models.GetLog(t)
 	// 添加到模板中
 	this.Data["Today"] = sclog.PutNums
 
 	// 获取所有种子数量
-	all := models.Count(models.DbInfo, nil)
+	all := models.ReadDb.CountInfos(models.InfoQuery{})
 	// 使用到模板中
 	this.Data["All"] = all
 
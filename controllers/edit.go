@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/ylqjgm/SCDht/common"
+)
+
+// 允许编辑的种子文件最大长度
+const maxEditSize = 10 * 1024 * 1024
+
+// 种子编辑页
+func (this *IndexController) Edit() {
+	this.TplNames = "edit.html"
+
+	if this.Ctx.Request.Method != "POST" {
+		return
+	}
+
+	// 第一步: 上传种子, 显示当前信息
+	if this.GetString("torrent") == "" {
+		data, err := this.readTorrentFile()
+		if err != nil {
+			this.Data["Error"] = err.Error()
+			return
+		}
+
+		meta, err := common.ReadTorrent(bytes.NewReader(data))
+		if err != nil {
+			this.Data["Error"] = err.Error()
+			return
+		}
+
+		this.Data["Torrent"] = base64.StdEncoding.EncodeToString(data)
+		this.Data["Name"] = meta.GetName()
+		this.Data["InfoHash"] = meta.InfoHash
+		this.Data["Trackers"] = formatTiers(meta.Trackers())
+		this.Data["WebSeeds"] = strings.Join(meta.WebSeeds(), "\n")
+		this.Data["Comment"] = meta.GetComment()
+		this.Data["CreatedBy"] = meta.CreatedBy
+		this.Data["Private"] = meta.Info.Private == 1
+		return
+	}
+
+	// 第二步: 提交修改, 返回编辑后的种子
+	torrent := this.GetString("torrent")
+	data, err := base64.StdEncoding.DecodeString(torrent)
+	if err != nil {
+		this.Data["Error"] = err.Error()
+		return
+	}
+
+	comment := this.GetString("comment")
+	createdBy := this.GetString("createdby")
+	private := this.GetString("private") != ""
+
+	// 表单提交全部字段, 只修改与种子当前值不同的字段
+	var opts common.EditOptions
+	meta, err := common.ReadTorrent(bytes.NewReader(data))
+	if err == nil {
+		opts = changedOptions(&meta, this.GetString("trackers"), this.GetString("webseeds"), comment, createdBy, private)
+		opts.AllowInfoChange = this.GetString("confirm") != ""
+	}
+
+	res, err := common.EditTorrent(data, opts)
+
+	// 出错或需要确认时重新显示表单
+	if err != nil {
+		// 需要确认的修改只显示警告
+		if err != common.ErrInfoChange {
+			this.Data["Error"] = err.Error()
+		}
+		this.Data["Warnings"] = res.Warnings
+		this.Data["Torrent"] = torrent
+		this.Data["Name"] = res.Name
+		this.Data["InfoHash"] = res.InfoHash
+		this.Data["Trackers"] = this.GetString("trackers")
+		this.Data["WebSeeds"] = this.GetString("webseeds")
+		this.Data["Comment"] = comment
+		this.Data["CreatedBy"] = createdBy
+		this.Data["Private"] = private
+		return
+	}
+
+	this.serveTorrent(res)
+}
+
+// 种子编辑接口
+func (this *IndexController) EditApi() {
+	data, err := this.readTorrentFile()
+	if err != nil {
+		this.editError(400, err, nil)
+		return
+	}
+
+	form := this.Input()
+	opts := common.EditOptions{
+		AddTrackers:     splitTiers(form["addtracker"]),
+		RemoveTrackers:  form["removetracker"],
+		AddWebSeeds:     form["addwebseed"],
+		RemoveWebSeeds:  form["removewebseed"],
+		AllowInfoChange: form.Get("allowinfochange") == "1",
+	}
+
+	// 出现的参数才修改
+	if _, ok := form["trackers"]; ok {
+		opts.Trackers = splitTiers(form["trackers"])
+	}
+	if form.Get("cleartrackers") == "1" {
+		opts.Trackers = [][]string{}
+	}
+	if form.Get("clearwebseeds") == "1" {
+		opts.WebSeeds = []string{}
+	}
+	if _, ok := form["comment"]; ok {
+		comment := form.Get("comment")
+		opts.Comment = &comment
+	}
+	if _, ok := form["createdby"]; ok {
+		createdBy := form.Get("createdby")
+		opts.CreatedBy = &createdBy
+	}
+	if _, ok := form["private"]; ok {
+		private := form.Get("private") == "1"
+		opts.Private = &private
+	}
+
+	res, err := common.EditTorrent(data, opts)
+
+	// 会改变infohash的修改需要allowinfochange=1确认
+	if err == common.ErrInfoChange {
+		this.editError(409, err, res.Warnings)
+		return
+	}
+	if err != nil {
+		this.editError(400, err, res.Warnings)
+		return
+	}
+
+	for _, w := range res.Warnings {
+		this.Ctx.ResponseWriter.Header().Add("X-Warning", w)
+	}
+	this.serveTorrent(res)
+}
+
+// 比较表单与种子的当前值, 生成只包含修改字段的编辑选项
+func changedOptions(meta *common.MetaInfo, trackers, webSeeds, comment, createdBy string, private bool) common.EditOptions {
+	var opts common.EditOptions
+
+	if tiers := parseTiers(trackers); !reflect.DeepEqual(tiers, parseTiers(formatTiers(meta.Trackers()))) {
+		opts.Trackers = tiers
+	}
+	if seeds := parseLines(webSeeds); !reflect.DeepEqual(seeds, parseLines(strings.Join(meta.WebSeeds(), "\n"))) {
+		opts.WebSeeds = seeds
+	}
+	// 浏览器提交的换行为\r\n
+	if comment = strings.TrimSpace(strings.Replace(comment, "\r\n", "\n", -1)); comment != meta.GetComment() {
+		opts.Comment = &comment
+	}
+	if createdBy = strings.TrimSpace(createdBy); createdBy != strings.TrimSpace(meta.CreatedBy) {
+		opts.CreatedBy = &createdBy
+	}
+	if private != (meta.Info.Private == 1) {
+		opts.Private = &private
+	}
+
+	return opts
+}
+
+// 读取上传的种子文件
+func (this *IndexController) readTorrentFile() ([]byte, error) {
+	f, _, err := this.GetFile("torrentFile")
+	if err != nil {
+		return nil, err
+	}
+	// 保证正确关闭
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxEditSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxEditSize {
+		return nil, common.ErrInvalidTorrent
+	}
+
+	return data, nil
+}
+
+// 输出编辑后的种子
+func (this *IndexController) serveTorrent(res common.EditResult) {
+	name := res.Name
+	if name == "" {
+		name = res.NewInfoHash
+	}
+
+	this.Ctx.Output.Header("Content-Type", "application/x-bittorrent")
+	this.Ctx.Output.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.QueryEscape(name+".torrent"))
+	this.Ctx.Output.Header("X-Infohash", res.NewInfoHash)
+	this.Ctx.Output.Body(res.Data)
+}
+
+// 输出接口错误
+func (this *IndexController) editError(status int, err error, warnings []string) {
+	this.Ctx.Output.SetStatus(status)
+	this.Data["json"] = map[string]interface{}{
+		"error":    err.Error(),
+		"warnings": warnings,
+	}
+	this.ServeJson()
+}
+
+// 每行一个tracker, 空行分隔tracker层
+func parseTiers(s string) [][]string {
+	tiers := [][]string{}
+	var tier []string
+
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if len(tier) > 0 {
+				tiers = append(tiers, tier)
+				tier = nil
+			}
+			continue
+		}
+		tier = append(tier, line)
+	}
+	if len(tier) > 0 {
+		tiers = append(tiers, tier)
+	}
+
+	return tiers
+}
+
+// 生成每行一个tracker, 空行分隔的文本
+func formatTiers(tiers [][]string) string {
+	var parts []string
+	for _, tier := range tiers {
+		parts = append(parts, strings.Join(tier, "\n"))
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// 每行一项
+func parseLines(s string) []string {
+	list := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			list = append(list, line)
+		}
+	}
+
+	return list
+}
+
+// 每个参数为一层, 同层tracker以逗号分隔
+func splitTiers(values []string) [][]string {
+	tiers := [][]string{}
+	for _, v := range values {
+		var tier []string
+		for _, tr := range strings.Split(v, ",") {
+			if tr = strings.TrimSpace(tr); tr != "" {
+				tier = append(tier, tr)
+			}
+		}
+		if len(tier) > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+
+	return tiers
+}
@@ -2,16 +2,17 @@ package controllers
 
 import (
 	"fmt"
+	"html/template"
+	"net/url"
 	"os"
-	"regexp"
 	"strings"
 	"time"
 
 	"github.com/astaxie/beego"
 	"github.com/astaxie/beego/utils/pagination"
 	"github.com/ylqjgm/SCDht/common"
+	"github.com/ylqjgm/SCDht/magnet"
 	"github.com/ylqjgm/SCDht/models"
-	"gopkg.in/mgo.v2/bson"
 )
 
 // Controller结构
@@ -24,6 +25,12 @@ type HotList struct {
 	Caption string // 标题
 }
 
+// TrendList结构
+type TrendList struct {
+	models.SC_Info
+	Trend int64 // 近期获取次数
+}
+
 // 首页
 func (this *IndexController) Index() {
 	// 如果当前是简体中文
@@ -51,10 +58,8 @@ func (this *IndexController) Index() {
 			this.Data["HotList"] = hotlist
 		}
 	} else {
-		// 定义一个Info列表
-		var infos []models.SC_Info
 		// 获取热门种子列表
-		models.GetDataByQuery(models.DbInfo, 0, 20, "-hot", nil, &infos)
+		infos := models.ReadDb.FindInfos(models.InfoQuery{Collapse: true}, 0, 20, "-hot")
 		// 设置热门列表
 		this.Data["HotList"] = infos
 	}
@@ -81,24 +86,47 @@ func (this *IndexController) Search() {
 	// 设置搜索词
 	this.Data["Key"] = key
 
-	// 定义两个SC_Info列表
-	var infos, newlist, hots []models.SC_Info
-
 	// 获取热门种子列表
-	models.GetDataByQuery(models.DbInfo, 0, 5, "-hot", nil, &hots)
+	hots := models.ReadDb.FindInfos(models.InfoQuery{Collapse: true}, 0, 5, "-hot")
 	// 设置热门列表
 	this.Data["HotList"] = hots
 
 	// 获取最新入库
-	models.GetDataByQuery(models.DbInfo, 0, 10, "-puttime", nil, &newlist)
+	newlist := models.ReadDb.FindInfos(models.InfoQuery{Collapse: true}, 0, 10, "-puttime")
 	// 设置最新入库
 	this.Data["NewList"] = newlist
 
+	// 设置近期热门
+	this.Data["TrendList"] = this.trending(10)
+
 	// 设置查询条件
-	query := bson.M{"$or": []bson.M{bson.M{"caption": bson.M{"$regex": bson.RegEx{key, "i"}}}, bson.M{"keys": bson.M{"$regex": bson.RegEx{key, "i"}}}}}
+	query := models.InfoQuery{Key: key, Collapse: true}
+
+	// 按分类过滤
+	category := this.GetString("category")
+	if common.IsCategory(category) {
+		query.Category = category
+		this.Data["Category"] = category
+	}
+	// 设置分类列表
+	this.Data["Categories"] = common.Categories
+
+	// 按发布属性过滤
+	filter := this.releaseFilter(&query.Release)
+	// 设置排序链接使用的查询参数
+	params := url.Values{}
+	for k, v := range filter {
+		params[k] = v
+	}
+	if common.IsCategory(category) {
+		params.Set("category", category)
+	}
+	if len(params) > 0 {
+		this.Data["Query"] = template.URL("?" + params.Encode())
+	}
 
 	// 获取种子数量
-	count := models.Count(models.DbInfo, query)
+	count := models.ReadDb.CountInfos(query)
 
 	// 设置数量
 	this.Data["Nums"] = count
@@ -109,27 +137,22 @@ func (this *IndexController) Search() {
 	this.Data["paginator"] = page
 
 	// 获取种子列表
-	models.GetDataByQuery(models.DbInfo, page.Offset(), 15, sort, query, &infos)
+	infos := models.ReadDb.FindInfos(query, page.Offset(), 15, sort)
 	// 设置种子列表
 	this.Data["Lists"] = infos
 
-	// 定义三个SC_Search列表
-	var randomlist, lastsearch, relevantsearch []models.SC_Search
-
-	// 设置查询条件
-	query = bson.M{"$or": []bson.M{bson.M{"caption": bson.M{"$regex": bson.RegEx{key, "i"}}}}}
 	// 获取相关搜索
-	models.GetDataByQuery(models.DbSearch, 0, 10, "-searchtime", query, &relevantsearch)
+	relevantsearch := models.ReadDb.FindSearches(key, 10, "-searchtime")
 	// 设置相关搜索
 	this.Data["RelevantList"] = relevantsearch
 
 	// 获取热门搜索
-	models.GetDataByQuery(models.DbSearch, 0, 10, "-views", nil, &randomlist)
+	randomlist := models.ReadDb.FindSearches("", 10, "-views")
 	// 设置热门搜索
 	this.Data["RandomList"] = randomlist
 
 	// 获取最近搜索
-	models.GetDataByQuery(models.DbSearch, 0, 10, "-searchtime", nil, &lastsearch)
+	lastsearch := models.ReadDb.FindSearches("", 10, "-searchtime")
 	// 设置最后搜索
 	this.Data["LastSearch"] = lastsearch
 
@@ -145,7 +168,7 @@ func (this *IndexController) Search() {
 	scsearch.Save()
 
 	// 自增查询次数
-	models.SaveLog(time.Now().Format("20060102"), "searchnums")
+	models.SaveLog(models.LogSearch)
 
 	// 输出模板
 	this.TplNames = "list.html"
@@ -190,24 +213,8 @@ func (this *IndexController) Magnet() {
 		// 种子入库
 		err = common.PutTorrent(meta)
 		if err == nil {
-			// 检测infohash是否已经存在
-			if models.Has(models.DbHash, bson.M{"infohash": strings.ToUpper(strings.TrimSpace(meta.InfoHash))}) {
-				// 设置当前infohash已经入库
-				models.SetPut(strings.ToUpper(strings.TrimSpace(meta.InfoHash)))
-			} else {
-				// 定义一个SC_Hash
-				var schash models.SC_Hash
-				// 设置SC_Hash
-				schash.Hot = 1
-				schash.IsPut = true
-				schash.InfoHash = strings.ToUpper(strings.TrimSpace(meta.InfoHash))
-				// 保存hash数据
-				err = schash.Save()
-				if err == nil {
-					// 自增统计数据
-					models.SaveLog(time.Now().Format("20060102"), "dhtnums")
-				}
-			}
+			// 保存hash数据并设置为已入库
+			common.SaveHash(strings.ToUpper(strings.TrimSpace(meta.InfoHash)), true)
 
 			this.Redirect("/"+strings.ToUpper(strings.TrimSpace(meta.InfoHash)), 302)
 		}
@@ -218,27 +225,34 @@ func (this *IndexController) Magnet() {
 
 // 最新入库
 func (this *IndexController) Newly() {
-	// 定义两个SC_Info列表
-	var infos, hots []models.SC_Info
-
 	// 获取热门种子列表
-	models.GetDataByQuery(models.DbInfo, 0, 5, "-hot", nil, &hots)
+	hots := models.ReadDb.FindInfos(models.InfoQuery{Collapse: true}, 0, 5, "-hot")
 	// 设置热门列表
 	this.Data["HotList"] = hots
 
+	// 按分类浏览
+	query := models.InfoQuery{Collapse: true}
+	category := this.GetString("category")
+	if common.IsCategory(category) {
+		query.Category = category
+		this.Data["Category"] = category
+	}
+	// 设置分类列表
+	this.Data["Categories"] = common.Categories
+
 	// 获取最新入库列表
-	models.GetDataByQuery(models.DbInfo, 0, 15, "-puttime", nil, &infos)
+	infos := models.ReadDb.FindInfos(query, 0, 15, "-puttime")
 	// 设置最新入库列表
 	this.Data["Lists"] = infos
 
-	// 定义一个SC_Search列表
-	var wesearch []models.SC_Search
-
 	// 获取大家都在搜
-	models.GetDataByQuery(models.DbSearch, 0, 10, "-searchtime", nil, &wesearch)
+	wesearch := models.ReadDb.FindSearches("", 10, "-searchtime")
 	// 设置大家都在搜
 	this.Data["SearchList"] = wesearch
 
+	// 设置近期热门
+	this.Data["TrendList"] = this.trending(10)
+
 	this.TplNames = "new.html"
 }
 
@@ -246,54 +260,48 @@ func (this *IndexController) Newly() {
 func (this *IndexController) Torrent() {
 	if this.Ctx.Request.Method == "POST" {
 		// 获取磁力链接
-		magnet := this.GetString("magnetLink")
-		// 将磁力链接转换为大写格式
-		magnet = strings.ToUpper(magnet)
-
-		// 定义一个正则
-		re, _ := regexp.Compile(`MAGNET:\?XT=URN:BTIH:([^&]+)`)
-		// 匹配磁力链接
-		match := re.FindAllString(magnet, -1)
-		// 获取infohash
-		magnet = strings.Replace(match[0], "MAGNET:?XT=URN:BTIH:", "", -1)
-		// 去除空格并转换为大写
-		magnet = strings.ToUpper(strings.TrimSpace(magnet))
-
-		if match[0] == "" {
-			// 如果匹配不到磁力链接
-			if len(magnet) != 40 {
-				// 跳转404
-				this.Abort("404")
+		link := strings.TrimSpace(this.GetString("magnetLink"))
+		// 定义一个infohash
+		var infohash string
+
+		if m, err := magnet.Parse(link); err == nil {
+			// 通过btmh查找已入库的v2种子
+			if m.InfoHashV2 != "" {
+				// 获取种子信息
+				if scinfo, ok := models.ReadDb.GetInfoV2(m.InfoHashV2); ok {
+					// 跳转到种子信息页
+					this.Redirect("/"+scinfo.InfoHash, 302)
+					return
+				}
 			}
-		}
 
-		// 检测infohash是否已经存在
-		if !models.Has(models.DbHash, bson.M{"infohash": magnet}) {
-			// 定义一个SC_Hash
-			var schash models.SC_Hash
-			// 设置SC_Hash
-			schash.Hot = 1
-			schash.IsPut = true
-			schash.InfoHash = magnet
-			// 保存hash数据
-			err := schash.Save()
-			if err == nil {
-				// 自增统计数据
-				models.SaveLog(time.Now().Format("20060102"), "dhtnums")
+			infohash = m.InfoHash
+			if infohash == "" {
+				// 没有btih时使用截断的v2 infohash下载
+				infohash = m.InfoHashV2[:40]
 			}
+		} else if hash, err := magnet.ParseHash(link); err == nil {
+			// 直接输入的infohash
+			infohash = hash
+		} else {
+			// 无法识别则跳转404
+			this.Abort("404")
 		}
 
+		// 保存hash数据
+		common.SaveHash(infohash, false)
+
 		// 检测infohash是否已入库过
-		if !models.Has(models.DbInfo, bson.M{"infohash": magnet}) {
+		if !models.Db.HasInfo(infohash) {
 			// 下载并入库种子
-			ret, err := common.PullTorrent(magnet)
+			ret, err := common.PullTorrent(infohash)
 			if err != nil || ret != 0 {
 				this.Abort("404")
 			}
 		}
 
 		// 跳转到种子信息页
-		this.Redirect("/"+magnet, 302)
+		this.Redirect("/"+infohash, 302)
 	}
 
 	this.TplNames = "torrent.html"
@@ -309,11 +317,21 @@ func (this *IndexController) View() {
 
 	// 定义一个SC_Info
 	var scinfo models.SC_Info
+	var ok bool
+
+	// 获取种子信息, 64位为v2格式infohash, 刚入库的种子可能还没有同步到从节点, 找不到时从主节点读取
+	for _, db := range []models.Store{models.ReadDb, models.Db} {
+		if len(infohash) == 64 {
+			scinfo, ok = db.GetInfoV2(infohash)
+		} else {
+			scinfo, ok = db.GetInfo(infohash)
+		}
+		if ok {
+			break
+		}
+	}
 
-	// 获取种子信息
-	models.GetOneByQuery(models.DbInfo, bson.M{"infohash": infohash}, &scinfo)
-
-	if scinfo.InfoHash == "" {
+	if !ok {
 		// 如果infohash为空或小于40则报错
 		this.Abort("404")
 	}
@@ -321,16 +339,13 @@ func (this *IndexController) View() {
 	// 设置种子标题
 	this.Data["Caption"] = scinfo.Caption
 
-	// 定义两个SC_Info列表
-	var hots []models.SC_Info
-
 	// 获取热门种子列表
-	models.GetDataByQuery(models.DbInfo, 0, 5, "-hot", nil, &hots)
+	hots := models.ReadDb.FindInfos(models.InfoQuery{Collapse: true}, 0, 5, "-hot")
 	// 设置热门列表
 	this.Data["HotList"] = hots
 
 	// 自增下载次数
-	models.SetAdd(models.DbInfo, bson.M{"infohash": infohash}, "views", true)
+	models.Db.AddViews(scinfo.InfoHash)
 
 	// 设置创建时间
 	this.Data["CreateTime"] = scinfo.CreateTime
@@ -338,16 +353,44 @@ func (this *IndexController) View() {
 	this.Data["PutTime"] = scinfo.PutTime
 	// 设置文件大小
 	this.Data["Length"] = scinfo.Length
+	this.Data["Category"] = scinfo.Category
+	// 设置发布名称解析结果
+	this.Data["Release"] = scinfo.Release
+	// 设置相同内容的其它种子
+	this.Data["Alternatives"] = this.alternatives(scinfo)
 	// 设置关键词
 	this.Data["Keys"] = scinfo.Keys
 	// 设置种子热度
 	this.Data["Hot"] = scinfo.Hot
+	// 设置获取历史
+	if swarm, ok := models.GetSwarm(scinfo.InfoHash); ok {
+		this.Data["Swarm"] = swarm
+		this.Data["SwarmDays"] = models.SwarmDays
+		this.Data["SwarmSeries"] = swarm.Series(time.Now(), models.SwarmDays)
+	}
 	// 设置文件数量
 	this.Data["FileCount"] = scinfo.FileCount
 	// 设置InfoHash
 	this.Data["InfoHash"] = scinfo.InfoHash
+	// 设置v2格式InfoHash
+	this.Data["InfoHashV2"] = scinfo.InfoHashV2
 	// 设置文件列表
 	this.Data["FileList"] = scinfo.FileList
+	// 设置tracker与web种子
+	this.Data["Trackers"] = scinfo.Trackers
+	this.Data["WebSeeds"] = scinfo.WebSeeds
+	this.Data["HttpSeeds"] = scinfo.HttpSeeds
+	// 设置分块信息
+	this.Data["PieceLength"] = scinfo.PieceLength
+	this.Data["PieceCount"] = scinfo.PieceCount
+	// 设置是否为私有种子
+	this.Data["Private"] = scinfo.Private
+	// 设置发布来源, 注释及创建工具
+	this.Data["Source"] = scinfo.Source
+	this.Data["Comment"] = scinfo.Comment
+	this.Data["CreatedBy"] = scinfo.CreatedBy
+	// 设置磁力链接
+	this.Data["Magnet"] = common.Magnet(scinfo)
 	// 设置下载链接
 	this.Data["Down"] = fmt.Sprintf("http://btcache.me/torrent/%s", scinfo.InfoHash)
 
@@ -358,8 +401,88 @@ func (this *IndexController) View() {
 	}
 
 	// 自增查看次数
-	models.SaveLog(time.Now().Format("20060102"), "viewnums")
+	models.SaveLog(models.LogView)
 
 	// 输出模板
 	this.TplNames = "view.html"
 }
+
+// 获取相同内容指纹的其它种子, 按热度排序
+func (this *IndexController) alternatives(scinfo models.SC_Info) []models.SC_Info {
+	if scinfo.Fingerprint == "" {
+		return nil
+	}
+
+	infos := models.ReadDb.FindInfos(models.InfoQuery{Fingerprint: scinfo.Fingerprint}, 0, 21, "-hot")
+	var alternatives []models.SC_Info
+	for _, info := range infos {
+		if info.InfoHash != scinfo.InfoHash && len(alternatives) < 20 {
+			alternatives = append(alternatives, info)
+		}
+	}
+
+	return alternatives
+}
+
+// 发布属性过滤参数, 设置查询条件并返回有效的参数
+func (this *IndexController) releaseFilter(release *models.Release) url.Values {
+	filter := url.Values{}
+
+	// 字符串属性
+	for _, key := range []string{"resolution", "source", "videocodec", "audio"} {
+		v := strings.TrimSpace(this.GetString(key))
+		if v == "" || len(v) > 20 {
+			continue
+		}
+		switch key {
+		case "resolution":
+			release.Resolution = v
+		case "source":
+			release.Source = v
+		case "videocodec":
+			release.VideoCodec = v
+		case "audio":
+			release.Audio = v
+		}
+		filter.Set(key, v)
+	}
+
+	// 数字属性
+	for _, key := range []string{"year", "season"} {
+		v, err := this.GetInt(key)
+		if err != nil || v <= 0 {
+			continue
+		}
+		if key == "year" {
+			release.Year = v
+		} else {
+			release.Season = v
+		}
+		filter.Set(key, fmt.Sprint(v))
+	}
+
+	// 设置已选择的过滤条件
+	this.Data["Filters"] = filter
+	if len(filter) > 0 {
+		this.Data["ReleaseFilter"] = template.URL(filter.Encode())
+	}
+
+	return filter
+}
+
+// 获取近期热门的种子, 不显示重复内容
+func (this *IndexController) trending(length int) []TrendList {
+	since := time.Now().AddDate(0, 0, -models.TrendDays)
+	var trends []TrendList
+	for _, swarm := range models.ReadDb.TrendingSwarms(since, length*2) {
+		// 入库时只有获取时间的历史没有近期热度
+		if len(trends) >= length || swarm.Trend == 0 {
+			break
+		}
+		if info, ok := models.ReadDb.GetInfo(swarm.InfoHash); ok && !info.Duplicate {
+			trends = append(trends, TrendList{SC_Info: info, Trend: swarm.Trend})
+		}
+	}
+
+	return trends
+}
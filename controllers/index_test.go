@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/ylqjgm/SCDht/common"
+	"github.com/ylqjgm/SCDht/models"
+)
+
+// 使用内存存储
+func useMemoryStore(t *testing.T) *models.MemoryStore {
+	store := models.NewMemoryStore()
+	old, oldRead := models.Db, models.ReadDb
+	models.Db, models.ReadDb = store, store
+	t.Cleanup(func() { models.Db, models.ReadDb = old, oldRead })
+	return store
+}
+
+// 获取种子列表中的infohash
+func infoNames(infos []models.SC_Info) []string {
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.InfoHash)
+	}
+	return names
+}
+
+func TestTrendingSkipsDuplicates(t *testing.T) {
+	store := useMemoryStore(t)
+	old := models.TrendDays
+	models.TrendDays = 3
+	defer func() { models.TrendDays = old }()
+
+	now := time.Now()
+	for i, info := range []models.SC_Info{
+		{InfoHash: "A", Caption: "a"},
+		{InfoHash: "B", Caption: "b", Duplicate: true},
+		{InfoHash: "C", Caption: "c"},
+		{InfoHash: "D", Caption: "d"},
+	} {
+		info := info
+		store.SaveInfo(&info)
+		store.AddSwarm(info.InfoHash, now, now, map[string]int64{models.LogDay(now): int64(10 - i)})
+	}
+	// 未入库的hash没有种子信息
+	store.AddSwarm("E", now, now, map[string]int64{models.LogDay(now): 20})
+	// 超出近期范围
+	past := now.AddDate(0, 0, -5)
+	store.SaveInfo(&models.SC_Info{InfoHash: "F"})
+	store.AddSwarm("F", past, past, map[string]int64{models.LogDay(past): 30})
+
+	var names []string
+	for _, trend := range (&IndexController{}).trending(2) {
+		names = append(names, trend.InfoHash)
+		if trend.Trend == 0 {
+			t.Errorf("%s has no trend", trend.InfoHash)
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"A", "C"}) {
+		t.Errorf("trending = %v, want [A C]", names)
+	}
+}
+
+func TestAlternatives(t *testing.T) {
+	store := useMemoryStore(t)
+	for _, info := range []models.SC_Info{
+		{InfoHash: "A", Hot: 5, Fingerprint: "fp"},
+		{InfoHash: "B", Hot: 9, Fingerprint: "fp", Duplicate: true},
+		{InfoHash: "C", Hot: 1, Fingerprint: "fp", Duplicate: true},
+		{InfoHash: "D", Hot: 7, Fingerprint: "other"},
+	} {
+		info := info
+		store.SaveInfo(&info)
+	}
+
+	scinfo, _ := store.GetInfo("A")
+	if got := infoNames((&IndexController{}).alternatives(scinfo)); !reflect.DeepEqual(got, []string{"B", "C"}) {
+		t.Errorf("alternatives = %v, want [B C]", got)
+	}
+	if got := (&IndexController{}).alternatives(models.SC_Info{InfoHash: "X"}); got != nil {
+		t.Errorf("alternatives without fingerprint = %v", got)
+	}
+}
+
+// 上传种子调用编辑接口
+func postEdit(t *testing.T, fields map[string]string) *httptest.ResponseRecorder {
+	data, err := ioutil.ReadFile("../common/testdata/v1multi.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	f, _ := w.CreateFormFile("torrentFile", "v1multi.torrent")
+	f.Write(data)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	w.Close()
+
+	r, _ := http.NewRequest("POST", "/api/edit", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	rw := httptest.NewRecorder()
+	handlers := beego.NewControllerRegister()
+	handlers.Add("/api/edit", &IndexController{}, "post:EditApi")
+	handlers.ServeHTTP(rw, r)
+	return rw
+}
+
+func TestEditApi(t *testing.T) {
+	useMemoryStore(t)
+	hash := "C2B8034ADB94D5CFFD8F5406DB981CCA4DAB5AE1"
+
+	// 修改注释不改变infohash
+	rw := postEdit(t, map[string]string{"comment": "edited", "addtracker": "udp://new.example:80"})
+	if rw.Code != 200 || rw.Header().Get("X-Infohash") != hash {
+		t.Fatalf("status %d, infohash %q: %s", rw.Code, rw.Header().Get("X-Infohash"), rw.Body.String())
+	}
+	meta, err := common.ReadTorrent(rw.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.GetComment() != "edited" || meta.InfoHash != hash || len(meta.Trackers()) != 3 {
+		t.Errorf("comment %q, infohash %s, trackers %v", meta.GetComment(), meta.InfoHash, meta.Trackers())
+	}
+
+	// 修改private需要确认
+	rw = postEdit(t, map[string]string{"private": "0"})
+	var res struct {
+		Error    string   `json:"error"`
+		Warnings []string `json:"warnings"`
+	}
+	if rw.Code != 409 || json.Unmarshal(rw.Body.Bytes(), &res) != nil || res.Error != common.ErrInfoChange.Error() || len(res.Warnings) != 1 {
+		t.Errorf("status %d: %s", rw.Code, rw.Body.String())
+	}
+
+	rw = postEdit(t, map[string]string{"private": "0", "allowinfochange": "1"})
+	if rw.Code != 200 || rw.Header().Get("X-Infohash") == hash || rw.Header().Get("X-Warning") == "" {
+		t.Errorf("status %d, infohash %q, warning %q", rw.Code, rw.Header().Get("X-Infohash"), rw.Header().Get("X-Warning"))
+	}
+}
+
+// 提交种子编辑表单
+func postEditForm(t *testing.T, data []byte, fields map[string]string) *httptest.ResponseRecorder {
+	form := url.Values{"torrent": {base64.StdEncoding.EncodeToString(data)}}
+	for k, v := range fields {
+		form.Set(k, v)
+	}
+
+	r, _ := http.NewRequest("POST", "/edit", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rw := httptest.NewRecorder()
+	handlers := beego.NewControllerRegister()
+	handlers.Add("/edit", &IndexController{}, "post:Edit")
+	handlers.ServeHTTP(rw, r)
+	return rw
+}
+
+func TestEditFormOnlyChangedFields(t *testing.T) {
+	useMemoryStore(t)
+	data, err := ioutil.ReadFile("../common/testdata/v1multi.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := common.ReadTorrent(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 与第一步显示的值相同
+	fields := map[string]string{
+		"trackers":  formatTiers(meta.Trackers()),
+		"webseeds":  strings.Join(meta.WebSeeds(), "\r\n"),
+		"comment":   meta.GetComment(),
+		"createdby": meta.CreatedBy,
+		"private":   "on",
+	}
+	rw := postEditForm(t, data, fields)
+	if rw.Code != 200 || !bytes.Equal(rw.Body.Bytes(), data) {
+		t.Errorf("unchanged form: status %d, body changed: %v", rw.Code, !bytes.Equal(rw.Body.Bytes(), data))
+	}
+
+	// 只修改注释, 其它字段保持原样
+	fields["comment"] = "line one\r\nline two"
+	rw = postEditForm(t, data, fields)
+	edited, err := common.ReadTorrent(rw.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.GetComment() != "line one\nline two" || edited.Comment8 != "" {
+		t.Errorf("comment %q, comment.utf-8 %q", edited.Comment, edited.Comment8)
+	}
+	if !reflect.DeepEqual(edited.AnnounceList, meta.AnnounceList) || edited.Announce != meta.Announce || edited.CreatedBy != meta.CreatedBy {
+		t.Errorf("announce %q %v, created by %q", edited.Announce, edited.AnnounceList, edited.CreatedBy)
+	}
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/beego/i18n"
+	"github.com/ylqjgm/SCDht/common"
+)
+
+// 载入测试用的语言文件
+func TestMain(m *testing.M) {
+	if err := i18n.SetMessage("en-US", "testdata/locale_en-US.ini"); err != nil {
+		panic(err)
+	}
+	common.Langs = i18n.ListLangs()
+
+	os.Exit(m.Run())
+}
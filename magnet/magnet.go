@@ -0,0 +1,300 @@
+// 磁力链接解析与生成(BEP 9, BEP 53)
+package magnet
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 磁力链接前缀
+const prefix = "magnet:?"
+
+// sha2-256的multihash前缀
+const sha256Multihash = "1220"
+
+var (
+	// 不是磁力链接
+	ErrNotMagnet = errors.New("magnet: not a magnet URI")
+	// 没有btih或btmh
+	ErrNoHash = errors.New("magnet: no urn:btih or urn:btmh exact topic")
+)
+
+// infohash格式错误
+type HashError struct {
+	Topic string // btih或btmh
+	Value string // 原始值
+}
+
+func (e *HashError) Error() string {
+	return fmt.Sprintf("magnet: invalid %s hash %q", e.Topic, e.Value)
+}
+
+// 参数格式错误
+type ParamError struct {
+	Key   string // 参数名
+	Value string // 参数值
+	Err   error  // 具体错误
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("magnet: invalid %s=%q: %v", e.Key, e.Value, e.Err)
+}
+
+// 选择下载的文件范围(BEP 53), 包含首尾
+type Range struct {
+	Start int
+	End   int
+}
+
+// 磁力链接结构
+type Magnet struct {
+	InfoHash   string   // v1 infohash, 40位大写十六进制
+	InfoHashV2 string   // v2 infohash, 64位大写十六进制
+	Name       string   // 显示名称(dn)
+	Length     int64    // 文件大小(xl)
+	Trackers   []string // tracker地址(tr)
+	WebSeeds   []string // web种子(ws)
+	Sources    []string // 种子文件下载地址(xs)
+	Acceptable []string // 备用种子文件下载地址(as)
+	Select     []Range  // 选择下载的文件(so)
+	Peers      []string // 节点地址(x.pe)
+}
+
+// 解析磁力链接
+func Parse(uri string) (*Magnet, error) {
+	uri = strings.TrimSpace(uri)
+	if len(uri) < len(prefix) || !strings.EqualFold(uri[:len(prefix)], prefix) {
+		return nil, ErrNotMagnet
+	}
+
+	m := new(Magnet)
+
+	for _, part := range strings.Split(uri[len(prefix):], "&") {
+		if part == "" {
+			continue
+		}
+
+		key, value := part, ""
+		if i := strings.Index(part, "="); i >= 0 {
+			key, value = part[:i], part[i+1:]
+		}
+
+		v, err := url.QueryUnescape(value)
+		if err != nil {
+			return nil, &ParamError{Key: key, Value: value, Err: err}
+		}
+
+		switch paramName(key) {
+		case "xt":
+			if err := m.setTopic(v); err != nil {
+				return nil, err
+			}
+		case "dn":
+			m.Name = v
+		case "xl":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return nil, &ParamError{Key: key, Value: v, Err: errors.New("not a valid length")}
+			}
+			m.Length = n
+		case "tr":
+			m.Trackers = appendUnique(m.Trackers, v)
+		case "ws":
+			m.WebSeeds = appendUnique(m.WebSeeds, v)
+		case "xs":
+			m.Sources = appendUnique(m.Sources, v)
+		case "as":
+			m.Acceptable = appendUnique(m.Acceptable, v)
+		case "so":
+			ranges, err := parseSelect(v)
+			if err != nil {
+				return nil, &ParamError{Key: key, Value: v, Err: err}
+			}
+			m.Select = append(m.Select, ranges...)
+		case "x.pe":
+			m.Peers = appendUnique(m.Peers, v)
+		}
+	}
+
+	if m.InfoHash == "" && m.InfoHashV2 == "" {
+		return nil, ErrNoHash
+	}
+
+	return m, nil
+}
+
+// 解析单独的infohash, 支持40位十六进制与32位base32格式
+func ParseHash(s string) (string, error) {
+	s = strings.TrimSpace(s)
+
+	switch len(s) {
+	case 40:
+		if _, err := hex.DecodeString(s); err == nil {
+			return strings.ToUpper(s), nil
+		}
+	case 32:
+		if b, err := base32.StdEncoding.DecodeString(strings.ToUpper(s)); err == nil {
+			return strings.ToUpper(hex.EncodeToString(b)), nil
+		}
+	}
+
+	return "", &HashError{Topic: "btih", Value: s}
+}
+
+// 解析v2 multihash, 只支持sha2-256
+func ParseMultihash(s string) (string, error) {
+	s = strings.TrimSpace(s)
+
+	if len(s) == 68 && strings.HasPrefix(s, sha256Multihash) {
+		if _, err := hex.DecodeString(s[4:]); err == nil {
+			return strings.ToUpper(s[4:]), nil
+		}
+	}
+
+	return "", &HashError{Topic: "btmh", Value: s}
+}
+
+// 生成磁力链接
+func (m *Magnet) String() string {
+	var params []string
+
+	if m.InfoHash != "" {
+		params = append(params, "xt=urn:btih:"+strings.ToUpper(m.InfoHash))
+	}
+	if m.InfoHashV2 != "" {
+		params = append(params, "xt=urn:btmh:"+sha256Multihash+strings.ToUpper(m.InfoHashV2))
+	}
+	if m.Name != "" {
+		params = append(params, "dn="+url.QueryEscape(m.Name))
+	}
+	if m.Length > 0 {
+		params = append(params, "xl="+strconv.FormatInt(m.Length, 10))
+	}
+	for _, tr := range m.Trackers {
+		params = append(params, "tr="+url.QueryEscape(tr))
+	}
+	for _, ws := range m.WebSeeds {
+		params = append(params, "ws="+url.QueryEscape(ws))
+	}
+	for _, xs := range m.Sources {
+		params = append(params, "xs="+url.QueryEscape(xs))
+	}
+	for _, as := range m.Acceptable {
+		params = append(params, "as="+url.QueryEscape(as))
+	}
+	if len(m.Select) > 0 {
+		params = append(params, "so="+formatSelect(m.Select))
+	}
+	for _, pe := range m.Peers {
+		params = append(params, "x.pe="+url.QueryEscape(pe))
+	}
+
+	return prefix + strings.Join(params, "&")
+}
+
+// 设置xt参数
+func (m *Magnet) setTopic(v string) error {
+	lower := strings.ToLower(v)
+
+	switch {
+	case strings.HasPrefix(lower, "urn:btih:"):
+		hash, err := ParseHash(v[len("urn:btih:"):])
+		if err != nil {
+			return err
+		}
+		m.InfoHash = hash
+	case strings.HasPrefix(lower, "urn:btmh:"):
+		hash, err := ParseMultihash(v[len("urn:btmh:"):])
+		if err != nil {
+			return err
+		}
+		m.InfoHashV2 = hash
+	}
+
+	// 其它类型的xt(如ed2k)忽略
+	return nil
+}
+
+// 去掉参数名中的序号, 如tr.1
+func paramName(key string) string {
+	key = strings.ToLower(key)
+	if key == "x.pe" {
+		return key
+	}
+
+	if i := strings.LastIndex(key, "."); i > 0 {
+		if _, err := strconv.Atoi(key[i+1:]); err == nil {
+			return key[:i]
+		}
+	}
+
+	return key
+}
+
+// 解析so参数, 如0,2,4-6
+func parseSelect(v string) ([]Range, error) {
+	var ranges []Range
+
+	for _, item := range strings.Split(v, ",") {
+		if item == "" {
+			continue
+		}
+
+		var r Range
+		var err error
+		if i := strings.Index(item, "-"); i >= 0 {
+			if r.Start, err = strconv.Atoi(item[:i]); err != nil {
+				return nil, err
+			}
+			if r.End, err = strconv.Atoi(item[i+1:]); err != nil {
+				return nil, err
+			}
+		} else {
+			if r.Start, err = strconv.Atoi(item); err != nil {
+				return nil, err
+			}
+			r.End = r.Start
+		}
+
+		if r.Start < 0 || r.End < r.Start {
+			return nil, errors.New("not a valid file range")
+		}
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+// 生成so参数
+func formatSelect(ranges []Range) string {
+	var items []string
+	for _, r := range ranges {
+		if r.Start == r.End {
+			items = append(items, strconv.Itoa(r.Start))
+		} else {
+			items = append(items, strconv.Itoa(r.Start)+"-"+strconv.Itoa(r.End))
+		}
+	}
+
+	return strings.Join(items, ",")
+}
+
+// 加入列表并去重
+func appendUnique(list []string, v string) []string {
+	if v == "" {
+		return list
+	}
+
+	for _, s := range list {
+		if s == v {
+			return list
+		}
+	}
+
+	return append(list, v)
+}
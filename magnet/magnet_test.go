@@ -0,0 +1,139 @@
+package magnet
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const (
+	testHash   = "000102030405060708090A0B0C0D0E0F10111213"
+	testHashV2 = "C42EB94B4AF2684552B7363BD590826204C37CB81958D9217DE914D083210500"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want Magnet
+	}{
+		// 十六进制btih, 小写转为大写
+		{"magnet:?xt=urn:btih:000102030405060708090a0b0c0d0e0f10111213", Magnet{InfoHash: testHash}},
+		// base32格式btih
+		{"magnet:?xt=urn:btih:AAAQEAYEAUDAOCAJBIFQYDIOB4IBCEQT", Magnet{InfoHash: testHash}},
+		{"MAGNET:?xt=urn:btih:aaaqeayeaudaocajbifqydiob4ibceqt", Magnet{InfoHash: testHash}},
+		// sha2-256 multihash
+		{"magnet:?xt=urn:btmh:1220" + testHashV2, Magnet{InfoHashV2: testHashV2}},
+		// 混合种子同时包含v1与v2
+		{
+			"magnet:?xt=urn:btih:" + testHash + "&xt=urn:btmh:1220" + testHashV2 + "&dn=a+b%2Fc&xl=1024",
+			Magnet{InfoHash: testHash, InfoHashV2: testHashV2, Name: "a b/c", Length: 1024},
+		},
+		// 带序号的参数名及重复的tracker
+		{
+			"magnet:?xt=urn:btih:" + testHash + "&tr.1=udp%3A%2F%2Fa%3A80&tr.2=udp%3A%2F%2Fb%3A80&tr=udp%3A%2F%2Fa%3A80&ws=http%3A%2F%2Fw%2F&x.pe=1.2.3.4%3A6881",
+			Magnet{InfoHash: testHash, Trackers: []string{"udp://a:80", "udp://b:80"}, WebSeeds: []string{"http://w/"}, Peers: []string{"1.2.3.4:6881"}},
+		},
+		// 文件选择
+		{"magnet:?xt=urn:btih:" + testHash + "&so=0,2,4-6", Magnet{InfoHash: testHash, Select: []Range{{0, 0}, {2, 2}, {4, 6}}}},
+		// 其它类型的xt忽略
+		{"magnet:?xt=urn:ed2k:abc&xt=urn:btih:" + testHash, Magnet{InfoHash: testHash}},
+	}
+
+	for _, tt := range tests {
+		m, err := Parse(tt.uri)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.uri, err)
+			continue
+		}
+		if !reflect.DeepEqual(*m, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.uri, *m, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		uri   string
+		err   error
+		topic string // HashError的类型
+		key   string // ParamError的参数名
+	}{
+		{"http://example.com/", ErrNotMagnet, "", ""},
+		{"magnet:", ErrNotMagnet, "", ""},
+		{"magnet:?dn=name", ErrNoHash, "", ""},
+		{"magnet:?xt=urn:ed2k:abc", ErrNoHash, "", ""},
+		{"magnet:?xt=urn:btih:0123", nil, "btih", ""},
+		{"magnet:?xt=urn:btih:ZZ0102030405060708090A0B0C0D0E0F10111213", nil, "btih", ""},
+		{"magnet:?xt=urn:btih:AAAQEAYEAUDAOCAJBIFQYDIOB4IBCE01", nil, "btih", ""},
+		{"magnet:?xt=urn:btmh:" + testHashV2, nil, "btmh", ""},
+		{"magnet:?xt=urn:btmh:1114" + testHashV2, nil, "btmh", ""},
+		{"magnet:?xt=urn:btmh:1220" + testHashV2[:62] + "ZZ", nil, "btmh", ""},
+		{"magnet:?xt=urn:btih:" + testHash + "&dn=%ZZ", nil, "", "dn"},
+		{"magnet:?xt=urn:btih:" + testHash + "&xl=-1", nil, "", "xl"},
+		{"magnet:?xt=urn:btih:" + testHash + "&xl=abc", nil, "", "xl"},
+		{"magnet:?xt=urn:btih:" + testHash + "&so=3-1", nil, "", "so"},
+		{"magnet:?xt=urn:btih:" + testHash + "&so=a", nil, "", "so"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.uri)
+		var hashErr *HashError
+		var paramErr *ParamError
+		switch {
+		case tt.err != nil:
+			if err != tt.err {
+				t.Errorf("Parse(%q) error = %v, want %v", tt.uri, err, tt.err)
+			}
+		case tt.topic != "":
+			if !errors.As(err, &hashErr) || hashErr.Topic != tt.topic {
+				t.Errorf("Parse(%q) error = %v, want %s HashError", tt.uri, err, tt.topic)
+			}
+		default:
+			if !errors.As(err, &paramErr) || paramErr.Key != tt.key {
+				t.Errorf("Parse(%q) error = %v, want %s ParamError", tt.uri, err, tt.key)
+			}
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	m := &Magnet{
+		InfoHash:   testHash,
+		InfoHashV2: testHashV2,
+		Name:       "a b",
+		Length:     10,
+		Trackers:   []string{"udp://a:80"},
+		Select:     []Range{{1, 1}, {3, 5}},
+	}
+	want := "magnet:?xt=urn:btih:" + testHash + "&xt=urn:btmh:1220" + testHashV2 + "&dn=a+b&xl=10&tr=udp%3A%2F%2Fa%3A80&so=1,3-5"
+	if got := m.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("magnet:?xt=urn:btih:" + testHash + "&dn=name&tr=udp%3A%2F%2Fa%3A80")
+	f.Add("magnet:?xt=urn:btih:AAAQEAYEAUDAOCAJBIFQYDIOB4IBCEQT&so=0,2-4")
+	f.Add("magnet:?xt=urn:btmh:1220" + testHashV2 + "&xl=100&ws=http%3A%2F%2Fw%2F&x.pe=1.2.3.4%3A1")
+	f.Add("magnet:?xt=urn:btih:" + testHash + "&xs=a&as=b&tr.1=c&tr.2=c")
+
+	f.Fuzz(func(t *testing.T, uri string) {
+		m, err := Parse(uri)
+		if err != nil {
+			return
+		}
+
+		// 生成的链接再次解析应得到相同结果
+		s := m.String()
+		again, err := Parse(s)
+		if err != nil {
+			t.Fatalf("Parse(%q) of String() of %q: %v", s, uri, err)
+		}
+		if !reflect.DeepEqual(m, again) {
+			t.Fatalf("round trip of %q changed:\n%+v\n%+v", uri, m, again)
+		}
+		if again.String() != s {
+			t.Fatalf("String() not stable: %q != %q", again.String(), s)
+		}
+	})
+}
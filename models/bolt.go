@@ -0,0 +1,753 @@
+// BoltDB单文件数据存储, 适用于不便部署MongoDB的小型站点
+package models
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// 数据桶名称, 与MongoDB的表名一致
+var (
+	bucketHash   = []byte("SC_Hash")   // 以infohash为键的hash
+	bucketInfo   = []byte("SC_Info")   // 以infohash为键的种子
+	bucketInfoV2 = []byte("SC_InfoV2") // v2格式infohash对应的infohash
+	bucketLog    = []byte("SC_Log")    // 以日期为键的统计
+	bucketSearch = []byte("SC_Search") // 以关键字为键的搜索
+	bucketMeta   = []byte("SC_Meta")   // 以迁移名称为键的迁移进度
+	bucketSwarm  = []byte("SC_Swarm")  // 以infohash为键的获取历史
+)
+
+// BoltDB数据存储, 数据以bson编码保存, 待入库hash与种子列表按索引查询, 其它查询遍历整个数据桶
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// 打开BoltDB数据文件, 不存在时创建
+func NewBoltStore(path string) (*BoltStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	// 文件被其它进程占用时不无限等待
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	// 创建数据桶
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketHash, bucketInfo, bucketInfoV2, bucketLog, bucketSearch, bucketMeta, bucketSwarm} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return createIndexes(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// 读取一条数据, 不存在时返回false
+func boltGet(b *bolt.Bucket, key string, val interface{}) bool {
+	data := b.Get([]byte(key))
+	if data == nil {
+		return false
+	}
+
+	return bson.Unmarshal(data, val) == nil
+}
+
+// 写入一条数据
+func boltPut(b *bolt.Bucket, key string, val interface{}) error {
+	data, err := bson.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(key), data)
+}
+
+// 读取并修改hash, 不存在时不做处理
+func (this *BoltStore) modifyHash(hash string, fn func(schash *SC_Hash)) error {
+	return this.db.Update(func(tx *bolt.Tx) error {
+		var schash SC_Hash
+		if !boltGet(tx.Bucket(bucketHash), hash, &schash) {
+			return nil
+		}
+		old := schash
+		fn(&schash)
+		return putHash(tx, &old, &schash)
+	})
+}
+
+// 读取并修改种子, 不存在时不做处理
+func (this *BoltStore) modifyInfo(hash string, fn func(scinfo *SC_Info)) error {
+	return this.db.Update(func(tx *bolt.Tx) error {
+		var scinfo SC_Info
+		if !boltGet(tx.Bucket(bucketInfo), hash, &scinfo) {
+			return nil
+		}
+		old := scinfo
+		fn(&scinfo)
+		return putInfo(tx, &old, &scinfo)
+	})
+}
+
+/********************* SC_Hash 操作 *********************/
+
+// 保存Hash数据, 返回是否为新数据
+func (this *BoltStore) SaveHash(schash *SC_Hash) (bool, error) {
+	isNew := false
+	err := this.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketHash)
+
+		// 存在则进行自增处理, 并更新最后获取时间
+		var old SC_Hash
+		if boltGet(b, schash.InfoHash, &old) {
+			cur := old
+			cur.Hot++
+			cur.Announce += schash.Announce
+			cur.GetPeers += schash.GetPeers
+			cur.LastSeen = time.Now()
+			return putHash(tx, &old, &cur)
+		}
+
+		isNew = true
+		schash.Id = bson.NewObjectId()
+		schash.Hot = 1
+		schash.Invalid = 0
+		schash.CreateTime = time.Now()
+		schash.LastSeen = schash.CreateTime
+		return putHash(tx, nil, schash)
+	})
+
+	return isNew, err
+}
+
+// hash是否存在
+func (this *BoltStore) HasHash(hash string) bool {
+	has := false
+	this.db.View(func(tx *bolt.Tx) error {
+		has = tx.Bucket(bucketHash).Get([]byte(hash)) != nil
+		return nil
+	})
+	return has
+}
+
+// 获取hash数据
+func (this *BoltStore) GetHash(hash string) (SC_Hash, bool) {
+	var schash SC_Hash
+	has := false
+	this.db.View(func(tx *bolt.Tx) error {
+		has = boltGet(tx.Bucket(bucketHash), hash, &schash)
+		return nil
+	})
+	return schash, has
+}
+
+// 验证此Hash是否已经入库
+func (this *BoltStore) IsPut(hash string) bool {
+	var schash SC_Hash
+	this.db.View(func(tx *bolt.Tx) error {
+		boltGet(tx.Bucket(bucketHash), hash, &schash)
+		return nil
+	})
+	return schash.IsPut
+}
+
+// 设置Hash为已入库状态
+func (this *BoltStore) SetPut(hash string) error {
+	return this.modifyHash(hash, func(schash *SC_Hash) { schash.IsPut = true })
+}
+
+// 失败次数加一
+func (this *BoltStore) AddInvalid(hash string) error {
+	return this.modifyHash(hash, func(schash *SC_Hash) { schash.Invalid++ })
+}
+
+// 获取等待入库的hash
+func (this *BoltStore) PendingHashes(sort string, limit int) []SC_Hash {
+	// 有索引的排序字段只读取需要的数量
+	if name, desc := sortIndex(pendingSortIndex, sort); name != "" {
+		var hashes []SC_Hash
+		this.db.View(func(tx *bolt.Tx) error {
+			b := tx.Bucket(bucketHash)
+			scanIndex(tx, name, nil, desc, func(hash []byte) bool {
+				var schash SC_Hash
+				if boltGet(b, string(hash), &schash) && pendingHash(&schash, sort) {
+					hashes = append(hashes, schash)
+				}
+				return limit <= 0 || len(hashes) < limit
+			})
+			return nil
+		})
+		return hashes
+	}
+
+	var hashes []SC_Hash
+	this.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketHash).ForEach(func(k, v []byte) error {
+			var schash SC_Hash
+			if bson.Unmarshal(v, &schash) == nil && pendingHash(&schash, sort) {
+				hashes = append(hashes, schash)
+			}
+			return nil
+		})
+	})
+
+	sortHashes(hashes, sort)
+	start, end := pageRange(len(hashes), 0, limit)
+	return hashes[start:end]
+}
+
+// 按编号顺序获取满足条件的hash
+func (this *BoltStore) ScanHashes(query HashQuery, limit int) []SC_Hash {
+	var seek []byte
+	if query.After != "" {
+		seek = []byte(query.After)
+	}
+
+	var hashes []SC_Hash
+	this.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketHash)
+		scanIndex(tx, indexHashId, seek, false, func(hash []byte) bool {
+			var schash SC_Hash
+			if boltGet(b, string(hash), &schash) && matchHash(query, &schash) {
+				hashes = append(hashes, schash)
+			}
+			return limit <= 0 || len(hashes) < limit
+		})
+		return nil
+	})
+	return hashes
+}
+
+// 按条件统计hash数量
+func (this *BoltStore) CountHashes(query HashQuery) int {
+	// 没有条件时直接获取数据桶的数量
+	if query == (HashQuery{}) {
+		n := 0
+		this.db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket(bucketHash).Stats().KeyN
+			return nil
+		})
+		return n
+	}
+
+	n := 0
+	this.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketHash).ForEach(func(k, v []byte) error {
+			var schash SC_Hash
+			if bson.Unmarshal(v, &schash) == nil && matchHash(query, &schash) {
+				n++
+			}
+			return nil
+		})
+	})
+	return n
+}
+
+// 删除hash
+func (this *BoltStore) DeleteHashes(hashes []string) (int, error) {
+	n := 0
+	err := this.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketHash)
+		for _, hash := range hashes {
+			var schash SC_Hash
+			if !boltGet(b, hash, &schash) {
+				continue
+			}
+			if err := updateIndex(tx, hash, hashIndexKeys(&schash), nil); err != nil {
+				return err
+			}
+			if err := b.Delete([]byte(hash)); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// 不存在时插入hash
+func (this *BoltStore) ImportHash(schash *SC_Hash) (bool, error) {
+	isNew := false
+	err := this.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketHash).Get([]byte(schash.InfoHash)) != nil {
+			return nil
+		}
+		isNew = true
+		schash.Id = bson.NewObjectId()
+		return putHash(tx, nil, schash)
+	})
+
+	return isNew, err
+}
+
+// 不存在时写入一条数据, 返回是否写入
+func (this *BoltStore) insert(bucket []byte, key string, val func() interface{}) (bool, error) {
+	isNew := false
+	err := this.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b.Get([]byte(key)) != nil {
+			return nil
+		}
+		isNew = true
+		return boltPut(b, key, val())
+	})
+
+	return isNew, err
+}
+
+/********************* SC_Info 操作 *********************/
+
+// 保存种子数据, 返回是否为新数据
+func (this *BoltStore) SaveInfo(scinfo *SC_Info) (bool, error) {
+	isNew := false
+	err := this.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketInfo)
+
+		// 存在则更新
+		var old SC_Info
+		if boltGet(b, scinfo.InfoHash, &old) {
+			cur := old
+			mergeInfo(&cur, scinfo)
+			return putInfo(tx, &old, &cur)
+		}
+
+		isNew = true
+		return insertInfo(tx, scinfo)
+	})
+
+	return isNew, err
+}
+
+// 不存在时插入种子
+func (this *BoltStore) ImportInfo(scinfo *SC_Info) (bool, error) {
+	isNew := false
+	err := this.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketInfo).Get([]byte(scinfo.InfoHash)) != nil {
+			return nil
+		}
+		isNew = true
+		return insertInfo(tx, scinfo)
+	})
+
+	return isNew, err
+}
+
+// 写入新的种子及v2格式infohash
+func insertInfo(tx *bolt.Tx, scinfo *SC_Info) error {
+	scinfo.Id = bson.NewObjectId()
+	if scinfo.InfoHashV2 != "" {
+		if err := tx.Bucket(bucketInfoV2).Put([]byte(scinfo.InfoHashV2), []byte(scinfo.InfoHash)); err != nil {
+			return err
+		}
+	}
+	return putInfo(tx, nil, scinfo)
+}
+
+// 通过infohash获取种子
+func (this *BoltStore) GetInfo(hash string) (SC_Info, bool) {
+	var scinfo SC_Info
+	has := false
+	this.db.View(func(tx *bolt.Tx) error {
+		has = boltGet(tx.Bucket(bucketInfo), hash, &scinfo)
+		return nil
+	})
+	return scinfo, has
+}
+
+// 通过v2格式infohash获取种子
+func (this *BoltStore) GetInfoV2(hash string) (SC_Info, bool) {
+	var scinfo SC_Info
+	has := false
+	this.db.View(func(tx *bolt.Tx) error {
+		if v1 := tx.Bucket(bucketInfoV2).Get([]byte(hash)); v1 != nil {
+			has = boltGet(tx.Bucket(bucketInfo), string(v1), &scinfo)
+		}
+		return nil
+	})
+	return scinfo, has
+}
+
+// 种子是否存在
+func (this *BoltStore) HasInfo(hash string) bool {
+	has := false
+	this.db.View(func(tx *bolt.Tx) error {
+		has = tx.Bucket(bucketInfo).Get([]byte(hash)) != nil
+		return nil
+	})
+	return has
+}
+
+// 获取已入库的infohash
+func (this *BoltStore) InfoHashes(hashes []string) map[string]bool {
+	has := make(map[string]bool)
+	this.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketInfo)
+		for _, hash := range hashes {
+			if b.Get([]byte(hash)) != nil {
+				has[hash] = true
+			}
+		}
+		return nil
+	})
+	return has
+}
+
+// 修改热度信息
+func (this *BoltStore) AddHot(hash string) error {
+	return this.modifyInfo(hash, func(scinfo *SC_Info) { scinfo.Hot++ })
+}
+
+// 查看次数加一
+func (this *BoltStore) AddViews(hash string) error {
+	return this.modifyInfo(hash, func(scinfo *SC_Info) { scinfo.Views++ })
+}
+
+// 设置同一内容指纹中显示的种子, 在一个事务中更新同组的全部种子
+func (this *BoltStore) SetGroupBest(fingerprint, hash string) error {
+	return this.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketInfo)
+
+		// 先取出同组的infohash, 写入时不移动索引游标
+		var hashes [][]byte
+		prefix := fingerprintPrefix(fingerprint)
+		c := tx.Bucket(bucketIndex).Bucket([]byte(indexFingerprint)).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			hashes = append(hashes, append([]byte(nil), v...))
+		}
+
+		for _, h := range hashes {
+			var scinfo SC_Info
+			if !boltGet(b, string(h), &scinfo) {
+				continue
+			}
+			if duplicate := scinfo.InfoHash != hash; scinfo.Duplicate != duplicate {
+				old := scinfo
+				scinfo.Duplicate = duplicate
+				if err := putInfo(tx, &old, &scinfo); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// 按条件查询种子
+func (this *BoltStore) FindInfos(query InfoQuery, start, length int, sort string) []SC_Info {
+	if start < 0 {
+		start = 0
+	}
+
+	// 按指纹查询时只读取同组的种子
+	name, desc := sortIndex(infoSortIndex, sort)
+	if name == "" || query.Fingerprint != "" {
+		infos := this.filterInfos(query, true)
+		sortInfos(infos, sort)
+		start, end := pageRange(len(infos), start, length)
+		return infos[start:end]
+	}
+
+	// 按索引顺序过滤, 只完整解码当前页的种子
+	var seek []byte
+	if name == indexInfoId && query.After != "" {
+		seek = []byte(query.After)
+	}
+	match := keyMatcher(query.Key)
+	var infos []SC_Info
+	this.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketInfo)
+		skip := start
+		scanIndex(tx, name, seek, desc, func(hash []byte) bool {
+			fields, ok := decodeInfoFields(b.Get(hash))
+			if !ok || !matchInfo(query, match, fields) {
+				return true
+			}
+			if skip > 0 {
+				skip--
+				return true
+			}
+
+			var scinfo SC_Info
+			if boltGet(b, string(hash), &scinfo) {
+				infos = append(infos, scinfo)
+			}
+			return length <= 0 || len(infos) < length
+		})
+		return nil
+	})
+	return infos
+}
+
+// 按条件统计种子数量
+func (this *BoltStore) CountInfos(query InfoQuery) int {
+	// 没有条件时直接获取数据桶的数量
+	if query.isEmpty() {
+		n := 0
+		this.db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket(bucketInfo).Stats().KeyN
+			return nil
+		})
+		return n
+	}
+
+	return len(this.filterInfos(query, false))
+}
+
+// 获取满足条件的种子, full为false时只解码过滤需要的字段
+func (this *BoltStore) filterInfos(query InfoQuery, full bool) []SC_Info {
+	match := keyMatcher(query.Key)
+	var infos []SC_Info
+	add := func(v []byte) {
+		fields, ok := decodeInfoFields(v)
+		if !ok || !matchInfo(query, match, fields) {
+			return
+		}
+		if !full {
+			infos = append(infos, *fields)
+			return
+		}
+		var scinfo SC_Info
+		if bson.Unmarshal(v, &scinfo) == nil {
+			infos = append(infos, scinfo)
+		}
+	}
+
+	this.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketInfo)
+
+		// 按指纹查询时只遍历指纹索引中的同组种子
+		if query.Fingerprint != "" {
+			prefix := fingerprintPrefix(query.Fingerprint)
+			c := tx.Bucket(bucketIndex).Bucket([]byte(indexFingerprint)).Cursor()
+			for k, hash := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, hash = c.Next() {
+				if v := b.Get(hash); v != nil {
+					add(v)
+				}
+			}
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			add(v)
+			return nil
+		})
+	})
+	return infos
+}
+
+/********************* SC_Log 操作 *********************/
+
+// 累加统计数据, 返回是否为当天的第一条统计
+func (this *BoltStore) AddLog(day string, counts map[string]int64) (bool, error) {
+	isNew := false
+	err := this.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketLog)
+
+		var sclog SC_Log
+		if !boltGet(b, day, &sclog) {
+			isNew = true
+			sclog = SC_Log{Id: bson.NewObjectId(), Day: day}
+		}
+		sclog.Add(counts)
+		return boltPut(b, day, &sclog)
+	})
+
+	return isNew, err
+}
+
+// 获取指定日期的统计
+func (this *BoltStore) GetLog(day string) SC_Log {
+	var sclog SC_Log
+	this.db.View(func(tx *bolt.Tx) error {
+		boltGet(tx.Bucket(bucketLog), day, &sclog)
+		return nil
+	})
+	return sclog
+}
+
+// 获取日期范围内的统计, 包含起止日期
+func (this *BoltStore) FindLogs(from, to string) []SC_Log {
+	var logs []SC_Log
+	this.db.View(func(tx *bolt.Tx) error {
+		// 日期格式固定, 键的顺序即为日期顺序
+		c := tx.Bucket(bucketLog).Cursor()
+		for k, v := c.Seek([]byte(from)); k != nil && string(k) <= to; k, v = c.Next() {
+			var sclog SC_Log
+			if bson.Unmarshal(v, &sclog) == nil {
+				logs = append(logs, sclog)
+			}
+		}
+		return nil
+	})
+	return logs
+}
+
+// 不存在当天的统计时插入
+func (this *BoltStore) ImportLog(sclog *SC_Log) (bool, error) {
+	return this.insert(bucketLog, sclog.Day, func() interface{} {
+		sclog.Id = bson.NewObjectId()
+		return sclog
+	})
+}
+
+/********************* SC_Search 操作 *********************/
+
+// 保存搜索数据, 返回是否为新的关键字
+func (this *BoltStore) SaveSearch(scsearch *SC_Search) (bool, error) {
+	isNew := false
+	err := this.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketSearch)
+
+		// 存在则自增并更新查询时间
+		var old SC_Search
+		if boltGet(b, scsearch.Caption, &old) {
+			old.Views++
+			old.SearchTime = time.Now()
+			return boltPut(b, old.Caption, &old)
+		}
+
+		isNew = true
+		scsearch.Id = bson.NewObjectId()
+		scsearch.Views = 1
+		scsearch.SearchTime = time.Now()
+		return boltPut(b, scsearch.Caption, scsearch)
+	})
+
+	return isNew, err
+}
+
+// 获取搜索列表
+func (this *BoltStore) FindSearches(key string, length int, sort string) []SC_Search {
+	match := keyMatcher(key)
+	var searches []SC_Search
+	this.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSearch).ForEach(func(k, v []byte) error {
+			var scsearch SC_Search
+			if bson.Unmarshal(v, &scsearch) == nil && match(scsearch.Caption) {
+				searches = append(searches, scsearch)
+			}
+			return nil
+		})
+	})
+
+	sortSearches(searches, sort)
+	start, end := pageRange(len(searches), 0, length)
+	return searches[start:end]
+}
+
+// 按编号顺序获取满足条件的搜索
+func (this *BoltStore) ScanSearches(query SearchQuery, limit int) []SC_Search {
+	var searches []SC_Search
+	this.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSearch).ForEach(func(k, v []byte) error {
+			var scsearch SC_Search
+			if bson.Unmarshal(v, &scsearch) == nil && matchSearch(query, &scsearch) {
+				searches = append(searches, scsearch)
+			}
+			return nil
+		})
+	})
+
+	sortSearches(searches, "_id")
+	start, end := pageRange(len(searches), 0, limit)
+	return searches[start:end]
+}
+
+// 不存在时插入搜索
+func (this *BoltStore) ImportSearch(scsearch *SC_Search) (bool, error) {
+	return this.insert(bucketSearch, scsearch.Caption, func() interface{} {
+		scsearch.Id = bson.NewObjectId()
+		return scsearch
+	})
+}
+
+/********************* SC_Swarm 操作 *********************/
+
+// 合并获取记录, 删除超出保留天数的日期并更新近期热度
+func (this *BoltStore) AddSwarm(hash string, first, last time.Time, days map[string]int64) error {
+	return this.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketSwarm)
+
+		var swarm SC_Swarm
+		if !boltGet(b, hash, &swarm) {
+			swarm.InfoHash = hash
+		}
+		swarm.merge(first, last, days)
+		swarm.trim(time.Now())
+		return boltPut(b, hash, &swarm)
+	})
+}
+
+// 获取种子的获取历史
+func (this *BoltStore) GetSwarm(hash string) (SC_Swarm, bool) {
+	var swarm SC_Swarm
+	has := false
+	this.db.View(func(tx *bolt.Tx) error {
+		has = boltGet(tx.Bucket(bucketSwarm), hash, &swarm)
+		return nil
+	})
+	return swarm, has
+}
+
+// 获取近期热度最高的种子历史
+func (this *BoltStore) TrendingSwarms(since time.Time, limit int) []SC_Swarm {
+	var swarms []SC_Swarm
+	this.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSwarm).ForEach(func(k, v []byte) error {
+			var swarm SC_Swarm
+			if bson.Unmarshal(v, &swarm) == nil && !swarm.LastSeen.Before(since) {
+				swarms = append(swarms, swarm)
+			}
+			return nil
+		})
+	})
+
+	sortSwarms(swarms)
+	start, end := pageRange(len(swarms), 0, limit)
+	return swarms[start:end]
+}
+
+/********************* SC_Meta 操作 *********************/
+
+// 获取数据迁移的执行进度
+func (this *BoltStore) GetMeta(id string) (SC_Meta, bool) {
+	var meta SC_Meta
+	has := false
+	this.db.View(func(tx *bolt.Tx) error {
+		has = boltGet(tx.Bucket(bucketMeta), id, &meta)
+		return nil
+	})
+	return meta, has
+}
+
+// 保存数据迁移的执行进度
+func (this *BoltStore) SaveMeta(meta *SC_Meta) error {
+	return this.db.Update(func(tx *bolt.Tx) error {
+		return boltPut(tx.Bucket(bucketMeta), meta.Id, meta)
+	})
+}
+
+// 关闭数据文件
+func (this *BoltStore) Close() error {
+	return this.db.Close()
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 统计索引中的数据数量
+func indexCount(t *testing.T, store *BoltStore, name string) int {
+	n := 0
+	store.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketIndex).Bucket([]byte(name)).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+func TestBoltIndexNoStaleEntries(t *testing.T) {
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// 写入时间精确到纳秒, 读出后只保留毫秒, 更新时必须删除原索引
+	for i := 0; i < 3; i++ {
+		store.SaveHash(&SC_Hash{InfoHash: "A"})
+		store.SaveHash(&SC_Hash{InfoHash: "B"})
+	}
+	store.AddInvalid("B")
+	store.SetPut("A")
+
+	for name, want := range map[string]int{indexHashId: 2, indexPendingHot: 1, indexPendingSeen: 1, indexPendingCreate: 1} {
+		if n := indexCount(t, store, name); n != want {
+			t.Errorf("index %s has %d entries, want %d", name, n, want)
+		}
+	}
+
+	store.SaveInfo(&SC_Info{InfoHash: "A", PutTime: time.Now(), Fingerprint: "fp"})
+	store.AddHot("A")
+	store.SaveInfo(&SC_Info{InfoHash: "A", PutTime: time.Now(), Fingerprint: "fp2"})
+	for name, want := range map[string]int{indexInfoId: 1, indexInfoHot: 1, indexInfoPut: 1, indexFingerprint: 1} {
+		if n := indexCount(t, store, name); n != want {
+			t.Errorf("index %s has %d entries, want %d", name, n, want)
+		}
+	}
+
+	store.DeleteHashes([]string{"A", "B"})
+	for _, name := range []string{indexHashId, indexPendingHot, indexPendingSeen, indexPendingCreate} {
+		if n := indexCount(t, store, name); n != 0 {
+			t.Errorf("index %s has %d entries after delete", name, n)
+		}
+	}
+}
+
+func TestBoltRebuildIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	store.ImportHash(&SC_Hash{InfoHash: "A", Hot: 1, LastSeen: now})
+	store.ImportHash(&SC_Hash{InfoHash: "B", Hot: 2, LastSeen: now})
+	store.SaveInfo(&SC_Info{InfoHash: "A", Hot: 3, PutTime: now, Fingerprint: "fp"})
+
+	// 模拟没有索引的旧数据文件
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(bucketIndex)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	checkNames(t, "bolt", "-hot", hashNames(store.PendingHashes("-hot", 0)), "B", "A")
+	checkNames(t, "bolt", "scan", hashNames(store.ScanHashes(HashQuery{}, 0)), "A", "B")
+	checkNames(t, "bolt", "infos", infoNames(store.FindInfos(InfoQuery{}, 0, 0, "-puttime")), "A")
+	checkNames(t, "bolt", "fingerprint", infoNames(store.FindInfos(InfoQuery{Fingerprint: "fp"}, 0, 0, "-hot")), "A")
+}
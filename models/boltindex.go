@@ -0,0 +1,256 @@
+// BoltDB存储的二级索引, 避免调度与列表查询遍历并解码整个数据桶
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// 索引数据桶, 每个索引为其中的一个子数据桶, 键为排序值加infohash, 值为infohash
+var bucketIndex = []byte("SC_Index")
+
+// 索引名称
+const (
+	indexHashId        = "hash._id"                // hash编号
+	indexPendingHot    = "hash.pending.hot"        // 等待入库hash的热度
+	indexPendingSeen   = "hash.pending.lastseen"   // 等待入库hash的最后获取时间
+	indexPendingCreate = "hash.pending.createtime" // 等待入库hash的首次获取时间
+	indexInfoId        = "info._id"                // 种子编号
+	indexInfoHot       = "info.hot"                // 种子热度
+	indexInfoPut       = "info.puttime"            // 种子入库时间
+	indexFingerprint   = "info.fingerprint"        // 内容指纹
+)
+
+// 全部索引
+var boltIndexes = []string{
+	indexHashId, indexPendingHot, indexPendingSeen, indexPendingCreate,
+	indexInfoId, indexInfoHot, indexInfoPut, indexFingerprint,
+}
+
+// 排序字段对应的索引
+var (
+	pendingSortIndex = map[string]string{"hot": indexPendingHot, "lastseen": indexPendingSeen, "createtime": indexPendingCreate}
+	infoSortIndex    = map[string]string{"": indexInfoId, "_id": indexInfoId, "hot": indexInfoHot, "puttime": indexInfoPut}
+)
+
+// 整数索引值, 负数排在正数之前
+func indexInt(n int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(n)^1<<63)
+	return b
+}
+
+// 时间索引值, 与bson一致精确到毫秒, 保证读出后计算的值不变
+func indexTime(t time.Time) []byte {
+	return indexInt(t.Unix()*1000 + int64(t.Nanosecond()/1e6))
+}
+
+// 计算hash的索引值, 不加入的索引没有对应的键
+func hashIndexKeys(schash *SC_Hash) map[string][]byte {
+	keys := map[string][]byte{indexHashId: []byte(schash.Id)}
+
+	// 只索引等待入库的hash
+	if pendingHash(schash, "") {
+		keys[indexPendingHot] = indexInt(schash.Hot)
+		keys[indexPendingSeen] = indexTime(schash.LastSeen)
+		if pendingHash(schash, "createtime") {
+			keys[indexPendingCreate] = indexTime(schash.CreateTime)
+		}
+	}
+
+	return keys
+}
+
+// 计算种子的索引值
+func infoIndexKeys(scinfo *SC_Info) map[string][]byte {
+	keys := map[string][]byte{
+		indexInfoId:  []byte(scinfo.Id),
+		indexInfoHot: indexInt(scinfo.Hot),
+		indexInfoPut: indexTime(scinfo.PutTime),
+	}
+	if scinfo.Fingerprint != "" {
+		keys[indexFingerprint] = fingerprintPrefix(scinfo.Fingerprint)
+	}
+
+	return keys
+}
+
+// 指纹索引的键前缀
+func fingerprintPrefix(fingerprint string) []byte {
+	return []byte(fingerprint + "\x00")
+}
+
+// 生成索引键
+func indexEntry(value []byte, hash string) []byte {
+	key := make([]byte, 0, len(value)+len(hash))
+	return append(append(key, value...), hash...)
+}
+
+// 按新旧索引值更新索引, old为nil表示新数据, cur为nil表示删除
+func updateIndex(tx *bolt.Tx, hash string, old, cur map[string][]byte) error {
+	idx := tx.Bucket(bucketIndex)
+
+	for name, value := range old {
+		if v, ok := cur[name]; ok && bytes.Equal(v, value) {
+			continue
+		}
+		if err := idx.Bucket([]byte(name)).Delete(indexEntry(value, hash)); err != nil {
+			return err
+		}
+	}
+
+	for name, value := range cur {
+		if v, ok := old[name]; ok && bytes.Equal(v, value) {
+			continue
+		}
+		if err := idx.Bucket([]byte(name)).Put(indexEntry(value, hash), []byte(hash)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 创建索引数据桶, 新建时为已有数据生成索引
+func createIndexes(tx *bolt.Tx) error {
+	rebuild := tx.Bucket(bucketIndex) == nil
+
+	idx, err := tx.CreateBucketIfNotExists(bucketIndex)
+	if err != nil {
+		return err
+	}
+	for _, name := range boltIndexes {
+		if _, err := idx.CreateBucketIfNotExists([]byte(name)); err != nil {
+			return err
+		}
+	}
+
+	if !rebuild {
+		return nil
+	}
+
+	err = tx.Bucket(bucketHash).ForEach(func(k, v []byte) error {
+		var schash SC_Hash
+		if bson.Unmarshal(v, &schash) != nil {
+			return nil
+		}
+		return updateIndex(tx, string(k), nil, hashIndexKeys(&schash))
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(bucketInfo).ForEach(func(k, v []byte) error {
+		var scinfo SC_Info
+		if bson.Unmarshal(v, &scinfo) != nil {
+			return nil
+		}
+		return updateIndex(tx, string(k), nil, infoIndexKeys(&scinfo))
+	})
+}
+
+// 写入hash并更新索引, old为nil表示新数据
+func putHash(tx *bolt.Tx, old, schash *SC_Hash) error {
+	var oldKeys map[string][]byte
+	if old != nil {
+		oldKeys = hashIndexKeys(old)
+	}
+	if err := updateIndex(tx, schash.InfoHash, oldKeys, hashIndexKeys(schash)); err != nil {
+		return err
+	}
+
+	return boltPut(tx.Bucket(bucketHash), schash.InfoHash, schash)
+}
+
+// 写入种子并更新索引, old为nil表示新数据
+func putInfo(tx *bolt.Tx, old, scinfo *SC_Info) error {
+	var oldKeys map[string][]byte
+	if old != nil {
+		oldKeys = infoIndexKeys(old)
+	}
+	if err := updateIndex(tx, scinfo.InfoHash, oldKeys, infoIndexKeys(scinfo)); err != nil {
+		return err
+	}
+
+	return boltPut(tx.Bucket(bucketInfo), scinfo.InfoHash, scinfo)
+}
+
+// 按索引顺序遍历infohash, 从seek开始, desc为true时倒序, fn返回false时停止
+func scanIndex(tx *bolt.Tx, name string, seek []byte, desc bool, fn func(hash []byte) bool) {
+	c := tx.Bucket(bucketIndex).Bucket([]byte(name)).Cursor()
+
+	var k, v []byte
+	switch {
+	case desc:
+		k, v = c.Last()
+	case seek != nil:
+		k, v = c.Seek(seek)
+	default:
+		k, v = c.First()
+	}
+
+	for k != nil && fn(v) {
+		if desc {
+			k, v = c.Prev()
+		} else {
+			k, v = c.Next()
+		}
+	}
+}
+
+// 获取排序字段对应的索引及是否倒序
+func sortIndex(indexes map[string]string, sort string) (string, bool) {
+	return indexes[strings.TrimPrefix(sort, "-")], strings.HasPrefix(sort, "-")
+}
+
+// 过滤种子时只解码需要的字段, 不解码文件列表等较大的字段
+type infoFields struct {
+	Id          bson.ObjectId `bson:"_id"`
+	Caption     string        `bson:"caption"`
+	Hot         int64         `bson:"hot"`
+	Files       []fileParts   `bson:"files"`
+	Category    string        `bson:"category"`
+	Release     *Release      `bson:"release"`
+	Fingerprint string        `bson:"fingerprint"`
+	Duplicate   bool          `bson:"duplicate"`
+	Keys        []string      `bson:"keys"`
+	PutTime     time.Time     `bson:"puttime"`
+}
+
+// 文件只解码路径数组
+type fileParts struct {
+	Parts []string `bson:"parts"`
+}
+
+// 解码过滤需要的字段
+func decodeInfoFields(data []byte) (*SC_Info, bool) {
+	var f infoFields
+	if bson.Unmarshal(data, &f) != nil {
+		return nil, false
+	}
+
+	scinfo := &SC_Info{
+		Id:          f.Id,
+		Caption:     f.Caption,
+		Hot:         f.Hot,
+		Category:    f.Category,
+		Release:     f.Release,
+		Fingerprint: f.Fingerprint,
+		Duplicate:   f.Duplicate,
+		Keys:        f.Keys,
+		PutTime:     f.PutTime,
+	}
+	if len(f.Files) > 0 {
+		scinfo.Files = make([]File, len(f.Files))
+		for i := range f.Files {
+			scinfo.Files[i].Parts = f.Files[i].Parts
+		}
+	}
+
+	return scinfo, true
+}
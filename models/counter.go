@@ -0,0 +1,151 @@
+// 统计数据缓冲
+package models
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 统计数据缓冲, 在内存中累加统计字段, 定时按日期批量写入
+type LogCounter struct {
+	*flushTicker
+	mu     sync.Mutex
+	store  Store                       // 写入的数据存储
+	counts map[string]map[string]int64 // 以日期为键的待写入统计
+}
+
+// 当前使用的统计缓冲
+var Counter *LogCounter
+
+// 创建统计缓冲
+func NewLogCounter(store Store) *LogCounter {
+	counter := &LogCounter{
+		store:  store,
+		counts: make(map[string]map[string]int64),
+	}
+	counter.flushTicker = newFlushTicker("flush logs: ", counter.Flush)
+	return counter
+}
+
+// 指定时间的统计字段加一, 同时计入当天与当时的小时
+func (this *LogCounter) Add(t time.Time, field string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.add(LogDay(t), logCounts(t, field, 1))
+}
+
+// 累加统计, 调用前需加锁
+func (this *LogCounter) add(day string, counts map[string]int64) {
+	fields, ok := this.counts[day]
+	if !ok {
+		fields = make(map[string]int64)
+		this.counts[day] = fields
+	}
+	for field, n := range counts {
+		fields[field] += n
+	}
+}
+
+// 获取尚未写入的统计
+func (this *LogCounter) Pending(day string) map[string]int64 {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	pending := make(map[string]int64)
+	for field, n := range this.counts[day] {
+		pending[field] = n
+	}
+	return pending
+}
+
+// 写入缓冲中的统计, 写入失败的日期保留到下次写入
+func (this *LogCounter) Flush() error {
+	// 取出当前缓冲, 写入期间的新统计进入新的缓冲
+	this.mu.Lock()
+	counts := this.counts
+	this.counts = make(map[string]map[string]int64)
+	this.mu.Unlock()
+
+	var last error
+	for day, fields := range counts {
+		if _, err := this.store.AddLog(day, fields); err != nil {
+			last = err
+
+			this.mu.Lock()
+			this.add(day, fields)
+			this.mu.Unlock()
+		}
+	}
+
+	return last
+}
+
+// 定时写入, 由缓冲嵌入后提供Start与Stop
+type flushTicker struct {
+	name  string       // 写入失败时输出的前缀
+	flush func() error // 写入函数
+	mu    sync.Mutex
+	stop  chan struct{} // 停止信号
+	done  chan struct{} // 已停止
+	start sync.Once
+	once  sync.Once
+	runs  bool // 是否已启动定时写入
+}
+
+// 创建定时写入
+func newFlushTicker(name string, flush func() error) *flushTicker {
+	return &flushTicker{
+		name:  name,
+		flush: flush,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// 启动定时写入, 多次调用只启动一次
+func (this *flushTicker) Start(interval time.Duration) {
+	this.start.Do(func() {
+		this.mu.Lock()
+		this.runs = true
+		this.mu.Unlock()
+
+		go this.run(interval)
+	})
+}
+
+// 按间隔定时写入, 直到调用Stop
+func (this *flushTicker) run(interval time.Duration) {
+	defer close(this.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := this.flush(); err != nil {
+				fmt.Println(this.name, err.Error())
+			}
+		case <-this.stop:
+			return
+		}
+	}
+}
+
+// 停止定时写入并写入剩余的数据
+func (this *flushTicker) Stop() error {
+	this.once.Do(func() {
+		close(this.stop)
+	})
+
+	this.mu.Lock()
+	runs := this.runs
+	this.mu.Unlock()
+	if runs {
+		<-this.done
+	}
+
+	return this.flush()
+}
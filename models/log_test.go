@@ -0,0 +1,94 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSaveLog(t *testing.T) {
+	old, oldCounter := Db, Counter
+	defer func() { Db, Counter = old, oldCounter }()
+	Counter = nil
+
+	for kind, store := range testStores(t) {
+		Db = store
+
+		// 没有统计缓冲时直接写入
+		SaveLog(LogSearch)
+		SaveLog(LogSearch)
+		SaveLog(LogView)
+		SaveLog("unknown")
+		now := time.Now()
+
+		sclog := GetLog(LogDay(now))
+		if sclog.SearchNums != 2 || sclog.ViewNums != 1 || sclog.PutNums != 0 {
+			t.Errorf("%s: day counts %+v", kind, sclog.LogCounts)
+		}
+		hour := sclog.Hours[now.Format("15")]
+		if hour.SearchNums != 2 || hour.ViewNums != 1 || len(sclog.Hours) != 1 {
+			t.Errorf("%s: hour counts %+v", kind, sclog.Hours)
+		}
+	}
+}
+
+func TestLogCounter(t *testing.T) {
+	old, oldCounter := Db, Counter
+	defer func() { Db, Counter = old, oldCounter }()
+
+	day1 := time.Date(2026, 3, 1, 10, 15, 0, 0, time.Local)
+	day2 := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	for kind, store := range testStores(t) {
+		Db = store
+		Counter = NewLogCounter(store)
+
+		Counter.Add(day1, LogPut)
+		Counter.Add(day1.Add(30*time.Minute), LogPut)
+		Counter.Add(day1.Add(time.Hour), LogPut)
+		Counter.Add(day1.Add(time.Hour), LogSearch)
+		Counter.Add(day2, LogDht)
+
+		// 未写入的统计也计入查询结果
+		if sclog := GetLog("20260301"); sclog.PutNums != 3 || sclog.Hours["10"].PutNums != 2 {
+			t.Errorf("%s: pending log %+v", kind, sclog)
+		}
+		if logs := store.FindLogs("20260301", "20260302"); len(logs) != 0 {
+			t.Errorf("%s: logs written before flush: %+v", kind, logs)
+		}
+
+		if err := Counter.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		// 再次写入时累加到已有数据
+		Counter.Add(day1.Add(time.Hour), LogView)
+		if err := Counter.Stop(); err != nil {
+			t.Fatal(err)
+		}
+
+		logs := store.FindLogs("20260301", "20260302")
+		if len(logs) != 2 || logs[0].Day != "20260301" || logs[1].Day != "20260302" {
+			t.Fatalf("%s: logs %+v", kind, logs)
+		}
+		if c := logs[0].LogCounts; c != (LogCounts{PutNums: 3, SearchNums: 1, ViewNums: 1}) {
+			t.Errorf("%s: day1 counts %+v", kind, c)
+		}
+		if h := logs[0].Hours; len(h) != 2 || h["10"] != (LogCounts{PutNums: 2}) || h["11"] != (LogCounts{PutNums: 1, SearchNums: 1, ViewNums: 1}) {
+			t.Errorf("%s: day1 hours %+v", kind, h)
+		}
+		if c := logs[1].LogCounts; c != (LogCounts{DhtNums: 1}) || logs[1].Hours["09"] != c {
+			t.Errorf("%s: day2 %+v", kind, logs[1])
+		}
+
+		// 按天与按小时统计, 没有数据的时间点为0
+		daily := Stats(day1, day2.Add(24*time.Hour), false)
+		if len(daily) != 3 || daily[0].PutNums != 3 || daily[1].DhtNums != 1 || daily[2].LogCounts != (LogCounts{}) {
+			t.Errorf("%s: daily %+v", kind, daily)
+		}
+		hourly := Stats(day1, day1.Add(2*time.Hour), true)
+		if len(hourly) != 3 || hourly[0].PutNums != 2 || hourly[1].PutNums != 1 || hourly[2].LogCounts != (LogCounts{}) {
+			t.Errorf("%s: hourly %+v", kind, hourly)
+		}
+		if !hourly[1].Time.Equal(time.Date(2026, 3, 1, 11, 0, 0, 0, time.Local)) {
+			t.Errorf("%s: hour time %v", kind, hourly[1].Time)
+		}
+	}
+}
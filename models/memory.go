@@ -0,0 +1,536 @@
+// 内存数据存储, 用于测试及临时运行, 重启后数据丢失
+package models
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// 内存数据存储
+type MemoryStore struct {
+	mu       sync.RWMutex
+	hashes   map[string]*SC_Hash   // 以infohash为键的hash
+	infos    map[string]*SC_Info   // 以infohash为键的种子
+	infosV2  map[string]string     // v2格式infohash对应的infohash
+	logs     map[string]*SC_Log    // 以日期为键的统计
+	searches map[string]*SC_Search // 以关键字为键的搜索
+	metas    map[string]SC_Meta    // 以迁移名称为键的迁移进度
+	swarms   map[string]*SC_Swarm  // 以infohash为键的获取历史
+}
+
+// 创建内存数据存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		hashes:   make(map[string]*SC_Hash),
+		infos:    make(map[string]*SC_Info),
+		infosV2:  make(map[string]string),
+		logs:     make(map[string]*SC_Log),
+		searches: make(map[string]*SC_Search),
+		metas:    make(map[string]SC_Meta),
+		swarms:   make(map[string]*SC_Swarm),
+	}
+}
+
+/********************* SC_Hash 操作 *********************/
+
+// 保存Hash数据, 返回是否为新数据
+func (this *MemoryStore) SaveHash(schash *SC_Hash) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	// 存在则进行自增处理, 并更新最后获取时间
+	if old, ok := this.hashes[schash.InfoHash]; ok {
+		old.Hot++
+		old.Announce += schash.Announce
+		old.GetPeers += schash.GetPeers
+		old.LastSeen = time.Now()
+		return false, nil
+	}
+
+	schash.Id = bson.NewObjectId()
+	schash.Hot = 1
+	schash.Invalid = 0
+	schash.CreateTime = time.Now()
+	schash.LastSeen = schash.CreateTime
+	h := *schash
+	this.hashes[schash.InfoHash] = &h
+	return true, nil
+}
+
+// hash是否存在
+func (this *MemoryStore) HasHash(hash string) bool {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	_, ok := this.hashes[hash]
+	return ok
+}
+
+// 获取hash数据
+func (this *MemoryStore) GetHash(hash string) (SC_Hash, bool) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	schash, ok := this.hashes[hash]
+	if !ok {
+		return SC_Hash{}, false
+	}
+	return *schash, true
+}
+
+// 验证此Hash是否已经入库
+func (this *MemoryStore) IsPut(hash string) bool {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	if h, ok := this.hashes[hash]; ok {
+		return h.IsPut
+	}
+	return false
+}
+
+// 设置Hash为已入库状态
+func (this *MemoryStore) SetPut(hash string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if h, ok := this.hashes[hash]; ok {
+		h.IsPut = true
+	}
+	return nil
+}
+
+// 失败次数加一
+func (this *MemoryStore) AddInvalid(hash string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if h, ok := this.hashes[hash]; ok {
+		h.Invalid++
+	}
+	return nil
+}
+
+// 获取等待入库的hash
+func (this *MemoryStore) PendingHashes(sort string, limit int) []SC_Hash {
+	this.mu.RLock()
+	var hashes []SC_Hash
+	for _, h := range this.hashes {
+		if pendingHash(h, sort) {
+			hashes = append(hashes, *h)
+		}
+	}
+	this.mu.RUnlock()
+
+	sortHashes(hashes, sort)
+	start, end := pageRange(len(hashes), 0, limit)
+	return hashes[start:end]
+}
+
+// 按编号顺序获取满足条件的hash
+func (this *MemoryStore) ScanHashes(query HashQuery, limit int) []SC_Hash {
+	this.mu.RLock()
+	var hashes []SC_Hash
+	for _, h := range this.hashes {
+		if matchHash(query, h) {
+			hashes = append(hashes, *h)
+		}
+	}
+	this.mu.RUnlock()
+
+	sortHashes(hashes, "_id")
+	start, end := pageRange(len(hashes), 0, limit)
+	return hashes[start:end]
+}
+
+// 按条件统计hash数量
+func (this *MemoryStore) CountHashes(query HashQuery) int {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	n := 0
+	for _, h := range this.hashes {
+		if matchHash(query, h) {
+			n++
+		}
+	}
+	return n
+}
+
+// 删除hash
+func (this *MemoryStore) DeleteHashes(hashes []string) (int, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	n := 0
+	for _, hash := range hashes {
+		if _, ok := this.hashes[hash]; ok {
+			delete(this.hashes, hash)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// 不存在时插入hash
+func (this *MemoryStore) ImportHash(schash *SC_Hash) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if _, ok := this.hashes[schash.InfoHash]; ok {
+		return false, nil
+	}
+
+	schash.Id = bson.NewObjectId()
+	h := *schash
+	this.hashes[schash.InfoHash] = &h
+	return true, nil
+}
+
+/********************* SC_Info 操作 *********************/
+
+// 保存种子数据, 返回是否为新数据
+func (this *MemoryStore) SaveInfo(scinfo *SC_Info) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	// 存在则更新
+	if old, ok := this.infos[scinfo.InfoHash]; ok {
+		mergeInfo(old, scinfo)
+		return false, nil
+	}
+
+	scinfo.Id = bson.NewObjectId()
+	info := *scinfo
+	this.infos[scinfo.InfoHash] = &info
+	if scinfo.InfoHashV2 != "" {
+		this.infosV2[scinfo.InfoHashV2] = scinfo.InfoHash
+	}
+	return true, nil
+}
+
+// 不存在时插入种子
+func (this *MemoryStore) ImportInfo(scinfo *SC_Info) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if _, ok := this.infos[scinfo.InfoHash]; ok {
+		return false, nil
+	}
+
+	scinfo.Id = bson.NewObjectId()
+	info := *scinfo
+	this.infos[scinfo.InfoHash] = &info
+	if scinfo.InfoHashV2 != "" {
+		this.infosV2[scinfo.InfoHashV2] = scinfo.InfoHash
+	}
+	return true, nil
+}
+
+// 通过infohash获取种子
+func (this *MemoryStore) GetInfo(hash string) (SC_Info, bool) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	if info, ok := this.infos[hash]; ok {
+		return *info, true
+	}
+	return SC_Info{}, false
+}
+
+// 通过v2格式infohash获取种子
+func (this *MemoryStore) GetInfoV2(hash string) (SC_Info, bool) {
+	this.mu.RLock()
+	v1, ok := this.infosV2[hash]
+	this.mu.RUnlock()
+
+	if !ok {
+		return SC_Info{}, false
+	}
+	return this.GetInfo(v1)
+}
+
+// 种子是否存在
+func (this *MemoryStore) HasInfo(hash string) bool {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	_, ok := this.infos[hash]
+	return ok
+}
+
+// 获取已入库的infohash
+func (this *MemoryStore) InfoHashes(hashes []string) map[string]bool {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	has := make(map[string]bool)
+	for _, hash := range hashes {
+		if _, ok := this.infos[hash]; ok {
+			has[hash] = true
+		}
+	}
+	return has
+}
+
+// 修改热度信息
+func (this *MemoryStore) AddHot(hash string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if info, ok := this.infos[hash]; ok {
+		info.Hot++
+	}
+	return nil
+}
+
+// 查看次数加一
+func (this *MemoryStore) AddViews(hash string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if info, ok := this.infos[hash]; ok {
+		info.Views++
+	}
+	return nil
+}
+
+// 设置同一内容指纹中显示的种子, 其它种子标记为重复
+func (this *MemoryStore) SetGroupBest(fingerprint, hash string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	for _, info := range this.infos {
+		if info.Fingerprint == fingerprint {
+			info.Duplicate = info.InfoHash != hash
+		}
+	}
+	return nil
+}
+
+// 按条件查询种子
+func (this *MemoryStore) FindInfos(query InfoQuery, start, length int, sort string) []SC_Info {
+	infos := this.filterInfos(query)
+	sortInfos(infos, sort)
+	start, end := pageRange(len(infos), start, length)
+	return infos[start:end]
+}
+
+// 按条件统计种子数量
+func (this *MemoryStore) CountInfos(query InfoQuery) int {
+	return len(this.filterInfos(query))
+}
+
+// 获取满足条件的种子
+func (this *MemoryStore) filterInfos(query InfoQuery) []SC_Info {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	match := keyMatcher(query.Key)
+	var infos []SC_Info
+	for _, info := range this.infos {
+		if matchInfo(query, match, info) {
+			infos = append(infos, *info)
+		}
+	}
+	return infos
+}
+
+/********************* SC_Log 操作 *********************/
+
+// 累加统计数据, 返回是否为当天的第一条统计
+func (this *MemoryStore) AddLog(day string, counts map[string]int64) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	sclog, ok := this.logs[day]
+	if !ok {
+		sclog = &SC_Log{Id: bson.NewObjectId(), Day: day}
+		this.logs[day] = sclog
+	}
+	sclog.Add(counts)
+	return !ok, nil
+}
+
+// 获取指定日期的统计
+func (this *MemoryStore) GetLog(day string) SC_Log {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	if sclog, ok := this.logs[day]; ok {
+		return copyLog(sclog)
+	}
+	return SC_Log{}
+}
+
+// 获取日期范围内的统计, 包含起止日期
+func (this *MemoryStore) FindLogs(from, to string) []SC_Log {
+	this.mu.RLock()
+	var logs []SC_Log
+	for day, sclog := range this.logs {
+		if day >= from && day <= to {
+			logs = append(logs, copyLog(sclog))
+		}
+	}
+	this.mu.RUnlock()
+
+	sortLogs(logs)
+	return logs
+}
+
+// 不存在当天的统计时插入
+func (this *MemoryStore) ImportLog(sclog *SC_Log) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if _, ok := this.logs[sclog.Day]; ok {
+		return false, nil
+	}
+
+	sclog.Id = bson.NewObjectId()
+	c := copyLog(sclog)
+	this.logs[sclog.Day] = &c
+	return true, nil
+}
+
+/********************* SC_Search 操作 *********************/
+
+// 保存搜索数据, 返回是否为新的关键字
+func (this *MemoryStore) SaveSearch(scsearch *SC_Search) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	// 存在则自增并更新查询时间
+	if old, ok := this.searches[scsearch.Caption]; ok {
+		old.Views++
+		old.SearchTime = time.Now()
+		return false, nil
+	}
+
+	scsearch.Id = bson.NewObjectId()
+	scsearch.Views = 1
+	scsearch.SearchTime = time.Now()
+	s := *scsearch
+	this.searches[scsearch.Caption] = &s
+	return true, nil
+}
+
+// 获取搜索列表
+func (this *MemoryStore) FindSearches(key string, length int, sort string) []SC_Search {
+	this.mu.RLock()
+	match := keyMatcher(key)
+	var searches []SC_Search
+	for _, s := range this.searches {
+		if match(s.Caption) {
+			searches = append(searches, *s)
+		}
+	}
+	this.mu.RUnlock()
+
+	sortSearches(searches, sort)
+	start, end := pageRange(len(searches), 0, length)
+	return searches[start:end]
+}
+
+// 按编号顺序获取满足条件的搜索
+func (this *MemoryStore) ScanSearches(query SearchQuery, limit int) []SC_Search {
+	this.mu.RLock()
+	var searches []SC_Search
+	for _, s := range this.searches {
+		if matchSearch(query, s) {
+			searches = append(searches, *s)
+		}
+	}
+	this.mu.RUnlock()
+
+	sortSearches(searches, "_id")
+	start, end := pageRange(len(searches), 0, limit)
+	return searches[start:end]
+}
+
+// 不存在时插入搜索
+func (this *MemoryStore) ImportSearch(scsearch *SC_Search) (bool, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if _, ok := this.searches[scsearch.Caption]; ok {
+		return false, nil
+	}
+
+	scsearch.Id = bson.NewObjectId()
+	s := *scsearch
+	this.searches[scsearch.Caption] = &s
+	return true, nil
+}
+
+/********************* SC_Swarm 操作 *********************/
+
+// 合并获取记录, 删除超出保留天数的日期并更新近期热度
+func (this *MemoryStore) AddSwarm(hash string, first, last time.Time, days map[string]int64) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	swarm, ok := this.swarms[hash]
+	if !ok {
+		swarm = &SC_Swarm{InfoHash: hash}
+		this.swarms[hash] = swarm
+	}
+	swarm.merge(first, last, days)
+	swarm.trim(time.Now())
+	return nil
+}
+
+// 获取种子的获取历史
+func (this *MemoryStore) GetSwarm(hash string) (SC_Swarm, bool) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	swarm, ok := this.swarms[hash]
+	if !ok {
+		return SC_Swarm{}, false
+	}
+	return copySwarm(swarm), true
+}
+
+// 获取近期热度最高的种子历史
+func (this *MemoryStore) TrendingSwarms(since time.Time, limit int) []SC_Swarm {
+	this.mu.RLock()
+	var swarms []SC_Swarm
+	for _, swarm := range this.swarms {
+		if !swarm.LastSeen.Before(since) {
+			swarms = append(swarms, copySwarm(swarm))
+		}
+	}
+	this.mu.RUnlock()
+
+	sortSwarms(swarms)
+	start, end := pageRange(len(swarms), 0, limit)
+	return swarms[start:end]
+}
+
+/********************* SC_Meta 操作 *********************/
+
+// 获取数据迁移的执行进度
+func (this *MemoryStore) GetMeta(id string) (SC_Meta, bool) {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+
+	meta, ok := this.metas[id]
+	return meta, ok
+}
+
+// 保存数据迁移的执行进度
+func (this *MemoryStore) SaveMeta(meta *SC_Meta) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.metas[meta.Id] = *meta
+	return nil
+}
+
+// 关闭存储
+func (this *MemoryStore) Close() error {
+	return nil
+}
@@ -2,76 +2,189 @@
 package models
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"time"
 
-	"github.com/astaxie/beego"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 // 数据库结构
 type DB struct {
-	Host    string // MongoDB连接地址
-	Port    int    // MongoDB连接端口
-	Name    string // MongoDB数据库名
-	User    string // MongoDB连接用户名
-	Pass    string // MongoDB连接密码
-	ShowMsg bool   // 是否显示信息
+	URL           string        // MongoDB连接字符串, 设置后忽略地址, 端口, 用户名, 密码, 认证库与副本集配置
+	Host          string        // MongoDB连接地址, 多个地址以逗号分隔
+	Port          int           // MongoDB连接端口
+	Name          string        // MongoDB数据库名
+	User          string        // MongoDB连接用户名
+	Pass          string        // MongoDB连接密码
+	AuthDB        string        // 认证使用的数据库, 为空时使用Name
+	ReplicaSet    string        // 副本集名称
+	TLS           bool          // 是否使用TLS连接
+	TLSCA         string        // 校验服务器证书的CA文件, 为空时使用系统证书
+	ReadPref      string        // 网站读取使用的节点, 见readModes
+	PoolLimit     int           // 每个服务器的最大连接数, 0为驱动默认值
+	Timeout       time.Duration // 连接超时时间
+	SocketTimeout time.Duration // 单次操作超时时间, 0为驱动默认值
+	ShowMsg       bool          // 是否显示信息
 }
 
-var (
-	Session  *mgo.Session    // 数据库连接对象
+// 数据库配置信息
+var DbConfig *DB
+
+// 读取节点配置对应的模式
+var readModes = map[string]mgo.Mode{
+	"primary":            mgo.Primary,            // 只从主节点读取
+	"primaryPreferred":   mgo.PrimaryPreferred,   // 优先从主节点读取
+	"secondary":          mgo.Secondary,          // 只从从节点读取
+	"secondaryPreferred": mgo.SecondaryPreferred, // 优先从从节点读取, 没有从节点时从主节点读取
+	"nearest":            mgo.Nearest,            // 从延迟最低的节点读取
+}
+
+// MongoDB数据存储
+type MgoStore struct {
+	Session  *mgo.Session    // 数据库连接对象, 每次操作复制一个连接
 	DbHash   *mgo.Collection // Hash表对象
 	DbInfo   *mgo.Collection // 种子信息表对象
 	DbLog    *mgo.Collection // 每日统计信息表
 	DbSearch *mgo.Collection // 搜索统计表
-	DbConfig *DB             // 数据库配置信息
-)
+	DbMeta   *mgo.Collection // 数据迁移进度表
+	DbSwarm  *mgo.Collection // 种子获取历史表
 
-// 初始化数据库
-func Init() {
-	// 获取数据库连接端口
-	dbport, _ := beego.AppConfig.Int("dbport")
-	// 获取是否允许显示信息
-	showmsg, _ := beego.AppConfig.Bool("showmsg")
+	reader *MgoStore // 按读取节点配置读取的存储
+}
 
-	// 初始化数据库配置信息
-	DbConfig = &DB{
-		Host:    beego.AppConfig.String("dbhost"), // 配置数据库地址
-		Port:    dbport,                           // 配置数据库端口
-		Name:    beego.AppConfig.String("dbname"), // 配置数据库名称
-		User:    beego.AppConfig.String("dbuser"), // 配置数据库用户名
-		Pass:    beego.AppConfig.String("dbpass"), // 配置数据库密码
-		ShowMsg: showmsg,                          // 是否允许显示信息
+// 根据配置生成连接信息与网站读取使用的模式
+func dialInfo(config *DB) (*mgo.DialInfo, mgo.Mode, error) {
+	rawurl := config.URL
+	if rawurl == "" {
+		// 由各项配置生成连接字符串
+		u := url.URL{Scheme: "mongodb", Path: "/" + config.Name}
+		if config.User != "" && config.Pass != "" {
+			u.User = url.UserPassword(config.User, config.Pass)
+		}
+		var hosts []string
+		for _, host := range strings.Split(config.Host, ",") {
+			if host = strings.TrimSpace(host); host != "" && !strings.Contains(host, ":") {
+				host = fmt.Sprintf("%s:%d", host, config.Port)
+			}
+			hosts = append(hosts, host)
+		}
+		u.Host = strings.Join(hosts, ",")
+		query := url.Values{}
+		if config.AuthDB != "" {
+			query.Set("authSource", config.AuthDB)
+		}
+		if config.ReplicaSet != "" {
+			query.Set("replicaSet", config.ReplicaSet)
+		}
+		u.RawQuery = query.Encode()
+		rawurl = u.String()
 	}
 
-	// 连接用户名及密码
-	userAndPass := DbConfig.User + ":" + DbConfig.Pass + "@"
+	// 驱动不支持的参数由此处理
+	useTLS, caFile, readPref := config.TLS, config.TLSCA, config.ReadPref
+	if i := strings.Index(rawurl, "?"); i >= 0 {
+		query, err := url.ParseQuery(rawurl[i+1:])
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, key := range []string{"ssl", "tls"} {
+			if v := query.Get(key); v != "" {
+				useTLS = v == "true"
+				query.Del(key)
+			}
+		}
+		if v := query.Get("tlsCAFile"); v != "" {
+			caFile = v
+			query.Del("tlsCAFile")
+		}
+		if v := query.Get("readPreference"); v != "" {
+			readPref = v
+			query.Del("readPreference")
+		}
+		rawurl = rawurl[:i]
+		if len(query) > 0 {
+			rawurl += "?" + query.Encode()
+		}
+	}
 
-	// 如果没有设置则表示不需要用户名和密码
-	if DbConfig.User == "" || DbConfig.Pass == "" {
-		userAndPass = ""
+	info, err := mgo.ParseURL(rawurl)
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.Database == "" {
+		info.Database = config.Name
+	}
+	info.Timeout = config.Timeout
+	if config.PoolLimit > 0 {
+		info.PoolLimit = config.PoolLimit
 	}
 
-	// 设置连接字符串
-	url := "mongodb://" + userAndPass + DbConfig.Host + ":" + fmt.Sprintf("%d", DbConfig.Port) + "/" + DbConfig.Name
+	// 默认优先从从节点读取
+	mode := mgo.SecondaryPreferred
+	if readPref != "" {
+		m, ok := readModes[readPref]
+		if !ok {
+			return nil, 0, fmt.Errorf("models: unknown read preference %q", readPref)
+		}
+		mode = m
+	}
+
+	if useTLS {
+		tlsConfig := &tls.Config{}
+		if caFile != "" {
+			pem, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return nil, 0, err
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, 0, fmt.Errorf("models: no certificate in %s", caFile)
+			}
+		}
+		info.DialServer = func(addr *mgo.ServerAddr) (net.Conn, error) {
+			dialer := &net.Dialer{Timeout: info.Timeout}
+			return tls.DialWithDialer(dialer, "tcp", addr.String(), tlsConfig)
+		}
+	}
+
+	return info, mode, nil
+}
+
+// 连接MongoDB并创建索引
+func NewMgoStore(config *DB) (*MgoStore, error) {
+	info, mode, err := dialInfo(config)
+	if err != nil {
+		return nil, err
+	}
 
-	// 定义一个错误变量
-	var err error
 	// 定义一个索引变量
 	var index mgo.Index
 	// 连接数据库
-	Session, err = mgo.Dial(url)
+	session, err := mgo.DialWithInfo(info)
 	if err != nil {
-		// 失败则报错
-		panic(err)
+		// 失败则返回错误, 由调用方决定如何处理
+		return nil, err
+	}
+
+	// 写入及爬虫的读取都使用主节点
+	session.SetMode(mgo.Primary, true)
+	if config.SocketTimeout > 0 {
+		session.SetSocketTimeout(config.SocketTimeout)
 	}
+	this := newMgoStore(session, info.Database)
 
-	// 配置为monotonic驱动
-	Session.SetMode(mgo.Monotonic, true)
+	// 网站读取使用的连接
+	read := session.Copy()
+	read.SetMode(mode, true)
+	this.reader = newMgoStore(read, info.Database)
 
-	// 连接Hash表
-	DbHash = Session.DB(DbConfig.Name).C("SC_Hash")
 	// 设置Hash表索引
 	index = mgo.Index{
 		Key:        []string{"infohash"}, // 索引键
@@ -80,10 +193,17 @@ func Init() {
 		Background: true,                 // 不长时间占用写锁
 	}
 	// 创建索引
-	DbHash.EnsureIndex(index)
+	this.DbHash.EnsureIndex(index)
+	// 设置Hash表调度索引
+	for _, key := range []string{"lastseen", "createtime"} {
+		index = mgo.Index{
+			Key:        []string{"isput", key}, // 索引键
+			Background: true,                   // 不长时间占用写锁
+		}
+		// 创建索引
+		this.DbHash.EnsureIndex(index)
+	}
 
-	// 连接种子信息表
-	DbInfo = Session.DB(DbConfig.Name).C("SC_Info")
 	// 设置种子表唯一索引
 	index = mgo.Index{
 		Key:        []string{"infohash"}, // 索引键
@@ -92,17 +212,49 @@ func Init() {
 		Background: true,                 // 不长时间占用写锁
 	}
 	// 创建索引
-	DbInfo.EnsureIndex(index)
+	this.DbInfo.EnsureIndex(index)
+	// 设置种子表v2 infohash索引
+	index = mgo.Index{
+		Key:        []string{"infohashv2"}, // 索引键
+		Unique:     true,                   // 唯一索引
+		Sparse:     true,                   // 只索引存在此字段的数据
+		Background: true,                   // 不长时间占用写锁
+	}
+	// 创建索引
+	this.DbInfo.EnsureIndex(index)
 	// 设置种子表标题索引
 	index = mgo.Index{
 		Key:        []string{"caption"}, // 索引键
 		Background: true,                // 不长时间占用写锁
 	}
 	// 创建索引
-	DbInfo.EnsureIndex(index)
+	this.DbInfo.EnsureIndex(index)
+	// 设置种子表分类索引
+	index = mgo.Index{
+		Key:        []string{"category", "-puttime"}, // 索引键
+		Background: true,                             // 不长时间占用写锁
+	}
+	// 创建索引
+	this.DbInfo.EnsureIndex(index)
+	// 设置种子表内容指纹索引
+	index = mgo.Index{
+		Key:        []string{"fingerprint", "-hot"}, // 索引键, 同组按热度排序
+		Sparse:     true,                            // 只索引存在此字段的数据
+		Background: true,                            // 不长时间占用写锁
+	}
+	// 创建索引
+	this.DbInfo.EnsureIndex(index)
+	// 设置种子表发布属性索引
+	for _, key := range []string{"release.resolution", "release.source", "release.year"} {
+		index = mgo.Index{
+			Key:        []string{key}, // 索引键
+			Sparse:     true,          // 只索引存在此字段的数据
+			Background: true,          // 不长时间占用写锁
+		}
+		// 创建索引
+		this.DbInfo.EnsureIndex(index)
+	}
 
-	// 链接每日统计信息表
-	DbLog = Session.DB(DbConfig.Name).C("SC_Log")
 	// 设置统计表唯一索引
 	index = mgo.Index{
 		Key:        []string{"day"}, //索引键
@@ -111,10 +263,8 @@ func Init() {
 		Background: true,            // 不长时间占用写锁
 	}
 	// 创建索引
-	DbLog.EnsureIndex(index)
+	this.DbLog.EnsureIndex(index)
 
-	// 链接搜索统计信息表
-	DbSearch = Session.DB(DbConfig.Name).C("SC_Search")
 	// 设置统计表唯一索引
 	index = mgo.Index{
 		Key:        []string{"caption"}, // 索引键
@@ -123,7 +273,648 @@ func Init() {
 		Background: true,                // 不长时间占用写锁
 	}
 	// 创建索引
-	DbSearch.EnsureIndex(index)
+	this.DbSearch.EnsureIndex(index)
+
+	// 设置获取历史表近期热度索引
+	index = mgo.Index{
+		Key:        []string{"-trend", "last_seen"}, // 索引键
+		Background: true,                            // 不长时间占用写锁
+	}
+	// 创建索引
+	this.DbSwarm.EnsureIndex(index)
+
+	return this, nil
+}
+
+// 使用指定连接创建数据存储, 数据迁移进度表以迁移名称为编号, 获取历史表以infohash为编号, 不需要另建唯一索引
+func newMgoStore(session *mgo.Session, name string) *MgoStore {
+	db := session.DB(name)
+	return &MgoStore{
+		Session:  session,
+		DbHash:   db.C("SC_Hash"),
+		DbInfo:   db.C("SC_Info"),
+		DbLog:    db.C("SC_Log"),
+		DbSearch: db.C("SC_Search"),
+		DbMeta:   db.C("SC_Meta"),
+		DbSwarm:  db.C("SC_Swarm"),
+	}
+}
+
+// 按读取节点配置读取的存储, 供网站使用
+func (this *MgoStore) Reader() *MgoStore {
+	if this.reader == nil {
+		return this
+	}
+	return this.reader
+}
+
+// 复制连接用于单次操作, 并发的操作使用连接池中不同的连接
+func (this *MgoStore) with(c *mgo.Collection) (*mgo.Collection, func()) {
+	session := this.Session.Copy()
+	return c.With(session), session.Close
+}
+
+/********************* SC_Hash 操作 *********************/
+
+// 保存Hash数据, 返回是否为新数据
+func (this *MgoStore) SaveHash(schash *SC_Hash) (bool, error) {
+	c, done := this.with(this.DbHash)
+	defer done()
+
+	now := time.Now()
+	id := bson.NewObjectId()
+	// 存在则自增热度与来源次数并更新最后获取时间, 不存在则插入热度为1的新数据
+	isNew, err := Upsert(c, bson.M{"infohash": schash.InfoHash}, bson.M{
+		"$inc":         bson.M{"hot": 1, "announce": schash.Announce, "getpeers": schash.GetPeers},
+		"$set":         bson.M{"lastseen": now},
+		"$setOnInsert": bson.M{"_id": id, "isput": schash.IsPut, "invalid": 0, "createtime": now},
+	})
+	if isNew {
+		schash.Id = id
+		schash.Hot = 1
+		schash.CreateTime = now
+		schash.LastSeen = now
+	}
+
+	return isNew, err
+}
+
+// hash是否存在
+func (this *MgoStore) HasHash(hash string) bool {
+	c, done := this.with(this.DbHash)
+	defer done()
+
+	return Has(c, bson.M{"infohash": hash})
+}
+
+// 获取hash数据
+func (this *MgoStore) GetHash(hash string) (SC_Hash, bool) {
+	c, done := this.with(this.DbHash)
+	defer done()
+
+	var schash SC_Hash
+	GetOneByQuery(c, bson.M{"infohash": hash}, &schash)
+	return schash, schash.InfoHash != ""
+}
+
+// 验证此Hash是否已经入库
+func (this *MgoStore) IsPut(hash string) bool {
+	c, done := this.with(this.DbHash)
+	defer done()
+
+	// 定义一个SC_Hash
+	var schash SC_Hash
+	// 获取Hash信息
+	GetOneByQuery(c, bson.M{"infohash": hash}, &schash)
+
+	if schash.InfoHash == "" {
+		return false
+	}
+
+	// 返回是否入库
+	return schash.IsPut
+}
+
+// 设置Hash为已入库状态
+func (this *MgoStore) SetPut(hash string) error {
+	c, done := this.with(this.DbHash)
+	defer done()
+
+	return Update(c, bson.M{"infohash": hash}, bson.M{"$set": bson.M{"isput": true}})
+}
+
+// 失败次数加一
+func (this *MgoStore) AddInvalid(hash string) error {
+	c, done := this.with(this.DbHash)
+	defer done()
+
+	return SetAdd(c, bson.M{"infohash": hash}, "invalid", true)
+}
+
+// 获取等待入库的hash
+func (this *MgoStore) PendingHashes(sort string, limit int) []SC_Hash {
+	c, done := this.with(this.DbHash)
+	defer done()
+
+	// 未入库且失败次数不超过3次
+	query := bson.M{"isput": false, "invalid": bson.M{"$lte": 3}}
+	if sort == "createtime" || sort == "-createtime" {
+		query["createtime"] = bson.M{"$gt": time.Time{}}
+	}
+
+	var hashes []SC_Hash
+	GetDataByQuery(c, 0, limit, sort, query, &hashes)
+	return hashes
+}
+
+// 按编号顺序获取满足条件的hash
+func (this *MgoStore) ScanHashes(query HashQuery, limit int) []SC_Hash {
+	c, done := this.with(this.DbHash)
+	defer done()
+
+	var hashes []SC_Hash
+	GetDataByQuery(c, 0, limit, "_id", hashQuery(query), &hashes)
+	return hashes
+}
+
+// 按条件统计hash数量
+func (this *MgoStore) CountHashes(query HashQuery) int {
+	c, done := this.with(this.DbHash)
+	defer done()
+
+	return Count(c, hashQuery(query))
+}
+
+// 将hash查询条件转换为MongoDB查询
+func hashQuery(q HashQuery) bson.M {
+	query := bson.M{}
+
+	hot := bson.M{}
+	if q.MinHot > 0 {
+		hot["$gte"] = q.MinHot
+	}
+	if q.MaxHot > 0 {
+		hot["$lte"] = q.MaxHot
+	}
+	if len(hot) > 0 {
+		query["hot"] = hot
+	}
+
+	// 与等待入库的条件一致, 失败超过3次的不再下载
+	if q.Pending {
+		query["isput"] = false
+		query["invalid"] = bson.M{"$lte": 3}
+	} else if q.Failed {
+		query["isput"] = false
+		query["invalid"] = bson.M{"$gt": 3}
+	}
+
+	if r := timeRange(q.Since, q.Until); r != nil {
+		query["lastseen"] = r
+	}
+	if q.After != "" {
+		query["_id"] = bson.M{"$gt": q.After}
+	}
+
+	return query
+}
+
+// 不存在时插入hash
+func (this *MgoStore) ImportHash(schash *SC_Hash) (bool, error) {
+	c, done := this.with(this.DbHash)
+	defer done()
+
+	h := *schash
+	h.Id = bson.NewObjectId()
+	isNew, err := Upsert(c, bson.M{"infohash": h.InfoHash}, bson.M{"$setOnInsert": &h})
+	if isNew {
+		schash.Id = h.Id
+	}
+
+	return isNew, err
+}
+
+// 删除hash
+func (this *MgoStore) DeleteHashes(hashes []string) (int, error) {
+	c, done := this.with(this.DbHash)
+	defer done()
+
+	info, err := c.RemoveAll(bson.M{"infohash": bson.M{"$in": hashes}})
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Removed, nil
+}
+
+// 生成时间范围查询, 零值表示不限, 都为零值时返回nil
+func timeRange(since, until time.Time) bson.M {
+	r := bson.M{}
+	if !since.IsZero() {
+		r["$gte"] = since
+	}
+	if !until.IsZero() {
+		r["$lt"] = until
+	}
+	if len(r) == 0 {
+		return nil
+	}
+
+	return r
+}
+
+/********************* SC_Info 操作 *********************/
+
+// 保存种子数据, 返回是否为新数据
+func (this *MgoStore) SaveInfo(scinfo *SC_Info) (bool, error) {
+	c, done := this.with(this.DbInfo)
+	defer done()
+
+	id := bson.NewObjectId()
+	// 只在插入时设置的字段
+	// 热度由获取记录累加, 重新入库时不覆盖
+	insert := bson.M{"_id": id, "keys": scinfo.Keys, "views": scinfo.Views, "hot": scinfo.Hot, "puttime": scinfo.PutTime}
+	if scinfo.InfoHashV2 != "" {
+		insert["infohashv2"] = scinfo.InfoHashV2
+	}
+
+	// 存在则更新, 不存在则插入
+	isNew, err := Upsert(c, bson.M{"infohash": scinfo.InfoHash}, bson.M{
+		"$set":         bson.M{"caption": scinfo.Caption, "length": scinfo.Length, "filecount": scinfo.FileCount, "files": scinfo.Files, "filelist": scinfo.FileList, "category": scinfo.Category, "release": scinfo.Release, "fingerprint": scinfo.Fingerprint, "createtime": scinfo.CreateTime, "trackers": scinfo.Trackers, "webseeds": scinfo.WebSeeds, "httpseeds": scinfo.HttpSeeds, "piecelength": scinfo.PieceLength, "piececount": scinfo.PieceCount, "private": scinfo.Private, "source": scinfo.Source, "comment": scinfo.Comment, "createdby": scinfo.CreatedBy, "metaversion": scinfo.MetaVersion, "charset": scinfo.Charset, "rawcaption": scinfo.RawCaption},
+		"$setOnInsert": insert,
+	})
+	if isNew {
+		scinfo.Id = id
+	}
+
+	return isNew, err
+}
+
+// 不存在时插入种子
+func (this *MgoStore) ImportInfo(scinfo *SC_Info) (bool, error) {
+	c, done := this.with(this.DbInfo)
+	defer done()
+
+	s := *scinfo
+	s.Id = bson.NewObjectId()
+	isNew, err := Upsert(c, bson.M{"infohash": s.InfoHash}, bson.M{"$setOnInsert": &s})
+	if isNew {
+		scinfo.Id = s.Id
+	}
+
+	return isNew, err
+}
+
+// 通过infohash获取种子
+func (this *MgoStore) GetInfo(hash string) (SC_Info, bool) {
+	c, done := this.with(this.DbInfo)
+	defer done()
+
+	var scinfo SC_Info
+	GetOneByQuery(c, bson.M{"infohash": hash}, &scinfo)
+	return scinfo, scinfo.InfoHash != ""
+}
+
+// 通过v2格式infohash获取种子
+func (this *MgoStore) GetInfoV2(hash string) (SC_Info, bool) {
+	c, done := this.with(this.DbInfo)
+	defer done()
+
+	var scinfo SC_Info
+	GetOneByQuery(c, bson.M{"infohashv2": hash}, &scinfo)
+	return scinfo, scinfo.InfoHash != ""
+}
+
+// 种子是否存在
+func (this *MgoStore) HasInfo(hash string) bool {
+	c, done := this.with(this.DbInfo)
+	defer done()
+
+	return Has(c, bson.M{"infohash": hash})
+}
+
+// 获取已入库的infohash
+func (this *MgoStore) InfoHashes(hashes []string) map[string]bool {
+	c, done := this.with(this.DbInfo)
+	defer done()
+
+	// 定义一个结果列表
+	var result []struct {
+		InfoHash string `bson:"infohash"`
+	}
+	// 只获取infohash字段
+	c.Find(bson.M{"infohash": bson.M{"$in": hashes}}).Select(bson.M{"infohash": 1}).All(&result)
+
+	has := make(map[string]bool)
+	for _, r := range result {
+		has[r.InfoHash] = true
+	}
+
+	return has
+}
+
+// 修改热度信息
+func (this *MgoStore) AddHot(hash string) error {
+	c, done := this.with(this.DbInfo)
+	defer done()
+
+	// 只修改已入库的种子, 不存在时忽略
+	err := c.Update(bson.M{"infohash": hash}, bson.M{"$inc": bson.M{"hot": 1}})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// 查看次数加一
+func (this *MgoStore) AddViews(hash string) error {
+	c, done := this.with(this.DbInfo)
+	defer done()
+
+	return SetAdd(c, bson.M{"infohash": hash}, "views", true)
+}
+
+// 设置同一内容指纹中显示的种子, 只更新标记需要改变的种子
+func (this *MgoStore) SetGroupBest(fingerprint, hash string) error {
+	c, done := this.with(this.DbInfo)
+	defer done()
+
+	// 先显示新的种子再隐藏其它种子, 更新期间同组不会没有显示的种子
+	_, err := c.UpdateAll(bson.M{"fingerprint": fingerprint, "infohash": hash, "duplicate": true}, bson.M{"$set": bson.M{"duplicate": false}})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.UpdateAll(bson.M{"fingerprint": fingerprint, "infohash": bson.M{"$ne": hash}, "duplicate": bson.M{"$ne": true}}, bson.M{"$set": bson.M{"duplicate": true}})
+	return err
+}
+
+// 按条件查询种子
+func (this *MgoStore) FindInfos(query InfoQuery, start, length int, sort string) []SC_Info {
+	c, done := this.with(this.DbInfo)
+	defer done()
+
+	var infos []SC_Info
+	GetDataByQuery(c, start, length, sort, infoQuery(query), &infos)
+	return infos
+}
+
+// 按条件统计种子数量
+func (this *MgoStore) CountInfos(query InfoQuery) int {
+	c, done := this.with(this.DbInfo)
+	defer done()
+
+	return Count(c, infoQuery(query))
+}
+
+// 将查询条件转换为MongoDB查询
+func infoQuery(q InfoQuery) bson.M {
+	query := bson.M{}
+	if q.Key != "" {
+		query["$or"] = []bson.M{bson.M{"caption": bson.M{"$regex": bson.RegEx{Pattern: q.Key, Options: "i"}}}, bson.M{"keys": bson.M{"$regex": bson.RegEx{Pattern: q.Key, Options: "i"}}}}
+	}
+	if q.Category != "" {
+		query["category"] = q.Category
+	}
+
+	// 发布属性
+	for field, value := range map[string]string{"resolution": q.Release.Resolution, "source": q.Release.Source, "videocodec": q.Release.VideoCodec, "audio": q.Release.Audio} {
+		if value != "" {
+			query["release."+field] = value
+		}
+	}
+	if q.Release.Year > 0 {
+		query["release.year"] = q.Release.Year
+	}
+	if q.Release.Season > 0 {
+		query["release.season"] = q.Release.Season
+	}
+
+	switch q.Missing {
+	case MissingParts:
+		// 还有文件缺少路径数组的种子即为未转换的数据
+		query["files"] = bson.M{"$elemMatch": bson.M{"parts": bson.M{"$exists": false}}}
+	case MissingCategory, MissingRelease, MissingFingerprint:
+		query[q.Missing] = bson.M{"$exists": false}
+	}
+
+	if q.Fingerprint != "" {
+		query["fingerprint"] = q.Fingerprint
+	}
+	if q.Collapse {
+		query["duplicate"] = bson.M{"$ne": true}
+	}
+
+	if q.MinHot > 0 {
+		query["hot"] = bson.M{"$gte": q.MinHot}
+	}
+	if r := timeRange(q.Since, q.Until); r != nil {
+		query["puttime"] = r
+	}
+
+	if q.After != "" {
+		query["_id"] = bson.M{"$gt": q.After}
+	}
+
+	return query
+}
+
+/********************* SC_Log 操作 *********************/
+
+// 累加统计数据, 键为字段名或hours.小时.字段名, 返回是否为当天的第一条统计
+func (this *MgoStore) AddLog(day string, counts map[string]int64) (bool, error) {
+	c, done := this.with(this.DbLog)
+	defer done()
+
+	inc := bson.M{}
+	for field, n := range counts {
+		inc[field] = n
+	}
+
+	return Upsert(c, bson.M{"day": day}, bson.M{
+		"$inc":         inc,
+		"$setOnInsert": bson.M{"_id": bson.NewObjectId()},
+	})
+}
+
+// 获取指定日期的统计
+func (this *MgoStore) GetLog(day string) SC_Log {
+	c, done := this.with(this.DbLog)
+	defer done()
+
+	var sclog SC_Log
+	GetOneByQuery(c, bson.M{"day": day}, &sclog)
+	return sclog
+}
+
+// 获取日期范围内的统计, 包含起止日期
+func (this *MgoStore) FindLogs(from, to string) []SC_Log {
+	c, done := this.with(this.DbLog)
+	defer done()
+
+	var logs []SC_Log
+	c.Find(bson.M{"day": bson.M{"$gte": from, "$lte": to}}).Sort("day").All(&logs)
+	return logs
+}
+
+// 不存在当天的统计时插入
+func (this *MgoStore) ImportLog(sclog *SC_Log) (bool, error) {
+	c, done := this.with(this.DbLog)
+	defer done()
+
+	l := *sclog
+	l.Id = bson.NewObjectId()
+	isNew, err := Upsert(c, bson.M{"day": l.Day}, bson.M{"$setOnInsert": &l})
+	if isNew {
+		sclog.Id = l.Id
+	}
+
+	return isNew, err
+}
+
+/********************* SC_Search 操作 *********************/
+
+// 保存搜索数据, 返回是否为新的关键字
+func (this *MgoStore) SaveSearch(scsearch *SC_Search) (bool, error) {
+	c, done := this.with(this.DbSearch)
+	defer done()
+
+	now := bson.Now()
+	id := bson.NewObjectId()
+	// 存在则自增搜索次数并更新搜索时间, 不存在则插入搜索次数为1的新数据
+	isNew, err := Upsert(c, bson.M{"caption": scsearch.Caption}, bson.M{
+		"$inc":         bson.M{"views": 1},
+		"$set":         bson.M{"searchtime": now},
+		"$setOnInsert": bson.M{"_id": id, "count": scsearch.Count},
+	})
+	if isNew {
+		scsearch.Id = id
+		scsearch.Views = 1
+		scsearch.SearchTime = now
+	}
+
+	return isNew, err
+}
+
+// 获取搜索列表
+func (this *MgoStore) FindSearches(key string, length int, sort string) []SC_Search {
+	c, done := this.with(this.DbSearch)
+	defer done()
+
+	var query interface{}
+	if key != "" {
+		query = bson.M{"caption": bson.M{"$regex": bson.RegEx{Pattern: key, Options: "i"}}}
+	}
+
+	var searches []SC_Search
+	GetDataByQuery(c, 0, length, sort, query, &searches)
+	return searches
+}
+
+// 按编号顺序获取满足条件的搜索
+func (this *MgoStore) ScanSearches(query SearchQuery, limit int) []SC_Search {
+	c, done := this.with(this.DbSearch)
+	defer done()
+
+	q := bson.M{}
+	if r := timeRange(query.Since, query.Until); r != nil {
+		q["searchtime"] = r
+	}
+	if query.After != "" {
+		q["_id"] = bson.M{"$gt": query.After}
+	}
+
+	var searches []SC_Search
+	GetDataByQuery(c, 0, limit, "_id", q, &searches)
+	return searches
+}
+
+// 不存在时插入搜索
+func (this *MgoStore) ImportSearch(scsearch *SC_Search) (bool, error) {
+	c, done := this.with(this.DbSearch)
+	defer done()
+
+	s := *scsearch
+	s.Id = bson.NewObjectId()
+	isNew, err := Upsert(c, bson.M{"caption": s.Caption}, bson.M{"$setOnInsert": &s})
+	if isNew {
+		scsearch.Id = s.Id
+	}
+
+	return isNew, err
+}
+
+/********************* SC_Swarm 操作 *********************/
+
+// 合并获取记录, 累加后再删除超出保留天数的日期并更新近期热度
+func (this *MgoStore) AddSwarm(hash string, first, last time.Time, days map[string]int64) error {
+	c, done := this.with(this.DbSwarm)
+	defer done()
+
+	// 没有每日次数时只更新时间, 空的$inc会被MongoDB拒绝
+	change := bson.M{"$max": bson.M{"last_seen": last}}
+	if !first.IsZero() {
+		change["$min"] = bson.M{"first_seen": first}
+	}
+	inc := bson.M{}
+	for day, n := range days {
+		inc["days."+day] = n
+	}
+	if len(inc) > 0 {
+		change["$inc"] = inc
+	}
+
+	var swarm SC_Swarm
+	_, err := c.FindId(hash).Apply(mgo.Change{
+		Update:    change,
+		Upsert:    true,
+		ReturnNew: true,
+	}, &swarm)
+	if err != nil {
+		return err
+	}
+
+	set := bson.M{}
+	for _, day := range swarm.trim(time.Now()) {
+		set["days."+day] = ""
+	}
+	update := bson.M{"$set": bson.M{"trend": swarm.Trend}}
+	if len(set) > 0 {
+		update["$unset"] = set
+	}
+
+	return c.UpdateId(hash, update)
+}
+
+// 获取种子的获取历史
+func (this *MgoStore) GetSwarm(hash string) (SC_Swarm, bool) {
+	c, done := this.with(this.DbSwarm)
+	defer done()
+
+	var swarm SC_Swarm
+	err := c.FindId(hash).One(&swarm)
+	return swarm, err == nil
+}
+
+// 获取近期热度最高的种子历史
+func (this *MgoStore) TrendingSwarms(since time.Time, limit int) []SC_Swarm {
+	c, done := this.with(this.DbSwarm)
+	defer done()
+
+	var swarms []SC_Swarm
+	GetDataByQuery(c, 0, limit, "-trend", bson.M{"last_seen": bson.M{"$gte": since}}, &swarms)
+	return swarms
+}
+
+/********************* SC_Meta 操作 *********************/
+
+// 获取数据迁移的执行进度
+func (this *MgoStore) GetMeta(id string) (SC_Meta, bool) {
+	c, done := this.with(this.DbMeta)
+	defer done()
+
+	var meta SC_Meta
+	err := c.FindId(id).One(&meta)
+	return meta, err == nil
+}
+
+// 保存数据迁移的执行进度
+func (this *MgoStore) SaveMeta(meta *SC_Meta) error {
+	c, done := this.with(this.DbMeta)
+	defer done()
+
+	_, err := c.UpsertId(meta.Id, meta)
+	return err
+}
+
+// 关闭数据库连接
+func (this *MgoStore) Close() error {
+	if this.reader != nil {
+		this.reader.Session.Close()
+	}
+	this.Session.Close()
+	return nil
 }
 
 /********************* 公共操作 *********************/
@@ -138,6 +929,20 @@ func Update(collection *mgo.Collection, query, data interface{}) error {
 	return collection.Update(query, data)
 }
 
+// 更新或插入一条数据, 返回是否插入了新数据
+func Upsert(collection *mgo.Collection, query, data interface{}) (bool, error) {
+	info, err := collection.Upsert(query, data)
+	// 并发插入同一条数据时会违反唯一索引, 此时数据已存在, 重试即为更新
+	if mgo.IsDup(err) {
+		info, err = collection.Upsert(query, data)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return info.UpsertedId != nil, nil
+}
+
 // 删除一条数据
 func Delete(collection *mgo.Collection, query interface{}) error {
 	return collection.Remove(query)
@@ -190,115 +995,3 @@ func SetAdd(collection *mgo.Collection, query interface{}, field string, add boo
 		return collection.Update(query, bson.M{"$inc": bson.M{field: -1}})
 	}
 }
-
-/********************* SC_Hash 操作 *********************/
-
-// 保存Hash数据
-func (this *SC_Hash) Save() error {
-	// 查询此条数据是否存在
-	if Has(DbHash, bson.M{"infohash": this.InfoHash}) {
-		// 如果存在则进行自增处理
-		return SetAdd(DbHash, bson.M{"infohash": this.InfoHash}, "hot", true)
-	} else {
-		// 创建编号
-		this.Id = bson.NewObjectId()
-		// 添加数据
-		return Insert(DbHash, this)
-	}
-}
-
-// 验证此Hash是否已经入库
-func IsPut(hash string) bool {
-	// 定义一个SC_Hash
-	var schash SC_Hash
-	// 获取Hash信息
-	GetOneByQuery(DbHash, bson.M{"infohash": hash}, &schash)
-
-	if schash.InfoHash == "" {
-		return false
-	}
-
-	// 返回是否入库
-	return schash.IsPut
-}
-
-// 设置Hash为已入库状态
-func SetPut(hash string) error {
-	return Update(DbHash, bson.M{"infohash": hash}, bson.M{"$set": bson.M{"isput": true}})
-}
-
-/********************* SC_Info 操作 *********************/
-
-// 保存种子数据
-func (this *SC_Info) Save() error {
-	// 查询此条数据是否存在
-	if Has(DbInfo, bson.M{"infohash": this.InfoHash}) {
-		// 如果存在则更新
-		return Update(DbInfo, bson.M{"infohash": this.InfoHash}, bson.M{"$set": bson.M{"caption": this.Caption, "length": this.Length, "hot": this.Hot, "filecount": this.FileCount, "createtime": this.CreateTime, "puttime": this.PutTime}})
-	} else {
-		// 创建编号
-		this.Id = bson.NewObjectId()
-		// 添加数据
-		return Insert(DbInfo, this)
-	}
-}
-
-// 修改热度信息
-func SetHot(hash string) error {
-	// 查询种子信息表中是否存在此hash
-	if Has(DbInfo, bson.M{"infohash": hash}) {
-		// 修改种子热度
-		return SetAdd(DbInfo, bson.M{"infohash": hash}, "hot", true)
-	}
-
-	return nil
-}
-
-/********************* SC_Log 操作 *********************/
-
-// 保存统计数据
-func SaveLog(day, field string) error {
-	// 查询此条数据是否存在
-	if !Has(DbLog, bson.M{"day": day}) {
-		// 定义一个SC_Log
-		var sclog SC_Log
-		// 设置日期
-		sclog.Day = day
-		// 判断设置字段
-		switch field {
-		case "dhtnums":
-			sclog.DhtNums = 1
-			break
-		case "putnums":
-			sclog.PutNums = 1
-			break
-		}
-		// 创建编号
-		sclog.Id = bson.NewObjectId()
-		// 添加数据
-		return Insert(DbLog, sclog)
-	} else {
-		// 自增
-		return SetAdd(DbLog, bson.M{"day": day}, field, true)
-	}
-}
-
-/********************* SC_Search 操作 *********************/
-
-// 保存搜索数据
-func (this *SC_Search) Save() error {
-	// 查询此条数据是否存在
-	if Has(DbSearch, bson.M{"caption": this.Caption}) {
-		// 存在则自增
-		SetAdd(DbSearch, bson.M{"caption": this.Caption}, "views", true)
-		// 更新查询时间
-		return Update(DbSearch, bson.M{"caption": this.Caption}, bson.M{"$set": bson.M{"searchtime": bson.Now()}})
-	} else {
-		// 创建编号
-		this.Id = bson.NewObjectId()
-		// 设置查询时间
-		this.SearchTime = bson.Now()
-		// 添加数据
-		return Insert(DbSearch, this)
-	}
-}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2"
+)
+
+func TestDialInfo(t *testing.T) {
+	dir := t.TempDir()
+	// 测试服务器的证书作为CA文件
+	server := httptest.NewTLSServer(nil)
+	server.Close()
+	caFile := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+	badFile := filepath.Join(dir, "bad.pem")
+	ioutil.WriteFile(badFile, []byte("not a certificate"), 0644)
+
+	tests := []struct {
+		name     string
+		config   DB
+		addrs    []string
+		database string
+		source   string
+		replica  string
+		mode     mgo.Mode
+		tls      bool
+		err      bool
+	}{
+		{
+			name:     "fields",
+			config:   DB{Host: "a, b:27018", Port: 27017, Name: "scdht", User: "u", Pass: "p", AuthDB: "admin", ReplicaSet: "rs0", ReadPref: "nearest"},
+			addrs:    []string{"a:27017", "b:27018"},
+			database: "scdht", source: "admin", replica: "rs0", mode: mgo.Nearest,
+		},
+		{
+			name:     "url read preference overrides config",
+			config:   DB{URL: "mongodb://u:p@h1:27017,h2:27017/db?replicaSet=rs0&readPreference=secondary&authSource=admin", ReadPref: "nearest"},
+			addrs:    []string{"h1:27017", "h2:27017"},
+			database: "db", source: "admin", replica: "rs0", mode: mgo.Secondary,
+		},
+		{
+			name:     "url without database",
+			config:   DB{URL: "mongodb://h1:27017/?readPreference=primary", Name: "scdht"},
+			addrs:    []string{"h1:27017"},
+			database: "scdht", mode: mgo.Primary,
+		},
+		{
+			name:     "ssl option",
+			config:   DB{URL: "mongodb://h1:27017/db?ssl=true&replicaSet=rs0"},
+			addrs:    []string{"h1:27017"},
+			database: "db", replica: "rs0", mode: mgo.SecondaryPreferred, tls: true,
+		},
+		{
+			name:     "tls option with ca file",
+			config:   DB{URL: "mongodb://h1:27017/db?tls=true&tlsCAFile=" + caFile + "&readPreference=secondaryPreferred"},
+			addrs:    []string{"h1:27017"},
+			database: "db", mode: mgo.SecondaryPreferred, tls: true,
+		},
+		{
+			name:     "url disables tls from config",
+			config:   DB{URL: "mongodb://h1:27017/db?tls=false", TLS: true},
+			addrs:    []string{"h1:27017"},
+			database: "db", mode: mgo.SecondaryPreferred,
+		},
+		{
+			name:     "tls from config",
+			config:   DB{Host: "h1", Port: 27017, Name: "db", TLS: true, TLSCA: caFile},
+			addrs:    []string{"h1:27017"},
+			database: "db", mode: mgo.SecondaryPreferred, tls: true,
+		},
+		{name: "unknown read preference", config: DB{URL: "mongodb://h1/db?readPreference=fastest"}, err: true},
+		{name: "missing ca file", config: DB{URL: "mongodb://h1/db?tls=true&tlsCAFile=" + filepath.Join(dir, "none.pem")}, err: true},
+		{name: "invalid ca file", config: DB{URL: "mongodb://h1/db?ssl=true&tlsCAFile=" + badFile}, err: true},
+	}
+
+	for _, tt := range tests {
+		tt.config.PoolLimit = 32
+		tt.config.Timeout = 3 * time.Second
+
+		info, mode, err := dialInfo(&tt.config)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: no error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(info.Addrs, tt.addrs) || info.Database != tt.database || info.Source != tt.source || info.ReplicaSetName != tt.replica {
+			t.Errorf("%s: addrs %v, database %q, source %q, replica set %q", tt.name, info.Addrs, info.Database, info.Source, info.ReplicaSetName)
+		}
+		if mode != tt.mode {
+			t.Errorf("%s: mode %v, want %v", tt.name, mode, tt.mode)
+		}
+		if (info.DialServer != nil) != tt.tls {
+			t.Errorf("%s: tls %v, want %v", tt.name, info.DialServer != nil, tt.tls)
+		}
+		if info.PoolLimit != 32 || info.Timeout != 3*time.Second {
+			t.Errorf("%s: pool %d, timeout %v", tt.name, info.PoolLimit, info.Timeout)
+		}
+	}
+
+	// 用户名与密码来自配置
+	info, _, _ := dialInfo(&DB{Host: "h1", Port: 27017, Name: "db", User: "u", Pass: "p@ss"})
+	if info.Username != "u" || info.Password != "p@ss" {
+		t.Errorf("credentials %q %q", info.Username, info.Password)
+	}
+}
@@ -2,6 +2,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gopkg.in/mgo.v2/bson"
@@ -9,42 +10,252 @@ import (
 
 // SC_Hash表结构
 type SC_Hash struct {
-	Id       bson.ObjectId `_id`             // 数据编号
-	InfoHash string        `bson:"infohash"` // InfoHash
-	Hot      int64         `bson:"hot"`      // Hash热度
-	Invalid  int           `bson:"invalid"`  // 失败次数
-	IsPut    bool          `bson:"isput"`    // 是否已入库
+	Id         bson.ObjectId `bson:"_id"`        // 数据编号
+	InfoHash   string        `bson:"infohash"`   // InfoHash
+	Hot        int64         `bson:"hot"`        // Hash热度
+	Invalid    int           `bson:"invalid"`    // 失败次数
+	IsPut      bool          `bson:"isput"`      // 是否已入库
+	Announce   int64         `bson:"announce"`   // announce_peer次数
+	GetPeers   int64         `bson:"getpeers"`   // get_peers次数
+	CreateTime time.Time     `bson:"createtime"` // 首次获取时间
+	LastSeen   time.Time     `bson:"lastseen"`   // 最后获取时间
 }
 
 // SC_Info表结构
 type SC_Info struct {
-	Id         bson.ObjectId `_id`               // 数据编号
-	InfoHash   string        `bson:"infohash"`   // InfoHash
-	Caption    string        `bson:"caption"`    // 种子名称
-	Length     int64         `bson:"length"`     // 种子大小, 单位字节
-	Hot        int64         `bson:"hot"`        // 种子热度
-	Files      []interface{} `bson:"files"`      // 文件列表
-	FileList   []interface{} `bson:"filelist"`   // 文件列表
-	FileCount  int64         `bson:"filecount"`  // 种子文件数量
-	Keys       []string      `bson:"keys"`       // 种子分词记录
-	Views      int64         `bson:"views"`      // 查看次数
-	CreateTime time.Time     `bson:"createtime"` // 种子创建时间
-	PutTime    time.Time     `bson:"puttime"`    // 种子入库时间
-}
-
-// SC_Log表结构
+	Id          bson.ObjectId `bson:"_id"`                   // 数据编号
+	InfoHash    string        `bson:"infohash"`              // InfoHash
+	InfoHashV2  string        `bson:"infohashv2,omitempty"`  // v2格式InfoHash
+	MetaVersion int64         `bson:"metaversion"`           // 元数据版本
+	Caption     string        `bson:"caption"`               // 种子名称
+	RawCaption  []byte        `bson:"rawcaption,omitempty"`  // 转换为utf-8前的原始名称
+	Charset     string        `bson:"charset,omitempty"`     // 原始名称使用的字符集
+	Length      int64         `bson:"length"`                // 种子大小, 单位字节
+	Hot         int64         `bson:"hot"`                   // 种子热度
+	Files       []File        `bson:"files"`                 // 文件列表
+	FileList    []*FileTree   `bson:"filelist"`              // 文件树
+	FileCount   int64         `bson:"filecount"`             // 种子文件数量
+	Category    string        `bson:"category"`              // 内容分类
+	Release     *Release      `bson:"release"`               // 发布名称解析结果, 无法解析时为null
+	Fingerprint string        `bson:"fingerprint,omitempty"` // 由文件列表计算的内容指纹, 相同内容的种子指纹相同
+	Duplicate   bool          `bson:"duplicate,omitempty"`   // 是否为重复内容, 同一指纹中热度最高的种子不是重复
+	Keys        []string      `bson:"keys"`                  // 种子分词记录
+	Trackers    [][]string    `bson:"trackers"`              // 分层tracker列表
+	WebSeeds    []string      `bson:"webseeds"`              // web种子(url-list)
+	HttpSeeds   []string      `bson:"httpseeds"`             // http种子(httpseeds)
+	PieceLength int64         `bson:"piecelength"`           // 分块大小
+	PieceCount  int64         `bson:"piececount"`            // 分块数量
+	Private     bool          `bson:"private"`               // 是否为私有种子
+	Source      string        `bson:"source"`                // 发布来源
+	Comment     string        `bson:"comment"`               // 种子注释
+	CreatedBy   string        `bson:"createdby"`             // 创建工具
+	Views       int64         `bson:"views"`                 // 查看次数
+	CreateTime  time.Time     `bson:"createtime"`            // 种子创建时间
+	PutTime     time.Time     `bson:"puttime"`               // 种子入库时间
+}
+
+// SC_Log表结构, 每天一条数据
 type SC_Log struct {
-	Id      bson.ObjectId `_id`            // 数据编号
-	Day     string        `bson:"day"`     // 统计日期
-	DhtNums int64         `bson:"dhtnums"` // 获取到的infohash数量
-	PutNums int64         `bson:"putnums"` // 种子入库数量
+	Id    bson.ObjectId        `bson:"_id"`                                    // 数据编号
+	Day   string               `bson:"day" json:"day"`                         // 统计日期
+	Hours map[string]LogCounts `bson:"hours,omitempty" json:"hours,omitempty"` // 以两位小时为键的每小时统计
+
+	// 全天统计
+	LogCounts `bson:",inline"`
+}
+
+// 统计字段
+const (
+	LogSeen   = "seen"       // 获取到的infohash次数, 包含重复的
+	LogDht    = "dhtnums"    // 新的infohash数量
+	LogPut    = "putnums"    // 种子入库数量
+	LogFail   = "failnums"   // 种子下载失败次数
+	LogSearch = "searchnums" // 搜索次数
+	LogView   = "viewnums"   // 种子查看次数
+)
+
+// 是否为有效的统计字段
+func IsLogField(field string) bool {
+	var counts LogCounts
+	return counts.Add(field, 0)
+}
+
+// 统计数据
+type LogCounts struct {
+	Seen       int64 `bson:"seen" json:"seen"`             // 获取到的infohash次数, 包含重复的
+	DhtNums    int64 `bson:"dhtnums" json:"dhtnums"`       // 新的infohash数量
+	PutNums    int64 `bson:"putnums" json:"putnums"`       // 种子入库数量
+	FailNums   int64 `bson:"failnums" json:"failnums"`     // 种子下载失败次数
+	SearchNums int64 `bson:"searchnums" json:"searchnums"` // 搜索次数
+	ViewNums   int64 `bson:"viewnums" json:"viewnums"`     // 种子查看次数
+}
+
+// 累加统计字段, 返回是否为有效的字段
+func (this *LogCounts) Add(field string, n int64) bool {
+	switch field {
+	case LogSeen:
+		this.Seen += n
+	case LogDht:
+		this.DhtNums += n
+	case LogPut:
+		this.PutNums += n
+	case LogFail:
+		this.FailNums += n
+	case LogSearch:
+		this.SearchNums += n
+	case LogView:
+		this.ViewNums += n
+	default:
+		return false
+	}
+
+	return true
+}
+
+// 合并统计数据
+func (this *LogCounts) Merge(other LogCounts) {
+	this.Seen += other.Seen
+	this.DhtNums += other.DhtNums
+	this.PutNums += other.PutNums
+	this.FailNums += other.FailNums
+	this.SearchNums += other.SearchNums
+	this.ViewNums += other.ViewNums
+}
+
+// 累加统计, 键为字段名或hours.小时.字段名
+func (this *SC_Log) Add(counts map[string]int64) {
+	for key, n := range counts {
+		parts := strings.Split(key, ".")
+		if len(parts) == 3 && parts[0] == "hours" {
+			if this.Hours == nil {
+				this.Hours = make(map[string]LogCounts)
+			}
+			hour := this.Hours[parts[1]]
+			if hour.Add(parts[2], n) {
+				this.Hours[parts[1]] = hour
+			}
+			continue
+		}
+		this.LogCounts.Add(key, n)
+	}
 }
 
 // SC_Search表结构
 type SC_Search struct {
-	Id         bson.ObjectId `_id`               // 数据编号
+	Id         bson.ObjectId `bson:"_id"`        // 数据编号
 	Caption    string        `bson:"caption"`    // 搜索关键字
 	SearchTime time.Time     `bson:"searchtime"` // 搜索时间
 	Count      int           `bson:"count"`      // 查询到的资源总量
 	Views      int64         `bson:"views"`      // 搜索次数
 }
+
+// SC_Meta表结构, 每个数据迁移一条数据, 记录执行进度
+type SC_Meta struct {
+	Id        string        `bson:"_id" json:"name"`                          // 迁移名称
+	Version   int           `bson:"version" json:"version"`                   // 迁移版本, 按版本顺序执行
+	Cursor    bson.ObjectId `bson:"cursor,omitempty" json:"cursor,omitempty"` // 已处理的最后一条数据编号, 中断后从此处继续
+	Count     int           `bson:"count" json:"count"`                       // 已处理的数据数量
+	Done      bool          `bson:"done" json:"done"`                         // 是否已完成
+	StartTime time.Time     `bson:"starttime" json:"starttime"`               // 开始时间
+	DoneTime  time.Time     `bson:"donetime" json:"donetime"`                 // 完成时间
+}
+
+// SC_Swarm表结构, 每个已入库的种子一条数据, 记录获取到infohash的历史
+type SC_Swarm struct {
+	InfoHash  string           `bson:"_id" json:"infohash"`          // InfoHash
+	FirstSeen time.Time        `bson:"first_seen" json:"first_seen"` // 首次获取时间
+	LastSeen  time.Time        `bson:"last_seen" json:"last_seen"`   // 最后获取时间
+	Days      map[string]int64 `bson:"days" json:"days"`             // 以日期为键的每日获取次数, 只保留最近SwarmDays天
+	Trend     int64            `bson:"trend" json:"trend"`           // 写入时最近TrendDays天的获取次数
+}
+
+// 发布名称解析结果
+type Release struct {
+	Title      string   `bson:"title,omitempty" json:"title,omitempty"`           // 标题
+	Artist     string   `bson:"artist,omitempty" json:"artist,omitempty"`         // 艺术家, 仅音乐
+	Year       int      `bson:"year,omitempty" json:"year,omitempty"`             // 年份
+	Season     int      `bson:"season,omitempty" json:"season,omitempty"`         // 季
+	Episode    int      `bson:"episode,omitempty" json:"episode,omitempty"`       // 集
+	EpisodeEnd int      `bson:"episodeend,omitempty" json:"episodeend,omitempty"` // 多集合并时的结束集
+	Resolution string   `bson:"resolution,omitempty" json:"resolution,omitempty"` // 分辨率
+	Source     string   `bson:"source,omitempty" json:"source,omitempty"`         // 片源
+	VideoCodec string   `bson:"videocodec,omitempty" json:"videocodec,omitempty"` // 视频编码
+	Audio      string   `bson:"audio,omitempty" json:"audio,omitempty"`           // 音频格式
+	Bitrate    string   `bson:"bitrate,omitempty" json:"bitrate,omitempty"`       // 音频码率
+	BitDepth   int      `bson:"bitdepth,omitempty" json:"bitdepth,omitempty"`     // 音频位深
+	Languages  []string `bson:"languages,omitempty" json:"languages,omitempty"`   // 语言
+	Group      string   `bson:"group,omitempty" json:"group,omitempty"`           // 发布组
+}
+
+// 文件信息结构
+type File struct {
+	Path       string   `bson:"path" json:"path"`                                 // 以/连接的文件路径, 兼容旧数据
+	Parts      []string `bson:"parts,omitempty" json:"parts,omitempty"`           // 文件路径
+	Length     int64    `bson:"length" json:"length"`                             // 文件长度
+	Ext        string   `bson:"ext,omitempty" json:"ext,omitempty"`               // 小写扩展名, 不含.
+	Media      string   `bson:"media,omitempty" json:"media,omitempty"`           // 媒体类型
+	Attr       string   `bson:"attr,omitempty" json:"attr,omitempty"`             // 文件属性(BEP 47)
+	Md5sum     string   `bson:"md5sum,omitempty" json:"md5sum,omitempty"`         // 文件md5
+	PiecesRoot string   `bson:"piecesroot,omitempty" json:"piecesroot,omitempty"` // v2文件merkle树根hash
+	Raw        [][]byte `bson:"raw,omitempty" json:"raw,omitempty"`               // 转换为utf-8前的原始路径, JSON中为base64
+}
+
+// 是否为填充文件
+func (this *File) IsPadding() bool {
+	if strings.Contains(this.Attr, "p") {
+		return true
+	}
+
+	// BitComet的填充文件没有attr属性
+	name := this.Path
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.HasPrefix(name, "_____padding_file_")
+}
+
+// 是否为隐藏文件
+func (this *File) IsHidden() bool {
+	return strings.Contains(this.Attr, "h")
+}
+
+// 是否为可执行文件
+func (this *File) IsExecutable() bool {
+	return strings.Contains(this.Attr, "x")
+}
+
+// 文件树目录结构, 字段名与旧数据保持一致
+type FileTree struct {
+	Name  string      `bson:"name" json:"name"`   // 目录名称, 根目录为空
+	Size  int64       `bson:"size" json:"size"`   // 目录下所有文件的总大小
+	Dirs  []*FileTree `bson:"dirs" json:"dirs"`   // 子目录
+	Files []*TreeFile `bson:"files" json:"files"` // 文件
+}
+
+// 文件树文件结构
+type TreeFile struct {
+	Name   string `bson:"path" json:"name"`     // 文件名
+	Length int64  `bson:"length" json:"length"` // 文件长度
+}
+
+// 目录总大小, 旧数据没有保存时重新计算
+func (this *FileTree) Total() int64 {
+	if this.Size > 0 {
+		return this.Size
+	}
+
+	var size int64
+	for _, d := range this.Dirs {
+		size += d.Total()
+	}
+	for _, f := range this.Files {
+		size += f.Length
+	}
+
+	return size
+}
+
+// 是否为纯v2种子
+func (this *SC_Info) IsV2Only() bool {
+	return this.InfoHashV2 != "" && this.InfoHash == this.InfoHashV2[:40]
+}
@@ -0,0 +1,298 @@
+// 内存与bolt存储共用的查询处理
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 关键字匹配器, 与MongoDB一致按不区分大小写的正则匹配, 不是合法正则时按子串匹配
+func keyMatcher(key string) func(string) bool {
+	if key == "" {
+		return func(string) bool { return true }
+	}
+
+	if re, err := regexp.Compile("(?i)" + key); err == nil {
+		return re.MatchString
+	}
+
+	key = strings.ToLower(key)
+	return func(s string) bool {
+		return strings.Contains(strings.ToLower(s), key)
+	}
+}
+
+// 是否按发布属性过滤
+func (q InfoQuery) hasRelease() bool {
+	r := q.Release
+	return r.Resolution != "" || r.Source != "" || r.VideoCodec != "" || r.Audio != "" || r.Year > 0 || r.Season > 0
+}
+
+// 是否没有任何查询条件
+func (q InfoQuery) isEmpty() bool {
+	return q.Key == "" && q.Category == "" && q.Missing == "" && q.After == "" && q.MinHot == 0 && q.Since.IsZero() && q.Until.IsZero() && q.Fingerprint == "" && !q.Collapse && !q.hasRelease()
+}
+
+// 时间是否在范围内, 零值表示不限
+func inRange(t, since, until time.Time) bool {
+	return (since.IsZero() || !t.Before(since)) && (until.IsZero() || t.Before(until))
+}
+
+// 种子是否满足查询条件
+func matchInfo(q InfoQuery, match func(string) bool, scinfo *SC_Info) bool {
+	if q.After != "" && scinfo.Id <= q.After {
+		return false
+	}
+	if scinfo.Hot < q.MinHot || !inRange(scinfo.PutTime, q.Since, q.Until) {
+		return false
+	}
+	if q.Category != "" && scinfo.Category != q.Category {
+		return false
+	}
+	if (q.Fingerprint != "" && scinfo.Fingerprint != q.Fingerprint) || (q.Collapse && scinfo.Duplicate) {
+		return false
+	}
+
+	// 发布属性
+	if rq := q.Release; q.hasRelease() {
+		r := scinfo.Release
+		if r == nil {
+			return false
+		}
+		if (rq.Resolution != "" && r.Resolution != rq.Resolution) ||
+			(rq.Source != "" && r.Source != rq.Source) ||
+			(rq.VideoCodec != "" && r.VideoCodec != rq.VideoCodec) ||
+			(rq.Audio != "" && r.Audio != rq.Audio) ||
+			(rq.Year > 0 && r.Year != rq.Year) ||
+			(rq.Season > 0 && r.Season != rq.Season) {
+			return false
+		}
+	}
+
+	switch q.Missing {
+	case MissingParts:
+		missing := false
+		for _, f := range scinfo.Files {
+			if len(f.Parts) == 0 {
+				missing = true
+				break
+			}
+		}
+		if !missing {
+			return false
+		}
+	case MissingCategory:
+		if scinfo.Category != "" {
+			return false
+		}
+	case MissingRelease:
+		if scinfo.Release != nil {
+			return false
+		}
+	case MissingFingerprint:
+		if scinfo.Fingerprint != "" {
+			return false
+		}
+	}
+
+	// 关键字匹配标题或任一分词
+	if q.Key != "" && !match(scinfo.Caption) {
+		for _, key := range scinfo.Keys {
+			if match(key) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// 按字段排序种子, 不支持的字段按编号排序
+func sortInfos(infos []SC_Info, field string) {
+	desc := strings.HasPrefix(field, "-")
+	field = strings.TrimPrefix(field, "-")
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		a, b := &infos[i], &infos[j]
+		if desc {
+			a, b = b, a
+		}
+		switch field {
+		case "hot":
+			return a.Hot < b.Hot
+		case "length":
+			return a.Length < b.Length
+		case "views":
+			return a.Views < b.Views
+		case "filecount":
+			return a.FileCount < b.FileCount
+		case "puttime":
+			return a.PutTime.Before(b.PutTime)
+		case "createtime":
+			return a.CreateTime.Before(b.CreateTime)
+		}
+		return a.Id < b.Id
+	})
+}
+
+// hash是否等待入库
+func pendingHash(schash *SC_Hash, field string) bool {
+	if schash.IsPut || schash.Invalid > 3 {
+		return false
+	}
+
+	// 按创建时间排序时忽略没有创建时间的旧数据
+	if strings.TrimPrefix(field, "-") == "createtime" && !schash.CreateTime.After(time.Time{}) {
+		return false
+	}
+
+	return true
+}
+
+// hash是否满足查询条件
+func matchHash(q HashQuery, schash *SC_Hash) bool {
+	if q.After != "" && schash.Id <= q.After {
+		return false
+	}
+	if schash.Hot < q.MinHot || (q.MaxHot > 0 && schash.Hot > q.MaxHot) {
+		return false
+	}
+	if (q.Pending || q.Failed) && (schash.IsPut || (schash.Invalid > 3) != q.Failed) {
+		return false
+	}
+
+	return inRange(schash.LastSeen, q.Since, q.Until)
+}
+
+// 搜索是否满足查询条件
+func matchSearch(q SearchQuery, scsearch *SC_Search) bool {
+	return (q.After == "" || scsearch.Id > q.After) && inRange(scsearch.SearchTime, q.Since, q.Until)
+}
+
+// 按字段排序hash, 不支持的字段按编号排序
+func sortHashes(hashes []SC_Hash, field string) {
+	desc := strings.HasPrefix(field, "-")
+	field = strings.TrimPrefix(field, "-")
+
+	sort.SliceStable(hashes, func(i, j int) bool {
+		a, b := &hashes[i], &hashes[j]
+		if desc {
+			a, b = b, a
+		}
+		switch field {
+		case "hot":
+			return a.Hot < b.Hot
+		case "lastseen":
+			return a.LastSeen.Before(b.LastSeen)
+		case "createtime":
+			return a.CreateTime.Before(b.CreateTime)
+		}
+		return a.Id < b.Id
+	})
+}
+
+// 按字段排序搜索, 不支持的字段按编号排序
+func sortSearches(searches []SC_Search, field string) {
+	desc := strings.HasPrefix(field, "-")
+	field = strings.TrimPrefix(field, "-")
+
+	sort.SliceStable(searches, func(i, j int) bool {
+		a, b := &searches[i], &searches[j]
+		if desc {
+			a, b = b, a
+		}
+		switch field {
+		case "views":
+			return a.Views < b.Views
+		case "searchtime":
+			return a.SearchTime.Before(b.SearchTime)
+		case "count":
+			return a.Count < b.Count
+		}
+		return a.Id < b.Id
+	})
+}
+
+// 截取分页数据
+func pageRange(total, start, length int) (int, int) {
+	if start < 0 {
+		start = 0
+	}
+	if start > total {
+		start = total
+	}
+
+	end := total
+	if length > 0 && start+length < total {
+		end = start + length
+	}
+
+	return start, end
+}
+
+// 用新的种子信息更新已存在的种子, 字段与MongoDB存储的更新一致, 热度, 查看次数与入库时间保持不变
+func mergeInfo(dst, src *SC_Info) {
+	dst.Caption = src.Caption
+	dst.Length = src.Length
+	dst.FileCount = src.FileCount
+	dst.Files = src.Files
+	dst.FileList = src.FileList
+	dst.Category = src.Category
+	dst.Release = src.Release
+	dst.Fingerprint = src.Fingerprint
+	dst.CreateTime = src.CreateTime
+	dst.Trackers = src.Trackers
+	dst.WebSeeds = src.WebSeeds
+	dst.HttpSeeds = src.HttpSeeds
+	dst.PieceLength = src.PieceLength
+	dst.PieceCount = src.PieceCount
+	dst.Private = src.Private
+	dst.Source = src.Source
+	dst.Comment = src.Comment
+	dst.CreatedBy = src.CreatedBy
+	dst.MetaVersion = src.MetaVersion
+	dst.Charset = src.Charset
+	dst.RawCaption = src.RawCaption
+}
+
+// 按日期排序统计
+func sortLogs(logs []SC_Log) {
+	sort.Slice(logs, func(i, j int) bool {
+		return logs[i].Day < logs[j].Day
+	})
+}
+
+// 复制统计, 每小时统计不与原数据共用
+func copyLog(sclog *SC_Log) SC_Log {
+	c := *sclog
+	if sclog.Hours != nil {
+		c.Hours = make(map[string]LogCounts, len(sclog.Hours))
+		for hour, counts := range sclog.Hours {
+			c.Hours[hour] = counts
+		}
+	}
+	return c
+}
+
+// 按近期热度倒序排列获取历史, 热度相同时最后获取时间晚的在前
+func sortSwarms(swarms []SC_Swarm) {
+	sort.Slice(swarms, func(i, j int) bool {
+		if swarms[i].Trend != swarms[j].Trend {
+			return swarms[i].Trend > swarms[j].Trend
+		}
+		return swarms[i].LastSeen.After(swarms[j].LastSeen)
+	})
+}
+
+// 复制获取历史, 每日获取次数不与原数据共用
+func copySwarm(swarm *SC_Swarm) SC_Swarm {
+	c := *swarm
+	c.Days = make(map[string]int64, len(swarm.Days))
+	for day, n := range swarm.Days {
+		c.Days[day] = n
+	}
+	return c
+}
@@ -0,0 +1,413 @@
+// 数据存储接口
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/astaxie/beego"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// 不支持的存储类型
+var ErrStore = errors.New("models: unknown store type")
+
+// 数据存储接口, 覆盖hash, 种子, 统计, 搜索, 获取历史与迁移进度六类数据
+type Store interface {
+	/********************* SC_Hash 操作 *********************/
+
+	// 保存hash, 已存在则累加热度与来源次数并更新最后获取时间, 返回是否为新数据
+	SaveHash(schash *SC_Hash) (bool, error)
+	// hash是否存在
+	HasHash(hash string) bool
+	// 获取hash数据
+	GetHash(hash string) (SC_Hash, bool)
+	// hash是否已入库
+	IsPut(hash string) bool
+	// 设置hash为已入库状态
+	SetPut(hash string) error
+	// 失败次数加一
+	AddInvalid(hash string) error
+	// 获取未入库且失败次数不超过3次的hash, 按createtime排序时忽略没有创建时间的旧数据
+	PendingHashes(sort string, limit int) []SC_Hash
+	// 按编号顺序获取满足条件的hash, 用于分批遍历
+	ScanHashes(query HashQuery, limit int) []SC_Hash
+	// 按条件统计hash数量
+	CountHashes(query HashQuery) int
+	// 不存在时插入hash, 保留原有的统计与时间, 返回是否插入
+	ImportHash(schash *SC_Hash) (bool, error)
+	// 删除hash, 返回删除的数量
+	DeleteHashes(hashes []string) (int, error)
+
+	/********************* SC_Info 操作 *********************/
+
+	// 保存种子, 已存在则更新种子信息并保留热度, 查看次数与入库时间, 返回是否为新数据
+	SaveInfo(scinfo *SC_Info) (bool, error)
+	// 不存在时插入种子, 保留原有的数据, 返回是否插入
+	ImportInfo(scinfo *SC_Info) (bool, error)
+	// 通过infohash获取种子
+	GetInfo(hash string) (SC_Info, bool)
+	// 通过v2格式infohash获取种子
+	GetInfoV2(hash string) (SC_Info, bool)
+	// 种子是否存在
+	HasInfo(hash string) bool
+	// 获取已入库的infohash
+	InfoHashes(hashes []string) map[string]bool
+	// 种子存在时热度加一
+	AddHot(hash string) error
+	// 查看次数加一
+	AddViews(hash string) error
+	// 将同一内容指纹中hash以外的种子标记为重复, hash取消重复标记
+	SetGroupBest(fingerprint, hash string) error
+	// 按条件查询种子, sort为字段名, 以-开头表示倒序
+	FindInfos(query InfoQuery, start, length int, sort string) []SC_Info
+	// 按条件统计种子数量
+	CountInfos(query InfoQuery) int
+
+	/********************* SC_Log 操作 *********************/
+
+	// 累加指定日期的多个统计字段, 键为字段名或hours.小时.字段名, 返回是否为当天的第一条统计
+	AddLog(day string, counts map[string]int64) (bool, error)
+	// 获取指定日期的统计
+	GetLog(day string) SC_Log
+	// 获取日期范围内的统计, 包含起止日期, 按日期排序
+	FindLogs(from, to string) []SC_Log
+	// 不存在当天的统计时插入, 返回是否插入
+	ImportLog(sclog *SC_Log) (bool, error)
+
+	/********************* SC_Search 操作 *********************/
+
+	// 保存搜索, 已存在则搜索次数加一并更新搜索时间, 返回是否为新的关键字
+	SaveSearch(scsearch *SC_Search) (bool, error)
+	// 获取搜索列表, key不为空时只获取包含key的搜索
+	FindSearches(key string, length int, sort string) []SC_Search
+	// 按编号顺序获取满足条件的搜索, 用于分批遍历
+	ScanSearches(query SearchQuery, limit int) []SC_Search
+	// 不存在时插入搜索, 保留原有的次数与时间, 返回是否插入
+	ImportSearch(scsearch *SC_Search) (bool, error)
+
+	/********************* SC_Swarm 操作 *********************/
+
+	// 合并infohash一段时间内的获取记录, 不存在则创建, 删除超出保留天数的日期并重新计算近期热度
+	AddSwarm(hash string, first, last time.Time, days map[string]int64) error
+	// 获取种子的获取历史
+	GetSwarm(hash string) (SC_Swarm, bool)
+	// 获取最后获取时间不早于since的种子历史, 按近期热度倒序
+	TrendingSwarms(since time.Time, limit int) []SC_Swarm
+
+	/********************* SC_Meta 操作 *********************/
+
+	// 获取数据迁移的执行进度
+	GetMeta(id string) (SC_Meta, bool)
+	// 保存数据迁移的执行进度, 已存在则覆盖
+	SaveMeta(meta *SC_Meta) error
+
+	// 关闭存储
+	Close() error
+}
+
+// 种子查询条件, 零值字段不参与过滤
+type InfoQuery struct {
+	Key         string        // 标题或分词包含的关键字, 不区分大小写的正则
+	Category    string        // 内容分类
+	Release     Release       // 发布属性, 只比较分辨率, 片源, 编码, 音频, 年份与季
+	Missing     string        // 缺少的数据, 见Missing开头的常量, 用于转换旧数据
+	Since       time.Time     // 入库时间不早于此时间
+	Until       time.Time     // 入库时间早于此时间
+	MinHot      int64         // 热度不低于此值
+	Fingerprint string        // 内容指纹
+	Collapse    bool          // 不查询重复内容, 每个指纹只保留热度最高的种子
+	After       bson.ObjectId // 只查询编号大于此值的数据, 用于分批遍历
+}
+
+// hash查询条件, 零值字段不参与过滤
+type HashQuery struct {
+	Since   time.Time     // 最后获取时间不早于此时间
+	Until   time.Time     // 最后获取时间早于此时间
+	MinHot  int64         // 热度不低于此值
+	MaxHot  int64         // 热度不高于此值
+	Pending bool          // 只查询未入库且失败次数不超过3次的hash
+	Failed  bool          // 只查询未入库且失败次数超过3次的hash
+	After   bson.ObjectId // 只查询编号大于此值的数据
+}
+
+// 搜索查询条件, 零值字段不参与过滤
+type SearchQuery struct {
+	Since time.Time     // 搜索时间不早于此时间
+	Until time.Time     // 搜索时间早于此时间
+	After bson.ObjectId // 只查询编号大于此值的数据
+}
+
+// 旧数据缺少的字段
+const (
+	MissingParts       = "parts"       // 文件没有路径数组
+	MissingCategory    = "category"    // 没有分类
+	MissingRelease     = "release"     // 没有解析发布名称
+	MissingFingerprint = "fingerprint" // 没有计算内容指纹
+)
+
+// 当前使用的数据存储
+var Db Store
+
+// 网站读取使用的数据存储, 可能读取到稍旧的数据, 写入仍使用Db
+var ReadDb Store
+
+// 初始化数据存储
+func Init() error {
+	// 获取数据库连接端口
+	dbport, _ := beego.AppConfig.Int("dbport")
+	// 获取是否允许显示信息
+	showmsg, _ := beego.AppConfig.Bool("showmsg")
+	// 获取是否使用TLS连接
+	dbtls, _ := beego.AppConfig.Bool("dbtls")
+
+	// 初始化数据库配置信息
+	DbConfig = &DB{
+		URL:           beego.AppConfig.String("dburl"),                                               // 配置连接字符串
+		Host:          beego.AppConfig.String("dbhost"),                                              // 配置数据库地址
+		Port:          dbport,                                                                        // 配置数据库端口
+		Name:          beego.AppConfig.String("dbname"),                                              // 配置数据库名称
+		User:          beego.AppConfig.String("dbuser"),                                              // 配置数据库用户名
+		Pass:          beego.AppConfig.String("dbpass"),                                              // 配置数据库密码
+		AuthDB:        beego.AppConfig.String("dbauthdb"),                                            // 配置认证数据库
+		ReplicaSet:    beego.AppConfig.String("dbreplicaset"),                                        // 配置副本集名称
+		TLS:           dbtls,                                                                         // 是否使用TLS连接
+		TLSCA:         beego.AppConfig.String("dbtlsca"),                                             // 配置CA文件
+		ReadPref:      beego.AppConfig.String("dbreadpref"),                                          // 配置网站读取使用的节点
+		PoolLimit:     beego.AppConfig.DefaultInt("dbpoollimit", 0),                                  // 配置每个服务器的最大连接数
+		Timeout:       time.Duration(beego.AppConfig.DefaultInt("dbtimeout", 10)) * time.Second,      // 配置连接超时时间
+		SocketTimeout: time.Duration(beego.AppConfig.DefaultInt("dbsockettimeout", 0)) * time.Second, // 配置单次操作超时时间
+		ShowMsg:       showmsg,                                                                       // 是否允许显示信息
+	}
+
+	store, err := NewStore(beego.AppConfig.String("store"), beego.AppConfig.String("storepath"))
+	if err != nil {
+		return err
+	}
+
+	Db = store
+	// MongoDB按配置的读取节点供网站读取, 其它存储与写入共用
+	ReadDb = store
+	if m, ok := store.(*MgoStore); ok {
+		ReadDb = m.Reader()
+	}
+
+	// 启动统计缓冲
+	interval, err := beego.AppConfig.Int("logflush")
+	if err != nil || interval < 1 {
+		interval = 5
+	}
+	Counter = NewLogCounter(store)
+	Counter.Start(time.Duration(interval) * time.Second)
+
+	// 启动获取历史缓冲
+	SwarmDays = beego.AppConfig.DefaultInt("swarmdays", 30)
+	if SwarmDays < 1 {
+		SwarmDays = 1
+	}
+	TrendDays = beego.AppConfig.DefaultInt("trenddays", 3)
+	if TrendDays < 1 || TrendDays > SwarmDays {
+		TrendDays = SwarmDays
+	}
+	interval, err = beego.AppConfig.Int("swarmflush")
+	if err != nil || interval < 1 {
+		interval = 60
+	}
+	Swarms = NewSwarmCounter(store)
+	Swarms.Start(time.Duration(interval) * time.Second)
+
+	return nil
+}
+
+// 写入缓冲中的统计并关闭数据存储, 程序退出前调用
+func Close() error {
+	if Counter != nil {
+		Counter.Stop()
+	}
+	if Swarms != nil {
+		Swarms.Stop()
+	}
+
+	return Db.Close()
+}
+
+// 根据类型创建数据存储, path为bolt数据文件路径
+func NewStore(kind, path string) (Store, error) {
+	switch kind {
+	case "", "mongo":
+		store, err := NewMgoStore(DbConfig)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "bolt":
+		if path == "" {
+			path = "data/scdht.db"
+		}
+		store, err := NewBoltStore(path)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "memory":
+		return NewMemoryStore(), nil
+	}
+
+	return nil, fmt.Errorf("%v: %s", ErrStore, kind)
+}
+
+/********************* SC_Hash 操作 *********************/
+
+// 保存Hash数据, 返回是否为新数据
+func (this *SC_Hash) Save() (bool, error) {
+	return Db.SaveHash(this)
+}
+
+// 验证此Hash是否已经入库
+func IsPut(hash string) bool {
+	return Db.IsPut(hash)
+}
+
+// 设置Hash为已入库状态
+func SetPut(hash string) error {
+	return Db.SetPut(hash)
+}
+
+/********************* SC_Info 操作 *********************/
+
+// 保存种子数据, 返回是否为新数据
+func (this *SC_Info) Save() (bool, error) {
+	return Db.SaveInfo(this)
+}
+
+// 获取已入库的infohash
+func InfoHashes(hashes []string) map[string]bool {
+	return Db.InfoHashes(hashes)
+}
+
+// 修改热度信息
+func SetHot(hash string) error {
+	return Db.AddHot(hash)
+}
+
+// 记录一次获取到infohash, 先在内存中累加, 由获取历史缓冲定时写入已入库的种子
+func SaveSwarm(hash string) {
+	now := time.Now()
+	if Swarms == nil {
+		if Db.HasInfo(hash) {
+			Db.AddSwarm(hash, now, now, map[string]int64{LogDay(now): 1})
+		}
+		return
+	}
+
+	Swarms.Add(hash, now)
+}
+
+// 重新选出同一内容指纹中热度最高的种子, 热度不高于当前显示的种子时保持不变, 其它种子标记为重复
+func RefreshGroup(fingerprint string) error {
+	if fingerprint == "" {
+		return nil
+	}
+
+	hottest := Db.FindInfos(InfoQuery{Fingerprint: fingerprint}, 0, 1, "-hot")
+	if len(hottest) == 0 {
+		return nil
+	}
+	best := hottest[0]
+	if shown := Db.FindInfos(InfoQuery{Fingerprint: fingerprint, Collapse: true}, 0, 1, "-hot"); len(shown) > 0 && shown[0].Hot >= best.Hot {
+		best = shown[0]
+	}
+
+	return Db.SetGroupBest(fingerprint, best.InfoHash)
+}
+
+/********************* SC_Log 操作 *********************/
+
+// 当前时间的统计字段加一, 同时计入当天与当前小时, 先在内存中累加, 由统计缓冲定时批量写入
+func SaveLog(field string) {
+	if !IsLogField(field) {
+		return
+	}
+
+	now := time.Now()
+	if Counter == nil {
+		Db.AddLog(LogDay(now), logCounts(now, field, 1))
+		return
+	}
+
+	Counter.Add(now, field)
+}
+
+// 统计日期格式
+func LogDay(t time.Time) string {
+	return t.Format("20060102")
+}
+
+// 生成统计字段的累加值, 包含全天与每小时两部分
+func logCounts(t time.Time, field string, n int64) map[string]int64 {
+	return map[string]int64{
+		field:                                   n,
+		"hours." + t.Format("15") + "." + field: n,
+	}
+}
+
+// 获取指定日期的统计, 包含尚未写入的部分
+func GetLog(day string) SC_Log {
+	sclog := Db.GetLog(day)
+	if Counter != nil {
+		sclog.Day = day
+		sclog.Add(Counter.Pending(day))
+	}
+
+	return sclog
+}
+
+// 统计时间点
+type LogPoint struct {
+	Time      time.Time `json:"time"` // 日期或小时的开始时间
+	LogCounts           // 统计数据
+}
+
+// 获取时间范围内的统计, hourly为true时按小时返回, 没有数据的时间点为0
+func Stats(from, to time.Time, hourly bool) []LogPoint {
+	// 统计日期使用本地时间
+	from, to = from.Local(), to.Local()
+	first := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+
+	// 读取范围内每天的统计, 加上尚未写入的部分
+	logs := make(map[string]SC_Log)
+	for _, sclog := range Db.FindLogs(LogDay(first), LogDay(to)) {
+		logs[sclog.Day] = sclog
+	}
+	if Counter != nil {
+		for day := first; !day.After(to); day = day.AddDate(0, 0, 1) {
+			if pending := Counter.Pending(LogDay(day)); len(pending) > 0 {
+				sclog := logs[LogDay(day)]
+				sclog.Add(pending)
+				logs[LogDay(day)] = sclog
+			}
+		}
+	}
+
+	var points []LogPoint
+	if !hourly {
+		for day := first; !day.After(to); day = day.AddDate(0, 0, 1) {
+			points = append(points, LogPoint{Time: day, LogCounts: logs[LogDay(day)].LogCounts})
+		}
+		return points
+	}
+
+	start := time.Date(from.Year(), from.Month(), from.Day(), from.Hour(), 0, 0, 0, time.Local)
+	for hour := start; !hour.After(to); hour = hour.Add(time.Hour) {
+		points = append(points, LogPoint{Time: hour, LogCounts: logs[LogDay(hour)].Hours[hour.Format("15")]})
+	}
+	return points
+}
+
+/********************* SC_Search 操作 *********************/
+
+// 保存搜索数据, 返回是否为新的关键字
+func (this *SC_Search) Save() (bool, error) {
+	return Db.SaveSearch(this)
+}
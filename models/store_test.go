@@ -0,0 +1,307 @@
+package models
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 测试使用的全部存储
+func testStores(t *testing.T) map[string]Store {
+	bolt, err := NewBoltStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bolt.Close() })
+
+	return map[string]Store{"memory": NewMemoryStore(), "bolt": bolt}
+}
+
+// 获取hash列表中的infohash
+func hashNames(hashes []SC_Hash) []string {
+	names := []string{}
+	for _, h := range hashes {
+		names = append(names, h.InfoHash)
+	}
+	return names
+}
+
+// 获取种子列表中的infohash
+func infoNames(infos []SC_Info) []string {
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.InfoHash)
+	}
+	return names
+}
+
+// 检查结果顺序
+func checkNames(t *testing.T, kind, what string, got []string, want ...string) {
+	t.Helper()
+	if want == nil {
+		want = []string{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s: %s = %v, want %v", kind, what, got, want)
+	}
+}
+
+func TestStorePendingHashes(t *testing.T) {
+	now := time.Now()
+	for kind, store := range testStores(t) {
+		for _, h := range []SC_Hash{
+			{InfoHash: "A", Hot: 5, LastSeen: now.Add(-time.Hour), CreateTime: now.Add(-10 * time.Hour)},
+			{InfoHash: "B", Hot: 9, LastSeen: now.Add(-3 * time.Hour), CreateTime: now.Add(-5 * time.Hour)},
+			{InfoHash: "C", Hot: 1, LastSeen: now.Add(-2 * time.Hour)}, // 没有创建时间的旧数据
+			{InfoHash: "D", Hot: 7, LastSeen: now, CreateTime: now, IsPut: true},
+			{InfoHash: "E", Hot: 3, LastSeen: now, CreateTime: now, Invalid: 4},
+			{InfoHash: "F", Hot: 2, LastSeen: now, CreateTime: now.Add(-time.Hour), Invalid: 3},
+		} {
+			h := h
+			if ok, err := store.ImportHash(&h); !ok || err != nil {
+				t.Fatalf("%s: ImportHash(%s) = %v, %v", kind, h.InfoHash, ok, err)
+			}
+		}
+
+		checkNames(t, kind, "-hot", hashNames(store.PendingHashes("-hot", 0)), "B", "A", "F", "C")
+		checkNames(t, kind, "-hot limit 2", hashNames(store.PendingHashes("-hot", 2)), "B", "A")
+		checkNames(t, kind, "hot", hashNames(store.PendingHashes("hot", 1)), "C")
+		checkNames(t, kind, "-lastseen", hashNames(store.PendingHashes("-lastseen", 0)), "F", "A", "C", "B")
+		checkNames(t, kind, "createtime", hashNames(store.PendingHashes("createtime", 0)), "A", "B", "F")
+		checkNames(t, kind, "_id", hashNames(store.PendingHashes("_id", 0)), "A", "B", "C", "F")
+
+		// 修改后离开或重新排序
+		store.AddInvalid("F")
+		store.SetPut("A")
+		if ok, _ := store.SaveHash(&SC_Hash{InfoHash: "C"}); ok {
+			t.Errorf("%s: SaveHash of existing hash returned new", kind)
+		}
+		checkNames(t, kind, "-lastseen after update", hashNames(store.PendingHashes("-lastseen", 0)), "C", "B")
+		checkNames(t, kind, "-hot after update", hashNames(store.PendingHashes("-hot", 0)), "B", "C")
+		if !store.IsPut("A") || store.IsPut("B") {
+			t.Errorf("%s: IsPut", kind)
+		}
+
+		if n, err := store.DeleteHashes([]string{"B", "X"}); n != 1 || err != nil {
+			t.Errorf("%s: DeleteHashes = %d, %v", kind, n, err)
+		}
+		checkNames(t, kind, "-hot after delete", hashNames(store.PendingHashes("-hot", 0)), "C")
+		if store.HasHash("B") {
+			t.Errorf("%s: deleted hash still exists", kind)
+		}
+	}
+}
+
+func TestStoreSaveHash(t *testing.T) {
+	for kind, store := range testStores(t) {
+		if ok, err := store.SaveHash(&SC_Hash{InfoHash: "A", GetPeers: 1}); !ok || err != nil {
+			t.Fatalf("%s: SaveHash = %v, %v", kind, ok, err)
+		}
+		store.SaveHash(&SC_Hash{InfoHash: "A", Announce: 1})
+		store.SaveHash(&SC_Hash{InfoHash: "A", GetPeers: 1})
+
+		hashes := store.PendingHashes("createtime", 0)
+		if len(hashes) != 1 {
+			t.Fatalf("%s: %d pending hashes", kind, len(hashes))
+		}
+		h := hashes[0]
+		if h.Hot != 3 || h.Announce != 1 || h.GetPeers != 2 || h.CreateTime.IsZero() || h.LastSeen.Before(h.CreateTime) {
+			t.Errorf("%s: hash = %+v", kind, h)
+		}
+
+		// 导入已存在的hash不覆盖
+		if ok, _ := store.ImportHash(&SC_Hash{InfoHash: "A", Hot: 100}); ok {
+			t.Errorf("%s: ImportHash overwrote existing hash", kind)
+		}
+		if n := store.CountHashes(HashQuery{MinHot: 100}); n != 0 {
+			t.Errorf("%s: CountHashes(MinHot 100) = %d", kind, n)
+		}
+	}
+}
+
+func TestStoreScanHashes(t *testing.T) {
+	for kind, store := range testStores(t) {
+		for i, name := range []string{"E", "D", "C", "B", "A"} {
+			store.ImportHash(&SC_Hash{InfoHash: name, Hot: int64(i + 1), IsPut: i == 2})
+		}
+
+		// 按编号顺序分批遍历
+		var all []string
+		query := HashQuery{}
+		for {
+			batch := store.ScanHashes(query, 2)
+			if len(batch) == 0 {
+				break
+			}
+			all = append(all, hashNames(batch)...)
+			query.After = batch[len(batch)-1].Id
+		}
+		checkNames(t, kind, "scan", all, "E", "D", "C", "B", "A")
+
+		checkNames(t, kind, "pending", hashNames(store.ScanHashes(HashQuery{Pending: true, MinHot: 2}, 0)), "D", "B", "A")
+		if n := store.CountHashes(HashQuery{Pending: true}); n != 4 {
+			t.Errorf("%s: CountHashes(Pending) = %d", kind, n)
+		}
+		if n := store.CountHashes(HashQuery{}); n != 5 {
+			t.Errorf("%s: CountHashes() = %d", kind, n)
+		}
+	}
+}
+
+// 保存测试种子
+func saveTestInfos(t *testing.T, store Store, now time.Time) {
+	for _, info := range []SC_Info{
+		{InfoHash: "A", Caption: "Alpha Movie", Hot: 5, Length: 100, PutTime: now.Add(-4 * time.Hour), Category: "video", Fingerprint: "fp1"},
+		{InfoHash: "B", Caption: "Beta Album", Hot: 9, Length: 400, PutTime: now.Add(-3 * time.Hour), Category: "audio"},
+		{InfoHash: "C", Caption: "Alpha Movie 1080p", Hot: 3, Length: 300, PutTime: now.Add(-2 * time.Hour), Category: "video", Fingerprint: "fp1", Duplicate: true},
+		{InfoHash: "D", Caption: "Delta", Hot: 7, Length: 200, PutTime: now.Add(-time.Hour), Category: "video", Keys: []string{"gamma"}, InfoHashV2: "D2"},
+	} {
+		info := info
+		if ok, err := store.SaveInfo(&info); !ok || err != nil {
+			t.Fatalf("SaveInfo(%s) = %v, %v", info.InfoHash, ok, err)
+		}
+	}
+}
+
+func TestStoreFindInfos(t *testing.T) {
+	now := time.Now()
+	for kind, store := range testStores(t) {
+		saveTestInfos(t, store, now)
+
+		checkNames(t, kind, "-hot", infoNames(store.FindInfos(InfoQuery{}, 0, 0, "-hot")), "B", "D", "A", "C")
+		checkNames(t, kind, "-hot page 2", infoNames(store.FindInfos(InfoQuery{}, 1, 2, "-hot")), "D", "A")
+		checkNames(t, kind, "-hot collapse", infoNames(store.FindInfos(InfoQuery{Collapse: true}, 0, 0, "-hot")), "B", "D", "A")
+		checkNames(t, kind, "-puttime", infoNames(store.FindInfos(InfoQuery{Category: "video"}, 0, 2, "-puttime")), "D", "C")
+		checkNames(t, kind, "hot", infoNames(store.FindInfos(InfoQuery{MinHot: 5}, 0, 0, "hot")), "A", "D", "B")
+		checkNames(t, kind, "_id", infoNames(store.FindInfos(InfoQuery{}, 0, 0, "_id")), "A", "B", "C", "D")
+		checkNames(t, kind, "key", infoNames(store.FindInfos(InfoQuery{Key: "alpha"}, 0, 0, "-hot")), "A", "C")
+		checkNames(t, kind, "keys", infoNames(store.FindInfos(InfoQuery{Key: "gam"}, 0, 0, "-hot")), "D")
+		checkNames(t, kind, "fingerprint", infoNames(store.FindInfos(InfoQuery{Fingerprint: "fp1"}, 0, 0, "-hot")), "A", "C")
+		checkNames(t, kind, "length", infoNames(store.FindInfos(InfoQuery{Since: now.Add(-150 * time.Minute)}, 0, 0, "length")), "D", "C")
+		checkNames(t, kind, "out of range", infoNames(store.FindInfos(InfoQuery{}, 10, 5, "-hot")))
+
+		// 按编号分批遍历
+		first := store.FindInfos(InfoQuery{}, 0, 2, "_id")
+		checkNames(t, kind, "after", infoNames(store.FindInfos(InfoQuery{After: first[1].Id}, 0, 0, "_id")), "C", "D")
+
+		for _, tt := range []struct {
+			query InfoQuery
+			want  int
+		}{
+			{InfoQuery{}, 4},
+			{InfoQuery{Category: "video"}, 3},
+			{InfoQuery{Key: "alpha", Collapse: true}, 1},
+			{InfoQuery{Fingerprint: "fp1"}, 2},
+			{InfoQuery{Missing: MissingRelease, MinHot: 6}, 2},
+		} {
+			if n := store.CountInfos(tt.query); n != tt.want {
+				t.Errorf("%s: CountInfos(%+v) = %d, want %d", kind, tt.query, n, tt.want)
+			}
+		}
+
+		if info, ok := store.GetInfoV2("D2"); !ok || info.InfoHash != "D" {
+			t.Errorf("%s: GetInfoV2 = %v, %v", kind, info.InfoHash, ok)
+		}
+	}
+}
+
+func TestStoreUpdateInfo(t *testing.T) {
+	now := time.Now()
+	for kind, store := range testStores(t) {
+		saveTestInfos(t, store, now)
+
+		// 热度与入库时间变化后重新排序
+		for i := 0; i < 5; i++ {
+			store.AddHot("C")
+		}
+		checkNames(t, kind, "-hot after AddHot", infoNames(store.FindInfos(InfoQuery{}, 0, 0, "-hot")), "B", "C", "D", "A")
+
+		if ok, err := store.SaveInfo(&SC_Info{InfoHash: "A", Caption: "Alpha", PutTime: now, Fingerprint: "fp2"}); ok || err != nil {
+			t.Fatalf("%s: SaveInfo of existing info = %v, %v", kind, ok, err)
+		}
+		// 重新入库不覆盖热度与入库时间
+		checkNames(t, kind, "-puttime after SaveInfo", infoNames(store.FindInfos(InfoQuery{}, 0, 2, "-puttime")), "D", "C")
+		if info, _ := store.GetInfo("A"); info.Hot != 5 || info.Caption != "Alpha" || !info.PutTime.Before(now) {
+			t.Errorf("%s: after SaveInfo hot %d, caption %q, puttime %v", kind, info.Hot, info.Caption, info.PutTime)
+		}
+		checkNames(t, kind, "-hot after SaveInfo", infoNames(store.FindInfos(InfoQuery{}, 0, 0, "-hot")), "B", "C", "D", "A")
+		checkNames(t, kind, "fp1 after SaveInfo", infoNames(store.FindInfos(InfoQuery{Fingerprint: "fp1"}, 0, 0, "-hot")), "C")
+		checkNames(t, kind, "fp2 after SaveInfo", infoNames(store.FindInfos(InfoQuery{Fingerprint: "fp2"}, 0, 0, "-hot")), "A")
+
+		store.SetGroupBest("fp1", "C")
+		store.AddViews("C")
+		info, ok := store.GetInfo("C")
+		if !ok || info.Duplicate || info.Views != 1 || info.Hot != 8 {
+			t.Errorf("%s: GetInfo(C) = %+v", kind, info)
+		}
+		if has := store.InfoHashes([]string{"A", "X", "C"}); !reflect.DeepEqual(has, map[string]bool{"A": true, "C": true}) {
+			t.Errorf("%s: InfoHashes = %v", kind, has)
+		}
+	}
+}
+
+func TestRefreshGroup(t *testing.T) {
+	old := Db
+	defer func() { Db = old }()
+
+	for kind, store := range testStores(t) {
+		Db = store
+		saveTestInfos(t, store, time.Now())
+		store.SaveInfo(&SC_Info{InfoHash: "E", Hot: 1, Fingerprint: "fp1", Duplicate: true})
+
+		shown := func() []string {
+			return infoNames(store.FindInfos(InfoQuery{Fingerprint: "fp1", Collapse: true}, 0, 0, "-hot"))
+		}
+
+		// 显示的种子热度最高时不变
+		if err := RefreshGroup("fp1"); err != nil {
+			t.Fatal(err)
+		}
+		checkNames(t, kind, "shown", shown(), "A")
+
+		// 重复的种子热度超过后改为显示
+		for i := 0; i < 3; i++ {
+			store.AddHot("C")
+		}
+		RefreshGroup("fp1")
+		checkNames(t, kind, "shown after AddHot", shown(), "C")
+		checkNames(t, kind, "group", infoNames(store.FindInfos(InfoQuery{Fingerprint: "fp1"}, 0, 0, "-hot")), "C", "A", "E")
+
+		// 热度相同时保持当前显示的种子
+		store.AddHot("A")
+		RefreshGroup("fp1")
+		checkNames(t, kind, "shown after tie", shown(), "C")
+
+		// 其它分组不受影响
+		checkNames(t, kind, "collapse", infoNames(store.FindInfos(InfoQuery{Collapse: true}, 0, 0, "-hot")), "B", "D", "C")
+		if err := RefreshGroup(""); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestStoreImportInfo(t *testing.T) {
+	now := time.Now()
+	for kind, store := range testStores(t) {
+		saveTestInfos(t, store, now)
+
+		// 已存在的种子不修改
+		if ok, err := store.ImportInfo(&SC_Info{InfoHash: "A", Caption: "Other", Hot: 100}); ok || err != nil {
+			t.Errorf("%s: ImportInfo of existing info = %v, %v", kind, ok, err)
+		}
+		if info, _ := store.GetInfo("A"); info.Caption != "Alpha Movie" || info.Hot != 5 {
+			t.Errorf("%s: existing info changed to %q, hot %d", kind, info.Caption, info.Hot)
+		}
+
+		// 新种子保留导入的热度与查看次数
+		info := SC_Info{InfoHash: "E", InfoHashV2: "E2", Caption: "Echo", Hot: 4, Views: 2, PutTime: now, Fingerprint: "fp1"}
+		if ok, err := store.ImportInfo(&info); !ok || err != nil || info.Id == "" {
+			t.Fatalf("%s: ImportInfo = %v, %v, id %q", kind, ok, err, info.Id)
+		}
+		if got, ok := store.GetInfoV2("E2"); !ok || got.Hot != 4 || got.Views != 2 {
+			t.Errorf("%s: GetInfoV2(E2) = %+v", kind, got)
+		}
+		checkNames(t, kind, "fp1 after ImportInfo", infoNames(store.FindInfos(InfoQuery{Fingerprint: "fp1"}, 0, 0, "-hot")), "A", "E", "C")
+	}
+}
@@ -0,0 +1,196 @@
+// 种子获取历史
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+// 获取历史的统计天数, 由Init按配置设置
+var (
+	SwarmDays = 30 // 每日获取次数保留的天数
+	TrendDays = 3  // 近期热度统计的天数
+)
+
+// 合并一段时间内的获取记录
+func (this *SC_Swarm) merge(first, last time.Time, days map[string]int64) {
+	if this.FirstSeen.IsZero() || (!first.IsZero() && first.Before(this.FirstSeen)) {
+		this.FirstSeen = first
+	}
+	if last.After(this.LastSeen) {
+		this.LastSeen = last
+	}
+	if this.Days == nil {
+		this.Days = make(map[string]int64)
+	}
+	for day, n := range days {
+		this.Days[day] += n
+	}
+}
+
+// 删除超出保留天数的日期并重新计算近期热度, 返回删除的日期
+func (this *SC_Swarm) trim(now time.Time) []string {
+	oldest := LogDay(now.AddDate(0, 0, 1-SwarmDays))
+	recent := LogDay(now.AddDate(0, 0, 1-TrendDays))
+
+	var removed []string
+	this.Trend = 0
+	for day, n := range this.Days {
+		if day < oldest {
+			removed = append(removed, day)
+			delete(this.Days, day)
+			continue
+		}
+		if day >= recent {
+			this.Trend += n
+		}
+	}
+
+	return removed
+}
+
+// 截止到now的最近n天每日获取次数, 按日期从早到晚排列
+func (this *SC_Swarm) Series(now time.Time, n int) []int64 {
+	series := make([]int64, n)
+	for i := range series {
+		series[i] = this.Days[LogDay(now.AddDate(0, 0, i+1-n))]
+	}
+	return series
+}
+
+// 种子入库时以hash的首次与最后获取时间开始获取历史, 入库前的获取记录不写入缓冲
+func SeedSwarm(hash string) error {
+	schash, ok := Db.GetHash(hash)
+	if !ok || schash.CreateTime.IsZero() {
+		return nil
+	}
+
+	return Db.AddSwarm(hash, schash.CreateTime, schash.LastSeen, nil)
+}
+
+// 获取种子的获取历史, 包含尚未写入的部分
+func GetSwarm(hash string) (SC_Swarm, bool) {
+	swarm, ok := ReadDb.GetSwarm(hash)
+	if Swarms != nil {
+		if pending, has := Swarms.pending(hash); has {
+			swarm.InfoHash = hash
+			swarm.merge(pending.first, pending.last, pending.days)
+			swarm.trim(time.Now())
+			ok = true
+		}
+	}
+
+	return swarm, ok
+}
+
+// 缓冲中一个infohash的获取记录
+type swarmSeen struct {
+	first time.Time        // 首次获取时间
+	last  time.Time        // 最后获取时间
+	days  map[string]int64 // 以日期为键的获取次数
+}
+
+// 获取历史缓冲, 在内存中累加每个infohash的获取次数, 定时写入已入库的种子
+type SwarmCounter struct {
+	*flushTicker
+	mu    sync.Mutex
+	store Store                 // 写入的数据存储
+	seen  map[string]*swarmSeen // 以infohash为键的待写入记录
+}
+
+// 当前使用的获取历史缓冲
+var Swarms *SwarmCounter
+
+// 创建获取历史缓冲
+func NewSwarmCounter(store Store) *SwarmCounter {
+	counter := &SwarmCounter{
+		store: store,
+		seen:  make(map[string]*swarmSeen),
+	}
+	counter.flushTicker = newFlushTicker("flush swarms: ", counter.Flush)
+	return counter
+}
+
+// 记录一次获取到infohash
+func (this *SwarmCounter) Add(hash string, t time.Time) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.add(hash, &swarmSeen{first: t, last: t, days: map[string]int64{LogDay(t): 1}})
+}
+
+// 累加获取记录, 调用前需加锁
+func (this *SwarmCounter) add(hash string, seen *swarmSeen) {
+	old, ok := this.seen[hash]
+	if !ok {
+		old = &swarmSeen{first: seen.first, last: seen.last, days: make(map[string]int64)}
+		this.seen[hash] = old
+	}
+	if seen.first.Before(old.first) {
+		old.first = seen.first
+	}
+	if seen.last.After(old.last) {
+		old.last = seen.last
+	}
+	for day, n := range seen.days {
+		old.days[day] += n
+	}
+}
+
+// 获取尚未写入的记录
+func (this *SwarmCounter) pending(hash string) (swarmSeen, bool) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	seen, ok := this.seen[hash]
+	if !ok {
+		return swarmSeen{}, false
+	}
+
+	days := make(map[string]int64)
+	for day, n := range seen.days {
+		days[day] = n
+	}
+	return swarmSeen{first: seen.first, last: seen.last, days: days}, true
+}
+
+// 写入缓冲中的记录, 未入库的infohash丢弃, 由SeedSwarm在入库时补充首次获取时间, 写入失败的保留到下次写入
+func (this *SwarmCounter) Flush() error {
+	// 取出当前缓冲, 写入期间的新记录进入新的缓冲
+	this.mu.Lock()
+	seen := this.seen
+	this.seen = make(map[string]*swarmSeen)
+	this.mu.Unlock()
+
+	hashes := make([]string, 0, len(seen))
+	for hash := range seen {
+		hashes = append(hashes, hash)
+	}
+
+	var last error
+	for start := 0; start < len(hashes); start += 500 {
+		end := start + 500
+		if end > len(hashes) {
+			end = len(hashes)
+		}
+
+		// 只记录已入库的种子
+		put := this.store.InfoHashes(hashes[start:end])
+		for _, hash := range hashes[start:end] {
+			if !put[hash] {
+				continue
+			}
+
+			s := seen[hash]
+			if err := this.store.AddSwarm(hash, s.first, s.last, s.days); err != nil {
+				last = err
+
+				this.mu.Lock()
+				this.add(hash, s)
+				this.mu.Unlock()
+			}
+		}
+	}
+
+	return last
+}
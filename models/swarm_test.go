@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSeedSwarm(t *testing.T) {
+	old := Db
+	defer func() { Db = old }()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	created := now.Add(-48 * time.Hour)
+	for kind, store := range testStores(t) {
+		Db = store
+		store.ImportHash(&SC_Hash{InfoHash: "A", Hot: 3, CreateTime: created, LastSeen: now.Add(-time.Hour)})
+
+		// 入库前的获取记录不写入
+		counter := NewSwarmCounter(store)
+		counter.Add("A", now.Add(-30*time.Minute))
+		if err := counter.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := store.GetSwarm("A"); ok {
+			t.Errorf("%s: swarm written before put", kind)
+		}
+
+		store.SaveInfo(&SC_Info{InfoHash: "A"})
+		if err := SeedSwarm("A"); err != nil {
+			t.Fatal(err)
+		}
+		counter.Add("A", now)
+		if err := counter.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		// 首次获取时间为hash的创建时间
+		swarm, ok := store.GetSwarm("A")
+		if !ok || !swarm.FirstSeen.Equal(created) || !swarm.LastSeen.Equal(now) {
+			t.Errorf("%s: first %v, last %v, want %v, %v", kind, swarm.FirstSeen, swarm.LastSeen, created, now)
+		}
+		if len(swarm.Days) != 1 || swarm.Days[LogDay(now)] != 1 || swarm.Trend != 1 {
+			t.Errorf("%s: days %v, trend %d", kind, swarm.Days, swarm.Trend)
+		}
+
+		// 不存在的hash不写入
+		if err := SeedSwarm("X"); err != nil {
+			t.Error(err)
+		}
+		if _, ok := store.GetSwarm("X"); ok {
+			t.Errorf("%s: swarm for unknown hash", kind)
+		}
+	}
+}